@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// maxPendingResults 连接断开期间最多缓存的探测结果数，超出后丢弃最旧的
+const maxPendingResults = 1000
+
+// CheckResult 表示一次探测的结果
+type CheckResult struct {
+	Name          string    `json:"name"`
+	Type          string    `json:"type"`
+	Target        string    `json:"target"`
+	Up            bool      `json:"up"`
+	Latency       float64   `json:"latency"` // 毫秒
+	StatusCode    int       `json:"statusCode,omitempty"`
+	CertExpiresAt time.Time `json:"certExpiresAt,omitzero"`
+	Message       string    `json:"message,omitempty"`
+	Time          time.Time `json:"time"`
+}
+
+// CheckRunner 定时执行探测任务，并缓存结果等待发送给服务器
+type CheckRunner struct {
+	checks []CheckConfig
+	client *http.Client
+
+	mu      sync.Mutex
+	pending []CheckResult
+}
+
+// newCheckRunner 创建探测任务运行器
+func newCheckRunner(checks []CheckConfig) *CheckRunner {
+	return &CheckRunner{
+		checks: checks,
+		client: &http.Client{
+			// 每次探测都重新建立连接，延迟中包含连接建立的时间
+			Transport: &http.Transport{DisableKeepAlives: true, Proxy: http.ProxyFromEnvironment},
+		},
+	}
+}
+
+// Start 为每个探测任务启动一个协程，ctx 取消后全部停止
+func (r *CheckRunner) Start(ctx context.Context) {
+	for _, c := range r.checks {
+		go r.loop(ctx, c)
+	}
+}
+
+// loop 定时执行单个探测任务
+func (r *CheckRunner) loop(ctx context.Context, c CheckConfig) {
+	ticker := time.NewTicker(c.Interval.Duration)
+	defer ticker.Stop()
+
+	wasUp := true
+	for {
+		result := r.run(ctx, c)
+		if result.Up != wasUp {
+			if result.Up {
+				log.Printf("探测 %s 已恢复", c.Name)
+			} else {
+				log.Printf("探测 %s 失败: %s", c.Name, result.Message)
+			}
+			wasUp = result.Up
+		}
+		r.push(result)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// run 执行一次探测，panic 作为失败结果返回
+func (r *CheckRunner) run(ctx context.Context, c CheckConfig) (result CheckResult) {
+	result = CheckResult{Name: c.Name, Type: c.Type, Target: c.Target, Time: time.Now()}
+	defer func() {
+		if p := recover(); p != nil {
+			result.Up = false
+			result.Message = fmt.Sprintf("panic: %v", p)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout.Duration)
+	defer cancel()
+	start := time.Now()
+	var err error
+	switch c.Type {
+	case "http":
+		err = r.checkHTTP(ctx, c, &result)
+	case "tcp":
+		err = checkTCP(ctx, c)
+	}
+	result.Latency = float64(time.Since(start).Microseconds()) / 1000
+	result.Up = err == nil
+	if err != nil {
+		result.Message = err.Error()
+	}
+	return result
+}
+
+// checkHTTP 发送 HTTP 请求并检查状态码、响应内容和证书有效期
+func (r *CheckRunner) checkHTTP(ctx context.Context, c CheckConfig, result *CheckResult) error {
+	req, err := http.NewRequestWithContext(ctx, c.Method, c.Target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "gonitor-client")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	result.StatusCode = resp.StatusCode
+
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		result.CertExpiresAt = resp.TLS.PeerCertificates[0].NotAfter
+	}
+
+	if len(c.ExpectStatus) > 0 {
+		if !slices.Contains(c.ExpectStatus, resp.StatusCode) {
+			return fmt.Errorf("状态码 %d 不在期望的 %v 中", resp.StatusCode, c.ExpectStatus)
+		}
+	} else if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("状态码 %d", resp.StatusCode)
+	}
+
+	if c.bodyMatch != nil {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		if err != nil {
+			return fmt.Errorf("读取响应失败: %w", err)
+		}
+		if !c.bodyMatch.Match(body) {
+			return fmt.Errorf("响应内容不匹配 %s", c.BodyMatch)
+		}
+	}
+
+	if c.MinCertDays > 0 && !result.CertExpiresAt.IsZero() {
+		if left := time.Until(result.CertExpiresAt); left < time.Duration(c.MinCertDays)*24*time.Hour {
+			return fmt.Errorf("证书将在 %s 过期", result.CertExpiresAt.Format(time.DateOnly))
+		}
+	}
+	return nil
+}
+
+// checkTCP 检查端口能否建立连接
+func checkTCP(ctx context.Context, c CheckConfig) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", c.Target)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// push 缓存一条探测结果
+func (r *CheckRunner) push(result CheckResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pending = append(r.pending, result)
+	if over := len(r.pending) - maxPendingResults; over > 0 {
+		r.pending = slices.Delete(r.pending, 0, over)
+	}
+}
+
+// Drain 取出所有等待发送的探测结果
+func (r *CheckRunner) Drain() []CheckResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	results := r.pending
+	r.pending = nil
+	return results
+}
+
+// Requeue 发送失败时把结果放回队列，下次连接后重新发送
+func (r *CheckRunner) Requeue(results []CheckResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pending = append(results, r.pending...)
+	if over := len(r.pending) - maxPendingResults; over > 0 {
+		r.pending = slices.Delete(r.pending, 0, over)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckRunnerHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			w.Write([]byte(`{"status": "ok"}`))
+		case "/error":
+			http.Error(w, "boom", http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	r := newCheckRunner(nil)
+	for _, tc := range []struct {
+		name   string
+		check  CheckConfig
+		up     bool
+		status int
+		msg    string
+	}{
+		{name: "正常", check: CheckConfig{Target: srv.URL + "/health"}, up: true, status: 200},
+		{name: "状态码错误", check: CheckConfig{Target: srv.URL + "/error"}, status: 500, msg: "状态码 500"},
+		{name: "期望的状态码", check: CheckConfig{Target: srv.URL + "/missing", ExpectStatus: []int{404}}, up: true, status: 404},
+		{name: "不在期望的状态码中", check: CheckConfig{Target: srv.URL + "/health", ExpectStatus: []int{204}}, status: 200, msg: "不在期望的 [204] 中"},
+		{name: "响应内容匹配", check: CheckConfig{Target: srv.URL + "/health", BodyMatch: `"status": "ok"`}, up: true, status: 200},
+		{name: "响应内容不匹配", check: CheckConfig{Target: srv.URL + "/health", BodyMatch: `"status": "down"`}, status: 200, msg: "响应内容不匹配"},
+		{name: "无法连接", check: CheckConfig{Target: "http://127.0.0.1:1/"}, msg: "connect"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := tc.check
+			c.Name, c.Type, c.Method, c.Timeout = "web", "http", "GET", Duration{5 * time.Second}
+			if c.BodyMatch != "" {
+				c.bodyMatch = regexp.MustCompile(c.BodyMatch)
+			}
+			result := r.run(context.Background(), c)
+			if result.Up != tc.up || result.StatusCode != tc.status || !strings.Contains(result.Message, tc.msg) {
+				t.Fatalf("探测结果为 %+v", result)
+			}
+			if result.Name != "web" || result.Target != c.Target || result.Time.IsZero() || result.Latency <= 0 {
+				t.Fatalf("探测结果缺少字段: %+v", result)
+			}
+		})
+	}
+}
+
+func TestCheckRunnerCertExpiry(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	r := newCheckRunner(nil)
+	r.client = srv.Client()
+
+	c := CheckConfig{Name: "tls", Type: "http", Target: srv.URL, Method: "GET", Timeout: Duration{5 * time.Second}}
+	result := r.run(context.Background(), c)
+	if !result.Up || result.CertExpiresAt.IsZero() {
+		t.Fatalf("探测结果为 %+v", result)
+	}
+	// 证书剩余有效期不足时失败
+	c.MinCertDays = int(time.Until(result.CertExpiresAt).Hours()/24) + 1
+	if result := r.run(context.Background(), c); result.Up || !strings.Contains(result.Message, "证书将在") {
+		t.Fatalf("证书即将过期时的探测结果为 %+v", result)
+	}
+}
+
+func TestCheckRunnerTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	r := newCheckRunner(nil)
+	c := CheckConfig{Name: "db", Type: "tcp", Target: addr, Timeout: Duration{5 * time.Second}}
+	if result := r.run(context.Background(), c); !result.Up {
+		t.Fatalf("端口开放时的探测结果为 %+v", result)
+	}
+	ln.Close()
+	if result := r.run(context.Background(), c); result.Up || result.Message == "" {
+		t.Fatalf("端口关闭后的探测结果为 %+v", result)
+	}
+}
+
+func TestCheckRunnerQueue(t *testing.T) {
+	r := newCheckRunner(nil)
+	for i := range maxPendingResults + 5 {
+		r.push(CheckResult{Latency: float64(i)})
+	}
+	// 超出上限时丢弃最旧的结果
+	results := r.Drain()
+	if len(results) != maxPendingResults || results[0].Latency != 5 {
+		t.Fatalf("取出 %d 条结果，第一条为 %+v", len(results), results[0])
+	}
+	if len(r.Drain()) != 0 {
+		t.Fatal("取出后队列应为空")
+	}
+
+	// 发送失败放回的结果排在新结果之前
+	r.push(CheckResult{Name: "new"})
+	r.Requeue([]CheckResult{{Name: "old"}})
+	if results := r.Drain(); len(results) != 2 || results[0].Name != "old" || results[1].Name != "new" {
+		t.Fatalf("放回后的结果为 %+v", results)
+	}
+}
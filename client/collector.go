@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sample 表示采集到的一个指标值
+type Sample struct {
+	Name   string            `json:"name"`
+	Value  float64           `json:"value"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Collector 是指标采集器
+// 每个采集器在独立的协程中按 Interval 定时调用 Collect，
+// 某个采集器出错或超时只会让它自己的指标缺失，不会影响其他采集器。
+type Collector interface {
+	// Name 返回采集器名称，用于启用/禁用和日志
+	Name() string
+	// Interval 返回采集间隔
+	Interval() time.Duration
+	// Collect 采集一次指标，ctx 在超过采集间隔后取消
+	Collect(ctx context.Context) ([]Sample, error)
+}
+
+// registry 保存所有已注册的采集器
+var registry = struct {
+	sync.Mutex
+	collectors map[string]Collector
+}{collectors: make(map[string]Collector)}
+
+// Register 注册一个采集器，通常在 init 中调用
+// 名称重复时 panic，避免两个采集器互相覆盖
+func Register(c Collector) {
+	registry.Lock()
+	defer registry.Unlock()
+	if _, ok := registry.collectors[c.Name()]; ok {
+		panic(fmt.Sprintf("采集器 %s 重复注册", c.Name()))
+	}
+	registry.collectors[c.Name()] = c
+}
+
+// Collectors 按名称顺序返回所有已注册的采集器
+func Collectors() []Collector {
+	registry.Lock()
+	defer registry.Unlock()
+	list := make([]Collector, 0, len(registry.collectors))
+	for _, c := range registry.collectors {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list
+}
+
+// selectCollectors 根据启用和禁用列表筛选采集器，列表为逗号分隔的名称
+// enable 为空表示启用全部；名称不存在时返回错误
+func selectCollectors(enable, disable string) ([]Collector, error) {
+	all := Collectors()
+	known := make(map[string]bool, len(all))
+	for _, c := range all {
+		known[c.Name()] = true
+	}
+	parse := func(list string) (map[string]bool, error) {
+		names := make(map[string]bool)
+		for _, name := range strings.Split(list, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if !known[name] {
+				return nil, fmt.Errorf("未知的采集器: %s", name)
+			}
+			names[name] = true
+		}
+		return names, nil
+	}
+	enabled, err := parse(enable)
+	if err != nil {
+		return nil, err
+	}
+	disabled, err := parse(disable)
+	if err != nil {
+		return nil, err
+	}
+
+	var selected []Collector
+	for _, c := range all {
+		if (len(enabled) == 0 || enabled[c.Name()]) && !disabled[c.Name()] {
+			selected = append(selected, c)
+		}
+	}
+	return selected, nil
+}
+
+// Runner 运行一组采集器并保存每个采集器最近一次成功采集的结果
+type Runner struct {
+	collectors []Collector
+
+	mu      sync.Mutex
+	samples map[string][]Sample
+}
+
+// newRunner 创建采集器运行器
+func newRunner(collectors []Collector) *Runner {
+	return &Runner{
+		collectors: collectors,
+		samples:    make(map[string][]Sample),
+	}
+}
+
+// Start 为每个采集器启动一个采集协程，ctx 取消后全部停止
+func (r *Runner) Start(ctx context.Context) {
+	for _, c := range r.collectors {
+		go r.loop(ctx, c)
+	}
+}
+
+// loop 定时执行单个采集器
+func (r *Runner) loop(ctx context.Context, c Collector) {
+	ticker := time.NewTicker(c.Interval())
+	defer ticker.Stop()
+
+	var lastErr string
+	for {
+		samples, err := r.collect(ctx, c)
+		if err != nil {
+			// 出错时丢弃旧数据，避免一直上报过期的值；相同的错误只记录一次
+			if msg := err.Error(); msg != lastErr {
+				log.Printf("采集器 %s 出错: %v", c.Name(), err)
+				lastErr = msg
+			}
+			samples = nil
+		} else if lastErr != "" {
+			log.Printf("采集器 %s 已恢复", c.Name())
+			lastErr = ""
+		}
+
+		r.mu.Lock()
+		r.samples[c.Name()] = samples
+		r.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// collect 执行一次采集，超时和 panic 都作为错误返回
+func (r *Runner) collect(ctx context.Context, c Collector) (samples []Sample, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	ctx, cancel := context.WithTimeout(ctx, max(c.Interval(), time.Second))
+	defer cancel()
+	samples, err = c.Collect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// NaN 和 Inf 无法编码为 JSON，会导致整帧发送失败，这里直接丢弃
+	valid := samples[:0]
+	for _, s := range samples {
+		if isFinite(s.Value) {
+			valid = append(valid, s)
+		}
+	}
+	return valid, nil
+}
+
+// Samples 返回所有采集器最近一次的结果，按采集器名称排序
+func (r *Runner) Samples() []Sample {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var all []Sample
+	for _, c := range r.collectors {
+		all = append(all, r.samples[c.Name()]...)
+	}
+	return all
+}
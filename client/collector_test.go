@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeCollector 每次采集调用 collect
+type fakeCollector struct {
+	name     string
+	interval time.Duration
+	collect  func(ctx context.Context) ([]Sample, error)
+}
+
+func (f *fakeCollector) Name() string            { return f.name }
+func (f *fakeCollector) Interval() time.Duration { return f.interval }
+func (f *fakeCollector) Collect(ctx context.Context) ([]Sample, error) {
+	return f.collect(ctx)
+}
+
+func TestRunnerCollect(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		collect func(ctx context.Context) ([]Sample, error)
+		want    []string
+		err     string
+	}{
+		{
+			name: "正常",
+			collect: func(context.Context) ([]Sample, error) {
+				return []Sample{{Name: "a", Value: 1}, {Name: "b", Value: 2}}, nil
+			},
+			want: []string{"a", "b"},
+		},
+		{
+			name: "丢弃 NaN 和 Inf",
+			collect: func(context.Context) ([]Sample, error) {
+				return []Sample{{Name: "nan", Value: math.NaN()}, {Name: "ok", Value: 1}, {Name: "inf", Value: math.Inf(-1)}}, nil
+			},
+			want: []string{"ok"},
+		},
+		{
+			name: "出错",
+			collect: func(context.Context) ([]Sample, error) {
+				return []Sample{{Name: "a"}}, errors.New("读取失败")
+			},
+			err: "读取失败",
+		},
+		{
+			name: "panic",
+			collect: func(context.Context) ([]Sample, error) {
+				var m map[string]int
+				m["x"] = 1
+				return nil, nil
+			},
+			err: "panic",
+		},
+		{
+			name: "超时",
+			collect: func(ctx context.Context) ([]Sample, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			},
+			err: "deadline exceeded",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newRunner(nil)
+			samples, err := r.collect(context.Background(), &fakeCollector{name: "fake", interval: time.Millisecond, collect: tc.collect})
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) || samples != nil {
+					t.Fatalf("返回 %v, %v，期望包含 %q 的错误", samples, err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, s := range samples {
+				names = append(names, s.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(tc.want, ",") {
+				t.Fatalf("返回的指标为 %v，期望 %v", names, tc.want)
+			}
+		})
+	}
+}
+
+func TestRunnerIsolatesCollectors(t *testing.T) {
+	healthy := &fakeCollector{name: "a", interval: 10 * time.Millisecond, collect: func(context.Context) ([]Sample, error) {
+		return []Sample{{Name: "a_value", Value: 1}}, nil
+	}}
+	broken := &fakeCollector{name: "b", interval: 10 * time.Millisecond, collect: func(context.Context) ([]Sample, error) {
+		panic("采集器崩溃")
+	}}
+	r := newRunner([]Collector{broken, healthy})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r.Start(ctx)
+
+	// 出错的采集器不影响其他采集器，也不会让运行器退出
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		samples := r.Samples()
+		if len(samples) == 1 && samples[0].Name == "a_value" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("采集结果为 %v", samples)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSelectCollectors(t *testing.T) {
+	all := Collectors()
+	if len(all) < 2 {
+		t.Skip("内置采集器不足两个")
+	}
+	first, second := all[0].Name(), all[1].Name()
+	names := func(list []Collector) string {
+		var s []string
+		for _, c := range list {
+			s = append(s, c.Name())
+		}
+		return strings.Join(s, ",")
+	}
+
+	for _, tc := range []struct {
+		enable, disable string
+		want            string
+		err             bool
+	}{
+		{"", "", names(all), false},
+		{first, "", first, false},
+		{" " + second + " , " + first, "", first + "," + second, false},
+		{"", first, names(all[1:]), false},
+		{first, first, "", false},
+		{"missing", "", "", true},
+		{"", "missing", "", true},
+	} {
+		got, err := selectCollectors(tc.enable, tc.disable)
+		if (err != nil) != tc.err || (err == nil && names(got) != tc.want) {
+			t.Errorf("selectCollectors(%q, %q) = %s, %v，期望 %s", tc.enable, tc.disable, names(got), err, tc.want)
+		}
+	}
+}
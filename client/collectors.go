@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/shirou/gopsutil/v3/mem"
+	"github.com/shirou/gopsutil/v3/net"
+)
+
+const (
+	// 常规指标的采集间隔，与发送间隔一致
+	metricsInterval = 500 * time.Millisecond
+	// 网速和磁盘IO采样更频繁，再做平滑处理
+	speedInterval = 200 * time.Millisecond
+	// 网速历史数据窗口大小
+	speedHistorySize = 3
+)
+
+// 内置采集器
+func init() {
+	Register(cpuCollector{})
+	Register(memoryCollector{})
+	Register(diskCollector{})
+	Register(&diskIOCollector{})
+	Register(&networkCollector{})
+}
+
+// cpuCollector 采集CPU使用率
+type cpuCollector struct{}
+
+func (cpuCollector) Name() string            { return "cpu" }
+func (cpuCollector) Interval() time.Duration { return metricsInterval }
+
+func (cpuCollector) Collect(ctx context.Context) ([]Sample, error) {
+	cpuPercent, err := cpu.PercentWithContext(ctx, 0, false)
+	if err != nil {
+		return nil, fmt.Errorf("获取CPU使用率失败: %v", err)
+	}
+	if len(cpuPercent) == 0 {
+		return nil, nil
+	}
+	return []Sample{{Name: "cpu", Value: cpuPercent[0]}}, nil
+}
+
+// memoryCollector 采集内存使用率
+type memoryCollector struct{}
+
+func (memoryCollector) Name() string            { return "memory" }
+func (memoryCollector) Interval() time.Duration { return metricsInterval }
+
+func (memoryCollector) Collect(ctx context.Context) ([]Sample, error) {
+	memInfo, err := mem.VirtualMemoryWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取内存信息失败: %v", err)
+	}
+	return []Sample{{Name: "memory", Value: memInfo.UsedPercent}}, nil
+}
+
+// diskCollector 采集所有磁盘的总体使用率
+type diskCollector struct{}
+
+func (diskCollector) Name() string            { return "disk" }
+func (diskCollector) Interval() time.Duration { return metricsInterval }
+
+func (diskCollector) Collect(ctx context.Context) ([]Sample, error) {
+	// 获取所有磁盘的总容量和总已用空间
+	var totalSpace uint64
+	var usedSpace uint64
+
+	partitions, err := disk.PartitionsWithContext(ctx, false)
+	if err != nil {
+		return nil, fmt.Errorf("获取磁盘分区信息失败: %v", err)
+	}
+
+	for _, partition := range partitions {
+		// 根据操作系统，跳过一些特殊的挂载点
+		if runtime.GOOS == "windows" && partition.Fstype == "NTFS" ||
+			runtime.GOOS != "windows" && (partition.Fstype == "ext4" || partition.Fstype == "xfs") {
+			usage, err := disk.UsageWithContext(ctx, partition.Mountpoint)
+			if err != nil {
+				log.Printf("获取磁盘 %s 使用情况失败: %v", partition.Mountpoint, err)
+				continue
+			}
+			totalSpace += usage.Total
+			usedSpace += usage.Used
+		}
+	}
+
+	if totalSpace == 0 {
+		return nil, nil
+	}
+	// 计算总体使用率
+	return []Sample{{Name: "diskUsage", Value: float64(usedSpace) * 100.0 / float64(totalSpace)}}, nil
+}
+
+// diskIOCollector 采集磁盘读写速度 (KB/s)
+type diskIOCollector struct {
+	lastStats  map[string]disk.IOCountersStat
+	lastTime   time.Time
+	readSpeed  speedHistory
+	writeSpeed speedHistory
+}
+
+func (*diskIOCollector) Name() string            { return "diskio" }
+func (*diskIOCollector) Interval() time.Duration { return speedInterval }
+
+func (d *diskIOCollector) Collect(ctx context.Context) ([]Sample, error) {
+	// 获取当前磁盘IO统计数据
+	currentStats, err := disk.IOCountersWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取磁盘IO统计信息失败: %v", err)
+	}
+
+	now := time.Now()
+	elapsedSec := now.Sub(d.lastTime).Seconds()
+
+	if elapsedSec > 0 && len(d.lastStats) > 0 {
+		var totalReadBytes uint64
+		var totalWriteBytes uint64
+		var lastReadBytes uint64
+		var lastWriteBytes uint64
+
+		// 汇总所有磁盘的IO
+		for name, stat := range currentStats {
+			totalReadBytes += stat.ReadBytes
+			totalWriteBytes += stat.WriteBytes
+
+			if lastStat, ok := d.lastStats[name]; ok {
+				lastReadBytes += lastStat.ReadBytes
+				lastWriteBytes += lastStat.WriteBytes
+			}
+		}
+
+		d.readSpeed.add(counterRate(totalReadBytes, lastReadBytes, elapsedSec))
+		d.writeSpeed.add(counterRate(totalWriteBytes, lastWriteBytes, elapsedSec))
+	}
+
+	// 更新统计数据以备下次使用
+	d.lastStats = currentStats
+	d.lastTime = now
+
+	if d.readSpeed.empty() {
+		return nil, nil
+	}
+	return []Sample{
+		{Name: "diskReadSpeed", Value: d.readSpeed.smoothed()},
+		{Name: "diskWriteSpeed", Value: d.writeSpeed.smoothed()},
+	}, nil
+}
+
+// networkCollector 采集所有网络接口的上传和下载速度 (KB/s)
+type networkCollector struct {
+	lastStats     map[string]net.IOCountersStat
+	lastTime      time.Time
+	uploadSpeed   speedHistory
+	downloadSpeed speedHistory
+}
+
+func (*networkCollector) Name() string            { return "network" }
+func (*networkCollector) Interval() time.Duration { return speedInterval }
+
+func (n *networkCollector) Collect(ctx context.Context) ([]Sample, error) {
+	// 获取当前网络统计数据
+	currentStats, err := net.IOCountersWithContext(ctx, true)
+	if err != nil {
+		return nil, fmt.Errorf("获取网络统计信息失败: %v", err)
+	}
+
+	now := time.Now()
+	elapsedSec := now.Sub(n.lastTime).Seconds()
+
+	if elapsedSec > 0 && len(n.lastStats) > 0 {
+		var totalBytesRecv uint64
+		var totalBytesSent uint64
+		var lastBytesRecv uint64
+		var lastBytesSent uint64
+
+		// 汇总所有接口的流量
+		for _, stat := range currentStats {
+			totalBytesRecv += stat.BytesRecv
+			totalBytesSent += stat.BytesSent
+
+			if lastStat, ok := n.lastStats[stat.Name]; ok {
+				lastBytesRecv += lastStat.BytesRecv
+				lastBytesSent += lastStat.BytesSent
+			}
+		}
+
+		n.downloadSpeed.add(counterRate(totalBytesRecv, lastBytesRecv, elapsedSec))
+		n.uploadSpeed.add(counterRate(totalBytesSent, lastBytesSent, elapsedSec))
+	}
+
+	// 更新统计数据以备下次使用
+	n.lastStats = make(map[string]net.IOCountersStat, len(currentStats))
+	for _, stat := range currentStats {
+		n.lastStats[stat.Name] = stat
+	}
+	n.lastTime = now
+
+	if n.downloadSpeed.empty() {
+		return nil, nil
+	}
+	return []Sample{
+		{Name: "uploadSpeed", Value: n.uploadSpeed.smoothed()},
+		{Name: "downloadSpeed", Value: n.downloadSpeed.smoothed()},
+	}, nil
+}
+
+// counterRate 根据两次计数器读数计算速率 (KB/s)，计数器重置时以当前值计算
+func counterRate(current, last uint64, elapsedSec float64) float64 {
+	if current < last {
+		return float64(current) / elapsedSec / 1024
+	}
+	return float64(current-last) / elapsedSec / 1024
+}
+
+// speedHistory 保存最近几次的速率，用于平滑处理
+type speedHistory []float64
+
+// add 记录一次速率，超出窗口大小时丢弃最旧的数据
+func (h *speedHistory) add(v float64) {
+	if len(*h) >= speedHistorySize {
+		*h = (*h)[1:]
+	}
+	*h = append(*h, v)
+}
+
+func (h speedHistory) empty() bool {
+	return len(h) == 0
+}
+
+// smoothed 返回偏向最新数据的加权平均值
+func (h speedHistory) smoothed() float64 {
+	var sum float64
+	for _, v := range h {
+		sum += v
+	}
+	avg := sum / float64(len(h))
+	if len(h) < 2 {
+		return avg
+	}
+	// 最新数据权重更高
+	return h[len(h)-1]*0.7 + avg*0.3
+}
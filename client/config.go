@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"time"
+)
+
+// Config 表示客户端配置文件，命令行参数之外的采集配置都写在这里
+type Config struct {
+	// Exec 定时执行的命令或脚本
+	Exec []ExecConfig `json:"exec"`
+	// Scrape 定时抓取的本地 Prometheus 接口
+	Scrape []ScrapeConfig `json:"scrape"`
+	// Checks 定时执行的 HTTP 和 TCP 探测
+	Checks []CheckConfig `json:"checks"`
+	// Labels 连接时发送给服务器的静态标签，如 env、region、role
+	Labels map[string]string `json:"labels"`
+}
+
+// labelNameRe 标签名称的格式，与 Prometheus 一致
+var labelNameRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// reservedLabels 由服务器生成的标签名称，不能在配置文件中设置
+var reservedLabels = map[string]bool{"id": true, "name": true, "group": true}
+
+// ExecConfig 表示一个自定义命令采集器
+type ExecConfig struct {
+	Name      string   `json:"name"`
+	Command   []string `json:"command"`   // 程序路径和参数，不经过 shell
+	Format    string   `json:"format"`    // 输出格式：nagios、json 或 prometheus
+	Interval  Duration `json:"interval"`  // 执行间隔，默认 1 分钟
+	Timeout   Duration `json:"timeout"`   // 单次执行超时，默认等于执行间隔
+	MaxOutput int      `json:"maxOutput"` // 标准输出的最大字节数，默认 64KB
+}
+
+// ScrapeConfig 表示一个 Prometheus 抓取采集器
+type ScrapeConfig struct {
+	Name     string   `json:"name"`
+	URL      string   `json:"url"`
+	Interval Duration `json:"interval"` // 抓取间隔，默认 30 秒
+	Timeout  Duration `json:"timeout"`  // 单次抓取超时，默认等于抓取间隔
+	// Metrics 只保留名称匹配其中任一正则的指标，为空表示全部保留
+	Metrics []Regexp `json:"metrics"`
+	// DropMetrics 丢弃名称匹配其中任一正则的指标
+	DropMetrics []Regexp `json:"dropMetrics"`
+	// MatchLabels 只保留每个标签都匹配对应正则的指标，缺少的标签按空字符串匹配
+	MatchLabels map[string]Regexp `json:"matchLabels"`
+	// Labels 附加到每个指标上的标签
+	Labels     map[string]string `json:"labels"`
+	MaxSamples int               `json:"maxSamples"` // 单次抓取最多上报的指标数，默认 500
+	MaxBody    int               `json:"maxBody"`    // 响应的最大字节数，默认 4MB
+}
+
+// CheckConfig 表示一个探测任务
+type CheckConfig struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`     // http 或 tcp
+	Target   string   `json:"target"`   // http 为 URL，tcp 为 host:port
+	Interval Duration `json:"interval"` // 探测间隔，默认 1 分钟
+	Timeout  Duration `json:"timeout"`  // 单次探测超时，默认 10 秒且不超过探测间隔
+
+	// 以下仅用于 http 探测
+	Method       string `json:"method"`       // 请求方法，默认 GET
+	ExpectStatus []int  `json:"expectStatus"` // 期望的状态码，默认 200-399
+	BodyMatch    string `json:"bodyMatch"`    // 响应内容（前 1MB）中需要包含匹配该正则的内容
+	MinCertDays  int    `json:"minCertDays"`  // 证书剩余有效期少于该天数时视为失败
+
+	bodyMatch *regexp.Regexp
+}
+
+// Regexp 从 JSON 字符串解析的正则表达式，与 Prometheus 一样要求完整匹配
+type Regexp struct {
+	*regexp.Regexp
+}
+
+// UnmarshalJSON 解析并编译正则表达式
+func (r *Regexp) UnmarshalJSON(data []byte) error {
+	var expr string
+	if err := json.Unmarshal(data, &expr); err != nil {
+		return err
+	}
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return fmt.Errorf("无效的正则表达式 %q: %w", expr, err)
+	}
+	r.Regexp = re
+	return nil
+}
+
+// Duration 可以从 "30s" 这样的字符串或秒数解析的时间间隔
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalJSON 解析字符串或数字形式的时间间隔
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case float64:
+		d.Duration = time.Duration(v * float64(time.Second))
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		d.Duration = parsed
+	default:
+		return fmt.Errorf("无效的时间间隔: %s", data)
+	}
+	return nil
+}
+
+// loadConfig 读取并校验配置文件，path 为空时返回空配置
+func loadConfig(path string) (*Config, error) {
+	config := &Config{}
+	if path == "" {
+		return config, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("解析配置文件 %s 失败: %w", path, err)
+	}
+
+	names := make(map[string]bool)
+	for i := range config.Exec {
+		e := &config.Exec[i]
+		if e.Name == "" || len(e.Command) == 0 {
+			return nil, fmt.Errorf("第 %d 个 exec 配置缺少 name 或 command", i+1)
+		}
+		if names[e.Name] {
+			return nil, fmt.Errorf("exec 名称 %s 重复", e.Name)
+		}
+		names[e.Name] = true
+		if _, ok := outputParsers[e.Format]; !ok {
+			return nil, fmt.Errorf("exec %s 的输出格式 %q 无效，可选 nagios、json、prometheus", e.Name, e.Format)
+		}
+		if e.Interval.Duration <= 0 {
+			e.Interval.Duration = time.Minute
+		}
+		if e.Timeout.Duration <= 0 || e.Timeout.Duration > e.Interval.Duration {
+			e.Timeout.Duration = e.Interval.Duration
+		}
+		if e.MaxOutput <= 0 {
+			e.MaxOutput = 64 << 10
+		}
+	}
+
+	names = make(map[string]bool)
+	for i := range config.Scrape {
+		sc := &config.Scrape[i]
+		if sc.Name == "" || sc.URL == "" {
+			return nil, fmt.Errorf("第 %d 个 scrape 配置缺少 name 或 url", i+1)
+		}
+		if names[sc.Name] {
+			return nil, fmt.Errorf("scrape 名称 %s 重复", sc.Name)
+		}
+		names[sc.Name] = true
+		if u, err := url.Parse(sc.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, fmt.Errorf("scrape %s 的地址 %q 无效", sc.Name, sc.URL)
+		}
+		if sc.Interval.Duration <= 0 {
+			sc.Interval.Duration = 30 * time.Second
+		}
+		if sc.Timeout.Duration <= 0 || sc.Timeout.Duration > sc.Interval.Duration {
+			sc.Timeout.Duration = sc.Interval.Duration
+		}
+		if sc.MaxSamples <= 0 {
+			sc.MaxSamples = 500
+		}
+		if sc.MaxBody <= 0 {
+			sc.MaxBody = 4 << 20
+		}
+	}
+	names = make(map[string]bool)
+	for i := range config.Checks {
+		c := &config.Checks[i]
+		if c.Name == "" || c.Target == "" {
+			return nil, fmt.Errorf("第 %d 个 checks 配置缺少 name 或 target", i+1)
+		}
+		if names[c.Name] {
+			return nil, fmt.Errorf("check 名称 %s 重复", c.Name)
+		}
+		names[c.Name] = true
+		switch c.Type {
+		case "http":
+			if u, err := url.Parse(c.Target); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				return nil, fmt.Errorf("check %s 的地址 %q 无效", c.Name, c.Target)
+			}
+			if c.Method == "" {
+				c.Method = "GET"
+			}
+			if c.BodyMatch != "" {
+				re, err := regexp.Compile(c.BodyMatch)
+				if err != nil {
+					return nil, fmt.Errorf("check %s 的 bodyMatch 无效: %w", c.Name, err)
+				}
+				c.bodyMatch = re
+			}
+		case "tcp":
+			if _, _, err := net.SplitHostPort(c.Target); err != nil {
+				return nil, fmt.Errorf("check %s 的地址 %q 无效，应为 host:port", c.Name, c.Target)
+			}
+		default:
+			return nil, fmt.Errorf("check %s 的类型 %q 无效，可选 http、tcp", c.Name, c.Type)
+		}
+		if c.Interval.Duration <= 0 {
+			c.Interval.Duration = time.Minute
+		}
+		if c.Timeout.Duration <= 0 {
+			c.Timeout.Duration = 10 * time.Second
+		}
+		c.Timeout.Duration = min(c.Timeout.Duration, c.Interval.Duration)
+	}
+
+	for name, value := range config.Labels {
+		if !labelNameRe.MatchString(name) || reservedLabels[name] {
+			return nil, fmt.Errorf("标签名称 %q 无效或为保留名称", name)
+		}
+		if value == "" || len(value) > 128 {
+			return nil, fmt.Errorf("标签 %s 的值不能为空且不能超过 128 字节", name)
+		}
+	}
+	if len(config.Labels) > 32 {
+		return nil, fmt.Errorf("标签不能超过 32 个")
+	}
+	return config, nil
+}
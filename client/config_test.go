@@ -0,0 +1,125 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig 把配置写入临时文件并返回路径
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "agent.json")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	config, err := loadConfig("")
+	if err != nil || len(config.Exec) != 0 {
+		t.Fatalf("没有配置文件时返回 %+v, %v", config, err)
+	}
+
+	config, err = loadConfig(writeConfig(t, `{
+		"exec": [
+			{"name": "a", "command": ["check_a"], "format": "nagios"},
+			{"name": "b", "command": ["check_b"], "format": "json", "interval": 10, "timeout": "1m", "maxOutput": 10},
+			{"name": "c", "command": ["check_c"], "format": "prometheus", "interval": "5m", "timeout": "30s"}
+		],
+		"scrape": [
+			{"name": "node", "url": "http://localhost:9100/metrics", "metrics": ["node_.*"]},
+			{"name": "app", "url": "https://localhost:8443/metrics", "interval": "10s", "timeout": "1m", "maxSamples": 20, "maxBody": 1024}
+		],
+		"checks": [
+			{"name": "site", "type": "http", "target": "https://example.com", "bodyMatch": "ok"},
+			{"name": "db", "type": "tcp", "target": "localhost:5432", "interval": "5s"},
+			{"name": "api", "type": "http", "target": "http://localhost/health", "method": "HEAD", "interval": "5m", "timeout": "30s"}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []struct {
+		interval, timeout time.Duration
+		maxOutput         int
+	}{
+		{time.Minute, time.Minute, 64 << 10},
+		// 超时不能超过执行间隔
+		{10 * time.Second, 10 * time.Second, 10},
+		{5 * time.Minute, 30 * time.Second, 64 << 10},
+	} {
+		e := config.Exec[i]
+		if e.Interval.Duration != want.interval || e.Timeout.Duration != want.timeout || e.MaxOutput != want.maxOutput {
+			t.Errorf("exec %s 为 %v/%v/%d，期望 %v/%v/%d", e.Name, e.Interval, e.Timeout, e.MaxOutput, want.interval, want.timeout, want.maxOutput)
+		}
+	}
+	for i, want := range []struct {
+		interval, timeout   time.Duration
+		maxSamples, maxBody int
+	}{
+		{30 * time.Second, 30 * time.Second, 500, 4 << 20},
+		{10 * time.Second, 10 * time.Second, 20, 1024},
+	} {
+		sc := config.Scrape[i]
+		if sc.Interval.Duration != want.interval || sc.Timeout.Duration != want.timeout || sc.MaxSamples != want.maxSamples || sc.MaxBody != want.maxBody {
+			t.Errorf("scrape %s 为 %v/%v/%d/%d，期望 %+v", sc.Name, sc.Interval, sc.Timeout, sc.MaxSamples, sc.MaxBody, want)
+		}
+	}
+	if re := config.Scrape[0].Metrics[0]; !re.MatchString("node_load1") || re.MatchString("go_node_x") {
+		t.Error("metrics 中的正则应完整匹配指标名称")
+	}
+	for i, want := range []struct {
+		method            string
+		interval, timeout time.Duration
+	}{
+		{"GET", time.Minute, 10 * time.Second},
+		// 超时不能超过探测间隔
+		{"", 5 * time.Second, 5 * time.Second},
+		{"HEAD", 5 * time.Minute, 30 * time.Second},
+	} {
+		c := config.Checks[i]
+		if c.Method != want.method || c.Interval.Duration != want.interval || c.Timeout.Duration != want.timeout {
+			t.Errorf("check %s 为 %s/%v/%v，期望 %+v", c.Name, c.Method, c.Interval, c.Timeout, want)
+		}
+	}
+	if config.Checks[0].bodyMatch == nil || config.Checks[2].bodyMatch != nil {
+		t.Error("只有配置了 bodyMatch 的探测需要编译正则")
+	}
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	if _, err := loadConfig(filepath.Join(t.TempDir(), "missing.json")); err == nil || !strings.Contains(err.Error(), "读取配置文件失败") {
+		t.Fatalf("文件不存在时返回 %v", err)
+	}
+
+	for _, tc := range []struct {
+		name, config, err string
+	}{
+		{"无效的 JSON", `{"exec": [`, "解析配置文件"},
+		{"无效的时间间隔", `{"exec": [{"name": "a", "command": ["x"], "format": "json", "interval": "often"}]}`, "解析配置文件"},
+		{"exec 缺少命令", `{"exec": [{"name": "a", "format": "json"}]}`, "第 1 个 exec 配置缺少 name 或 command"},
+		{"exec 名称重复", `{"exec": [{"name": "a", "command": ["x"], "format": "json"}, {"name": "a", "command": ["y"], "format": "json"}]}`, "exec 名称 a 重复"},
+		{"exec 输出格式无效", `{"exec": [{"name": "a", "command": ["x"], "format": "xml"}]}`, "输出格式 \"xml\" 无效"},
+		{"scrape 缺少地址", `{"scrape": [{"name": "a"}]}`, "第 1 个 scrape 配置缺少 name 或 url"},
+		{"scrape 名称重复", `{"scrape": [{"name": "a", "url": "http://x"}, {"name": "a", "url": "http://y"}]}`, "scrape 名称 a 重复"},
+		{"scrape 地址无效", `{"scrape": [{"name": "a", "url": "file:///etc/passwd"}]}`, "地址 \"file:///etc/passwd\" 无效"},
+		{"scrape 正则无效", `{"scrape": [{"name": "a", "url": "http://x", "metrics": ["("]}]}`, "无效的正则表达式"},
+		{"check 缺少目标", `{"checks": [{"name": "a", "type": "tcp"}]}`, "第 1 个 checks 配置缺少 name 或 target"},
+		{"check 名称重复", `{"checks": [{"name": "a", "type": "tcp", "target": "x:1"}, {"name": "a", "type": "tcp", "target": "y:1"}]}`, "check 名称 a 重复"},
+		{"check 类型无效", `{"checks": [{"name": "a", "type": "icmp", "target": "x"}]}`, "类型 \"icmp\" 无效"},
+		{"http 地址无效", `{"checks": [{"name": "a", "type": "http", "target": "example.com"}]}`, "地址 \"example.com\" 无效"},
+		{"tcp 缺少端口", `{"checks": [{"name": "a", "type": "tcp", "target": "localhost"}]}`, "应为 host:port"},
+		{"bodyMatch 无效", `{"checks": [{"name": "a", "type": "http", "target": "http://x", "bodyMatch": "("}]}`, "bodyMatch 无效"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := loadConfig(writeConfig(t, tc.config))
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("返回 %v，期望包含 %q 的错误", err, tc.err)
+			}
+		})
+	}
+}
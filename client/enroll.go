@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 连接时携带密钥使用的请求头
+const secretHeader = "X-Gonitor-Secret"
+
+// enrollTimeout 注册请求的超时时间
+const enrollTimeout = 30 * time.Second
+
+// State 表示通过注册令牌注册后保存在本地的客户端身份
+type State struct {
+	ID     string `json:"id"`
+	Secret string `json:"secret"`
+}
+
+// loadOrEnroll 读取本地保存的客户端身份，没有时使用注册令牌向服务器注册并保存
+func loadOrEnroll(path, baseURL, token string) (State, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		var state State
+		if err := json.Unmarshal(data, &state); err != nil || state.ID == "" {
+			return State{}, fmt.Errorf("客户端身份文件 %s 无效，删除后可重新注册", path)
+		}
+		return state, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return State{}, fmt.Errorf("读取客户端身份文件出错: %w", err)
+	}
+	if token == "" {
+		return State{}, errors.New("请提供客户端ID或注册令牌")
+	}
+
+	name, _ := os.Hostname()
+	state, err := enroll(baseURL, token, name)
+	if err != nil {
+		return State{}, err
+	}
+	if err := saveState(path, state); err != nil {
+		return State{}, fmt.Errorf("客户端已注册为 %s，但保存身份文件出错: %w", state.ID, err)
+	}
+	return state, nil
+}
+
+// enroll 使用注册令牌向服务器注册，返回分配的客户端ID和密钥
+func enroll(baseURL, token, name string) (State, error) {
+	body, _ := json.Marshal(map[string]string{"token": token, "name": name})
+	client := &http.Client{Timeout: enrollTimeout}
+	resp, err := client.Post(baseURL+"/api/enroll", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return State{}, fmt.Errorf("注册失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return State{}, fmt.Errorf("注册失败: %s %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var state State
+	if err := json.NewDecoder(resp.Body).Decode(&state); err != nil {
+		return State{}, fmt.Errorf("解析注册结果出错: %w", err)
+	}
+	if state.ID == "" || state.Secret == "" {
+		return State{}, errors.New("服务器返回的注册结果不完整")
+	}
+	return state, nil
+}
+
+// saveState 保存客户端身份，文件中包含密钥，只有当前用户可以读写
+// 先写入临时文件再重命名，避免中断时留下不完整的文件
+func saveState(path string, state State) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
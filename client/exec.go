@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// outputParsers 支持的命令输出格式，exitCode 为命令的退出码
+var outputParsers = map[string]func(name string, output []byte, exitCode int) ([]Sample, error){
+	"nagios":     parseNagios,
+	"json":       requireSuccess(parseJSONMetrics),
+	"prometheus": requireSuccess(parsePromText),
+}
+
+// requireSuccess 包装只接受退出码为 0 的解析器
+func requireSuccess(parse func(output []byte) ([]Sample, error)) func(string, []byte, int) ([]Sample, error) {
+	return func(_ string, output []byte, exitCode int) ([]Sample, error) {
+		if exitCode != 0 {
+			return nil, fmt.Errorf("命令退出码为 %d", exitCode)
+		}
+		return parse(output)
+	}
+}
+
+// execCollector 定时执行配置的命令并解析其输出
+type execCollector struct {
+	config ExecConfig
+}
+
+// newExecCollector 根据配置创建命令采集器
+func newExecCollector(config ExecConfig) *execCollector {
+	return &execCollector{config: config}
+}
+
+func (e *execCollector) Name() string            { return "exec:" + e.config.Name }
+func (e *execCollector) Interval() time.Duration { return e.config.Interval.Duration }
+
+func (e *execCollector) Collect(ctx context.Context) ([]Sample, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.config.Timeout.Duration)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, e.config.Command[0], e.config.Command[1:]...)
+	stdout := &limitedBuffer{limit: e.config.MaxOutput, overflow: cancel}
+	stderr := &limitedBuffer{limit: 1024}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// 子进程继承了输出管道时，不会无限期等待其关闭
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	if stdout.overflowed {
+		return nil, fmt.Errorf("输出超过 %d 字节", e.config.MaxOutput)
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("执行超时（%v）", e.config.Timeout.Duration)
+	}
+	exitCode := 0
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("执行命令失败: %w", err)
+		}
+		exitCode = exitErr.ExitCode()
+	}
+
+	samples, err := outputParsers[e.config.Format](e.config.Name, stdout.Bytes(), exitCode)
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return samples, nil
+}
+
+// limitedBuffer 最多保存 limit 字节的缓冲区，超出后丢弃其余数据并调用 overflow
+// 不能直接嵌入 bytes.Buffer，否则 io.Copy 会通过 ReadFrom 绕过长度限制
+type limitedBuffer struct {
+	buf        bytes.Buffer
+	limit      int
+	overflowed bool
+	overflow   func()
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.buf.Len(); len(p) > room {
+		b.buf.Write(p[:max(room, 0)])
+		if !b.overflowed && b.overflow != nil {
+			b.overflow()
+		}
+		b.overflowed = true
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) Bytes() []byte  { return b.buf.Bytes() }
+func (b *limitedBuffer) String() string { return b.buf.String() }
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimitedBuffer(t *testing.T) {
+	for _, tc := range []struct {
+		writes     []string
+		limit      int
+		want       string
+		overflowed bool
+	}{
+		{[]string{"abc", "de"}, 5, "abcde", false},
+		{[]string{"abc", "def", "gh"}, 5, "abcde", true},
+		{[]string{"abcdefg"}, 5, "abcde", true},
+		{[]string{"a"}, 0, "", true},
+		{nil, 5, "", false},
+	} {
+		calls := 0
+		b := &limitedBuffer{limit: tc.limit, overflow: func() { calls++ }}
+		for _, w := range tc.writes {
+			// 超出部分被丢弃，但仍报告全部写入，避免命令因写入失败而提前退出
+			if n, err := b.Write([]byte(w)); n != len(w) || err != nil {
+				t.Errorf("写入 %q 返回 %d, %v", w, n, err)
+			}
+		}
+		wantCalls := 0
+		if tc.overflowed {
+			wantCalls = 1
+		}
+		if b.String() != tc.want || b.overflowed != tc.overflowed || calls != wantCalls {
+			t.Errorf("写入 %q（上限 %d）后为 %q，overflowed=%v，调用 overflow %d 次", tc.writes, tc.limit, b.String(), b.overflowed, calls)
+		}
+	}
+}
+
+func TestExecCollector(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("需要 /bin/sh")
+	}
+	for _, tc := range []struct {
+		name    string
+		script  string
+		format  string
+		timeout time.Duration
+		want    string
+		err     string
+	}{
+		{name: "JSON", script: `echo '{"queue_depth": 12}'`, format: "json", want: "queue_depth=12"},
+		{name: "Nagios 非零退出码", script: `echo 'CRITICAL|used=95%'; exit 2`, format: "nagios", want: "disk_status=2,used=95"},
+		{name: "Prometheus", script: `printf 'up 1\n'`, format: "prometheus", want: "up=1"},
+		{name: "退出码非零时附带标准错误", script: `echo 'disk missing' >&2; exit 1`, format: "json", err: "退出码为 1: disk missing"},
+		{name: "输出过多", script: `while :; do echo 0123456789; done`, format: "json", err: "输出超过 100 字节"},
+		{name: "超时", script: `sleep 5`, format: "json", timeout: 100 * time.Millisecond, err: "执行超时"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			config := ExecConfig{
+				Name:      "disk",
+				Command:   []string{"/bin/sh", "-c", tc.script},
+				Format:    tc.format,
+				Interval:  Duration{time.Minute},
+				Timeout:   Duration{5 * time.Second},
+				MaxOutput: 100,
+			}
+			if tc.timeout > 0 {
+				config.Timeout.Duration = tc.timeout
+			}
+			start := time.Now()
+			samples, err := newExecCollector(config).Collect(context.Background())
+			if elapsed := time.Since(start); elapsed > 3*time.Second {
+				t.Errorf("执行用时 %v", elapsed)
+			}
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("返回 %v, %v，期望包含 %q 的错误", samples, err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, s := range samples {
+				got = append(got, s.Name+"="+strconv.FormatFloat(s.Value, 'g', -1, 64))
+			}
+			if strings.Join(got, ",") != tc.want {
+				t.Fatalf("采集结果为 %v，期望 %s", got, tc.want)
+			}
+		})
+	}
+
+	if _, err := newExecCollector(ExecConfig{Name: "x", Command: []string{"/nonexistent"}, Format: "json", Timeout: Duration{time.Second}}).Collect(context.Background()); err == nil || !strings.Contains(err.Error(), "执行命令失败") {
+		t.Fatalf("程序不存在时返回 %v", err)
+	}
+}
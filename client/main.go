@@ -1,36 +1,45 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
+	"math/rand"
+	"net/http"
 	"net/url"
-	"runtime"
+	"reflect"
 	"time"
 
 	"github.com/gorilla/websocket"
-	"github.com/shirou/gopsutil/v3/cpu"
-	"github.com/shirou/gopsutil/v3/disk"
-	"github.com/shirou/gopsutil/v3/mem"
-	"github.com/shirou/gopsutil/v3/net"
 )
 
 var (
 	serverAddr = flag.String("server", "localhost:44123", "服务器地址")
 	clientID   = flag.String("id", "", "客户端ID")
-	// 记录上一次网络统计信息
-	lastNetStats map[string]net.IOCountersStat
-	lastNetTime  time.Time
-	// 用于平滑处理的网速历史数据
-	uploadSpeedHistory   []float64
-	downloadSpeedHistory []float64
-	// 网速历史数据窗口大小
-	speedHistorySize = 3
-	// 磁盘IO数据
-	lastDiskIOStats  map[string]disk.IOCountersStat
-	lastDiskIOTime   time.Time
-	diskReadHistory  []float64
-	diskWriteHistory []float64
+	// 自助注册
+	enrollToken = flag.String("enroll", "", "注册令牌，没有指定 -id 且本地没有保存的身份时用于向服务器注册")
+	stateFile   = flag.String("state", "gonitor-agent.json", "注册后保存客户端ID和密钥的文件")
+	// 心跳参数
+	pingInterval = flag.Duration("ping-interval", 10*time.Second, "向服务器发送心跳的间隔")
+	pongTimeout  = flag.Duration("pong-timeout", 30*time.Second, "超过该时间没有收到服务器的任何消息即认为连接断开")
+	configFile   = flag.String("config", "", "配置文件路径（JSON），用于配置自定义命令等采集器")
+	// 采集器选择
+	enableCollectors  = flag.String("collectors", "", "启用的采集器，逗号分隔，为空表示全部启用")
+	disableCollectors = flag.String("disable-collectors", "", "禁用的采集器，逗号分隔")
+	listCollectors    = flag.Bool("list-collectors", false, "列出所有可用的采集器后退出")
+)
+
+const (
+	// 重连等待时间从 minReconnectDelay 开始指数增长，最长 maxReconnectDelay
+	minReconnectDelay = time.Second
+	maxReconnectDelay = time.Minute
+	// 服务器主动关闭（如重启）时的重连等待时间
+	goingAwayDelay = 500 * time.Millisecond
+	// 向服务器写入单条消息的超时时间
+	writeWait = 10 * time.Second
+	// 在握手请求中声明标签使用的请求头
+	labelsHeader = "X-Gonitor-Labels"
 )
 
 // 系统指标结构
@@ -42,35 +51,74 @@ type Metrics struct {
 	DiskWriteSpeed float64 `json:"diskWriteSpeed"` // 磁盘写入速度 (KB/s)
 	UploadSpeed    float64 `json:"uploadSpeed"`    // 上传网速 (KB/s)
 	DownloadSpeed  float64 `json:"downloadSpeed"`  // 下载网速 (KB/s)
+	// Samples 内置字段之外的其他指标，与上一帧相同时为 null，服务器沿用上一次的值
+	Samples []Sample `json:"samples"`
+	// Checks 上一帧之后完成的探测结果
+	Checks []CheckResult `json:"checks,omitempty"`
+}
+
+// newMetrics 将采集到的指标组装成一帧，内置指标填入对应字段，其余放入 Samples
+func newMetrics(samples []Sample) Metrics {
+	m := Metrics{Samples: []Sample{}}
+	builtin := map[string]*float64{
+		"cpu":            &m.CPU,
+		"memory":         &m.Memory,
+		"diskUsage":      &m.DiskUsage,
+		"diskReadSpeed":  &m.DiskReadSpeed,
+		"diskWriteSpeed": &m.DiskWriteSpeed,
+		"uploadSpeed":    &m.UploadSpeed,
+		"downloadSpeed":  &m.DownloadSpeed,
+	}
+	for _, sample := range samples {
+		if field, ok := builtin[sample.Name]; ok && len(sample.Labels) == 0 {
+			*field = sample.Value
+			continue
+		}
+		m.Samples = append(m.Samples, sample)
+	}
+	return m
 }
 
 func main() {
 	flag.Parse()
 
-	if *clientID == "" {
-		log.Fatal("请提供客户端ID")
+	config, err := loadConfig(*configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, e := range config.Exec {
+		Register(newExecCollector(e))
+	}
+	for _, sc := range config.Scrape {
+		Register(newScrapeCollector(sc))
+	}
+
+	if *listCollectors {
+		for _, c := range Collectors() {
+			fmt.Printf("%-16s 间隔 %v\n", c.Name(), c.Interval())
+		}
+		return
 	}
 
-	// 初始化网络统计数据
-	initNetStats()
-	// 初始化磁盘IO统计数据
-	initDiskIOStats()
-	// 初始化网速历史数据
-	uploadSpeedHistory = make([]float64, 0, speedHistorySize)
-	downloadSpeedHistory = make([]float64, 0, speedHistorySize)
-	// 初始化磁盘IO历史数据
-	diskReadHistory = make([]float64, 0, speedHistorySize)
-	diskWriteHistory = make([]float64, 0, speedHistorySize)
+	if *pingInterval <= 0 || *pingInterval >= *pongTimeout {
+		log.Fatalf("心跳间隔 %v 必须大于 0 且小于心跳超时 %v", *pingInterval, *pongTimeout)
+	}
 
-	log.Printf("客户端启动，连接到服务器：%s，客户端ID：%s", *serverAddr, *clientID)
+	collectors, err := selectCollectors(*enableCollectors, *disableCollectors)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(collectors) == 0 {
+		log.Fatal("没有启用任何采集器")
+	}
 
 	// 构造WebSocket URL
-	wsScheme := "ws"
+	wsScheme, httpScheme := "ws", "http"
 	serverURL := *serverAddr
 
 	// 检查服务器地址是否包含协议前缀
 	if len(serverURL) >= 8 && serverURL[:8] == "https://" {
-		wsScheme = "wss"
+		wsScheme, httpScheme = "wss", "https"
 		serverURL = serverURL[8:]
 	} else if len(serverURL) >= 7 && serverURL[:7] == "http://" {
 		serverURL = serverURL[7:]
@@ -81,390 +129,151 @@ func main() {
 		serverURL = serverURL[:len(serverURL)-1]
 	}
 
-	u := url.URL{Scheme: wsScheme, Host: serverURL, Path: "/ws", RawQuery: fmt.Sprintf("id=%s", *clientID)}
-	log.Printf("连接到 %s", u.String())
-
-	// 连接到服务器
-	conn, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
-	if err != nil {
-		log.Fatalf("连接到服务器失败: %v", err)
-	}
-	defer conn.Close()
-
-	log.Println("成功连接到服务器")
-
-	// 启动单独的goroutine来收集网速数据
-	go collectNetworkSpeedData()
-	// 启动单独的goroutine来收集磁盘IO数据
-	go collectDiskIOData()
-
-	// 定时发送系统指标
-	ticker := time.NewTicker(500 * time.Millisecond)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		metrics, err := collectMetrics()
+	// 没有指定客户端ID时使用本地保存的身份，或者用注册令牌向服务器注册
+	id, secret := *clientID, ""
+	if id == "" {
+		state, err := loadOrEnroll(*stateFile, httpScheme+"://"+serverURL, *enrollToken)
 		if err != nil {
-			log.Printf("收集系统指标失败: %v", err)
-			continue
-		}
-
-		if err := conn.WriteJSON(metrics); err != nil {
-			log.Printf("发送数据失败: %v", err)
-			conn.Close()
-			ticker.Stop()
-			// 启动重连
-			reconnect(u.String())
-			break
+			log.Fatal(err)
 		}
+		id, secret = state.ID, state.Secret
 	}
-}
 
-// 初始化网络统计数据
-func initNetStats() {
-	// 获取所有网络接口的IO统计信息
-	stats, err := net.IOCounters(true)
-	if err != nil {
-		log.Printf("初始化网络统计数据失败: %v", err)
-		return
-	}
+	log.Printf("客户端启动，连接到服务器：%s，客户端ID：%s", *serverAddr, id)
 
-	// 转换为map以便查找
-	lastNetStats = make(map[string]net.IOCountersStat)
-	for _, stat := range stats {
-		lastNetStats[stat.Name] = stat
-	}
-	lastNetTime = time.Now()
+	u := url.URL{Scheme: wsScheme, Host: serverURL, Path: "/ws", RawQuery: url.Values{"id": {id}}.Encode()}
+	log.Printf("连接到 %s", u.String())
 
-	log.Println("网络统计数据初始化完成")
-}
+	// 每个采集器在独立的协程中运行，连接断开重连时不中断采集
+	runner := newRunner(collectors)
+	runner.Start(context.Background())
+	checks := newCheckRunner(config.Checks)
+	checks.Start(context.Background())
 
-// 初始化磁盘IO统计数据
-func initDiskIOStats() {
-	// 获取所有磁盘的IO统计信息
-	stats, err := disk.IOCounters()
-	if err != nil {
-		log.Printf("初始化磁盘IO统计数据失败: %v", err)
-		return
+	// 静态标签和密钥在握手请求中发送，服务器每次连接时更新标签
+	header := http.Header{}
+	if secret != "" {
+		header.Set(secretHeader, secret)
 	}
-
-	// 转换为map以便查找
-	lastDiskIOStats = make(map[string]disk.IOCountersStat)
-	for name, stat := range stats {
-		lastDiskIOStats[name] = stat
+	if len(config.Labels) > 0 {
+		labels := url.Values{}
+		for name, value := range config.Labels {
+			labels.Set(name, value)
+		}
+		header.Set(labelsHeader, labels.Encode())
 	}
-	lastDiskIOTime = time.Now()
-
-	log.Println("磁盘IO统计数据初始化完成")
-}
-
-// 单独收集网络速度数据，采样更频繁
-func collectNetworkSpeedData() {
-	ticker := time.NewTicker(200 * time.Millisecond) // 每200毫秒采样一次网络数据
-	defer ticker.Stop()
 
-	for range ticker.C {
-		// 获取当前网络统计数据
-		currentStats, err := net.IOCounters(true)
+	backoff := minReconnectDelay
+	for connected := false; ; {
+		conn, resp, err := websocket.DefaultDialer.Dial(u.String(), header)
 		if err != nil {
-			log.Printf("获取网络统计信息失败: %v", err)
-			continue
-		}
-
-		now := time.Now()
-		elapsedSec := now.Sub(lastNetTime).Seconds()
-
-		if elapsedSec > 0 && len(lastNetStats) > 0 {
-			var totalBytesRecv uint64
-			var totalBytesSent uint64
-			var lastBytesRecv uint64
-			var lastBytesSent uint64
-
-			// 汇总所有接口的流量
-			for _, stat := range currentStats {
-				totalBytesRecv += stat.BytesRecv
-				totalBytesSent += stat.BytesSent
-
-				if lastStat, ok := lastNetStats[stat.Name]; ok {
-					lastBytesRecv += lastStat.BytesRecv
-					lastBytesSent += lastStat.BytesSent
-				}
+			// 服务器可能正在重启，第一次连接失败也按退避时间重试；
+			// 但握手被明确拒绝（客户端ID未注册或密钥无效）时重试没有意义
+			if !connected && resp != nil && resp.StatusCode >= 400 && resp.StatusCode < 500 {
+				log.Fatalf("服务器拒绝连接（%s）: %v", resp.Status, err)
 			}
-
-			// 计算即时速率 (KB/s)
-			var downloadSpeed, uploadSpeed float64
-
-			// 处理计数器重置的情况
-			if totalBytesRecv >= lastBytesRecv {
-				downloadSpeed = float64(totalBytesRecv-lastBytesRecv) / elapsedSec / 1024
+			if connected {
+				log.Printf("重新连接失败: %v，%v后重试...", err, backoff)
 			} else {
-				// 只在计数器重置时输出日志
-				log.Printf("检测到接收计数器重置")
-				downloadSpeed = float64(totalBytesRecv) / elapsedSec / 1024
+				log.Printf("连接到服务器失败: %v，%v后重试...", err, backoff)
 			}
-
-			if totalBytesSent >= lastBytesSent {
-				uploadSpeed = float64(totalBytesSent-lastBytesSent) / elapsedSec / 1024
-			} else {
-				// 只在计数器重置时输出日志
-				log.Printf("检测到发送计数器重置")
-				uploadSpeed = float64(totalBytesSent) / elapsedSec / 1024
-			}
-
-			// 更新历史数据队列
-			if len(downloadSpeedHistory) >= speedHistorySize {
-				downloadSpeedHistory = downloadSpeedHistory[1:]
-			}
-			downloadSpeedHistory = append(downloadSpeedHistory, downloadSpeed)
-
-			if len(uploadSpeedHistory) >= speedHistorySize {
-				uploadSpeedHistory = uploadSpeedHistory[1:]
-			}
-			uploadSpeedHistory = append(uploadSpeedHistory, uploadSpeed)
-
-			// 禁用瞬时网速日志输出，减少控制台输出量
-			// log.Printf("瞬时下载速度: %.2f KB/s, 瞬时上传速度: %.2f KB/s", downloadSpeed, uploadSpeed)
-		}
-
-		// 更新统计数据以备下次使用
-		lastNetStats = make(map[string]net.IOCountersStat)
-		for _, stat := range currentStats {
-			lastNetStats[stat.Name] = stat
-		}
-		lastNetTime = now
-	}
-}
-
-// 单独收集磁盘IO数据
-func collectDiskIOData() {
-	ticker := time.NewTicker(200 * time.Millisecond) // 每200毫秒采样一次磁盘IO数据
-	defer ticker.Stop()
-
-	for range ticker.C {
-		// 获取当前磁盘IO统计数据
-		currentStats, err := disk.IOCounters()
-		if err != nil {
-			log.Printf("获取磁盘IO统计信息失败: %v", err)
+			time.Sleep(withJitter(backoff))
+			backoff = min(backoff*2, maxReconnectDelay)
 			continue
 		}
 
-		now := time.Now()
-		elapsedSec := now.Sub(lastDiskIOTime).Seconds()
-
-		if elapsedSec > 0 && len(lastDiskIOStats) > 0 {
-			var totalReadBytes uint64
-			var totalWriteBytes uint64
-			var lastReadBytes uint64
-			var lastWriteBytes uint64
-
-			// 汇总所有磁盘的IO
-			for name, stat := range currentStats {
-				totalReadBytes += stat.ReadBytes
-				totalWriteBytes += stat.WriteBytes
-
-				if lastStat, ok := lastDiskIOStats[name]; ok {
-					lastReadBytes += lastStat.ReadBytes
-					lastWriteBytes += lastStat.WriteBytes
-				}
-			}
-
-			// 计算即时速率 (KB/s)
-			var readSpeed, writeSpeed float64
-
-			// 处理计数器重置的情况
-			if totalReadBytes >= lastReadBytes {
-				readSpeed = float64(totalReadBytes-lastReadBytes) / elapsedSec / 1024
-			} else {
-				log.Printf("检测到读取计数器重置")
-				readSpeed = float64(totalReadBytes) / elapsedSec / 1024
-			}
-
-			if totalWriteBytes >= lastWriteBytes {
-				writeSpeed = float64(totalWriteBytes-lastWriteBytes) / elapsedSec / 1024
-			} else {
-				log.Printf("检测到写入计数器重置")
-				writeSpeed = float64(totalWriteBytes) / elapsedSec / 1024
-			}
-
-			// 更新历史数据队列
-			if len(diskReadHistory) >= speedHistorySize {
-				diskReadHistory = diskReadHistory[1:]
-			}
-			diskReadHistory = append(diskReadHistory, readSpeed)
-
-			if len(diskWriteHistory) >= speedHistorySize {
-				diskWriteHistory = diskWriteHistory[1:]
-			}
-			diskWriteHistory = append(diskWriteHistory, writeSpeed)
+		if connected {
+			log.Println("重新连接成功")
+		} else {
+			log.Println("成功连接到服务器")
 		}
-
-		// 更新统计数据以备下次使用
-		lastDiskIOStats = make(map[string]disk.IOCountersStat)
-		for name, stat := range currentStats {
-			lastDiskIOStats[name] = stat
+		connected = true
+		backoff = minReconnectDelay
+
+		err = sendMetrics(conn, runner, checks)
+		if websocket.IsCloseError(err, websocket.CloseGoingAway) {
+			// 服务器正在重启，尽快重连而不必等待退避时间
+			log.Println("服务器正在关闭，稍后立即重新连接...")
+			time.Sleep(withJitter(goingAwayDelay))
+			continue
 		}
-		lastDiskIOTime = now
+		log.Printf("连接断开: %v，尝试重新连接...", err)
+		time.Sleep(withJitter(backoff))
 	}
 }
 
-// 收集系统指标
-func collectMetrics() (Metrics, error) {
-	metrics := Metrics{}
-
-	// 获取CPU使用率
-	cpuPercent, err := cpu.Percent(0, false)
-	if err != nil {
-		return metrics, fmt.Errorf("获取CPU使用率失败: %v", err)
-	}
-	if len(cpuPercent) > 0 {
-		metrics.CPU = cpuPercent[0]
-	}
-
-	// 获取内存使用率
-	memInfo, err := mem.VirtualMemory()
-	if err != nil {
-		return metrics, fmt.Errorf("获取内存信息失败: %v", err)
-	}
-	metrics.Memory = memInfo.UsedPercent
-
-	// 获取所有磁盘的总容量和总已用空间
-	var totalSpace uint64
-	var usedSpace uint64
+// sendMetrics 在连接上定时发送系统指标，直到连接断开，返回断开的原因
+func sendMetrics(conn *websocket.Conn, runner *Runner, checks *CheckRunner) error {
+	defer conn.Close()
 
-	partitions, err := disk.Partitions(false)
-	if err != nil {
-		return metrics, fmt.Errorf("获取磁盘分区信息失败: %v", err)
+	// 收到服务器的任何消息、心跳或心跳回应都会延长读取期限，
+	// 超过 pongTimeout 没有动静即认为服务器已失联
+	extendDeadline := func() error {
+		return conn.SetReadDeadline(time.Now().Add(*pongTimeout))
 	}
-
-	for _, partition := range partitions {
-		// 根据操作系统，跳过一些特殊的挂载点
-		if runtime.GOOS == "windows" && partition.Fstype == "NTFS" ||
-			runtime.GOOS != "windows" && (partition.Fstype == "ext4" || partition.Fstype == "xfs") {
-			usage, err := disk.Usage(partition.Mountpoint)
-			if err != nil {
-				log.Printf("获取磁盘 %s 使用情况失败: %v", partition.Mountpoint, err)
-				continue
+	extendDeadline()
+	conn.SetPongHandler(func(string) error {
+		return extendDeadline()
+	})
+	conn.SetPingHandler(func(data string) error {
+		extendDeadline()
+		err := conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(writeWait))
+		if err == websocket.ErrCloseSent {
+			return nil
+		}
+		return err
+	})
+
+	// 持续读取服务器消息，以便及时收到关闭帧和心跳
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				readErr <- err
+				return
 			}
-			totalSpace += usage.Total
-			usedSpace += usage.Used
+			extendDeadline()
 		}
-	}
+	}()
 
-	if totalSpace > 0 {
-		// 计算总体使用率
-		metrics.DiskUsage = float64(usedSpace) * 100.0 / float64(totalSpace)
-	}
+	// 定时发送系统指标和心跳
+	ticker := time.NewTicker(metricsInterval)
+	defer ticker.Stop()
+	pingTicker := time.NewTicker(*pingInterval)
+	defer pingTicker.Stop()
 
-	// 使用历史数据计算平滑的网速
-	if len(downloadSpeedHistory) > 0 {
-		// 计算平均值
-		var sum float64
-		for _, v := range downloadSpeedHistory {
-			sum += v
-		}
-		// 偏向最新数据的加权平均
-		if len(downloadSpeedHistory) >= 2 {
-			// 最新数据权重更高
-			metrics.DownloadSpeed = (downloadSpeedHistory[len(downloadSpeedHistory)-1] * 0.7) +
-				(sum / float64(len(downloadSpeedHistory)) * 0.3)
-		} else {
-			metrics.DownloadSpeed = sum / float64(len(downloadSpeedHistory))
-		}
-	}
+	// 其他指标通常变化不频繁且可能很多，只在变化时发送；新连接的第一帧总是完整发送
+	var lastSamples []Sample
 
-	if len(uploadSpeedHistory) > 0 {
-		// 计算平均值
-		var sum float64
-		for _, v := range uploadSpeedHistory {
-			sum += v
-		}
-		// 偏向最新数据的加权平均
-		if len(uploadSpeedHistory) >= 2 {
-			// 最新数据权重更高
-			metrics.UploadSpeed = (uploadSpeedHistory[len(uploadSpeedHistory)-1] * 0.7) +
-				(sum / float64(len(uploadSpeedHistory)) * 0.3)
-		} else {
-			metrics.UploadSpeed = sum / float64(len(uploadSpeedHistory))
+	for {
+		select {
+		case err := <-readErr:
+			return err
+		case <-pingTicker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				return fmt.Errorf("发送心跳失败: %w", err)
+			}
+			continue
+		case <-ticker.C:
 		}
-	}
 
-	// 计算磁盘读取速度平滑值
-	if len(diskReadHistory) > 0 {
-		var sum float64
-		for _, v := range diskReadHistory {
-			sum += v
-		}
-		// 偏向最新数据的加权平均
-		if len(diskReadHistory) >= 2 {
-			metrics.DiskReadSpeed = (diskReadHistory[len(diskReadHistory)-1] * 0.7) +
-				(sum / float64(len(diskReadHistory)) * 0.3)
+		metrics := newMetrics(runner.Samples())
+		if lastSamples != nil && reflect.DeepEqual(metrics.Samples, lastSamples) {
+			metrics.Samples = nil
 		} else {
-			metrics.DiskReadSpeed = sum / float64(len(diskReadHistory))
+			lastSamples = metrics.Samples
 		}
-	}
+		metrics.Checks = checks.Drain()
 
-	// 计算磁盘写入速度平滑值
-	if len(diskWriteHistory) > 0 {
-		var sum float64
-		for _, v := range diskWriteHistory {
-			sum += v
-		}
-		// 偏向最新数据的加权平均
-		if len(diskWriteHistory) >= 2 {
-			metrics.DiskWriteSpeed = (diskWriteHistory[len(diskWriteHistory)-1] * 0.7) +
-				(sum / float64(len(diskWriteHistory)) * 0.3)
-		} else {
-			metrics.DiskWriteSpeed = sum / float64(len(diskWriteHistory))
+		conn.SetWriteDeadline(time.Now().Add(writeWait))
+		if err := conn.WriteJSON(metrics); err != nil {
+			checks.Requeue(metrics.Checks)
+			return fmt.Errorf("发送数据失败: %w", err)
 		}
 	}
-
-	return metrics, nil
 }
 
-// 尝试重新连接
-func reconnect(serverUrl string) {
-	log.Println("连接断开，尝试重新连接...")
-	for {
-		// 重新初始化网络统计数据
-		initNetStats()
-		// 重新初始化磁盘IO统计数据
-		initDiskIOStats()
-		// 清空历史数据
-		uploadSpeedHistory = make([]float64, 0, speedHistorySize)
-		downloadSpeedHistory = make([]float64, 0, speedHistorySize)
-		diskReadHistory = make([]float64, 0, speedHistorySize)
-		diskWriteHistory = make([]float64, 0, speedHistorySize)
-
-		// 尝试重新连接
-		conn, _, err := websocket.DefaultDialer.Dial(serverUrl, nil)
-		if err != nil {
-			log.Printf("重新连接失败: %v，5秒后重试...", err)
-			time.Sleep(5 * time.Second)
-			continue
-		}
-
-		log.Println("重新连接成功")
-
-		// 启动单独的goroutine来收集网速数据
-		go collectNetworkSpeedData()
-		// 启动单独的goroutine来收集磁盘IO数据
-		go collectDiskIOData()
-
-		// 定时发送系统指标
-		ticker := time.NewTicker(500 * time.Millisecond)
-		for range ticker.C {
-			metrics, err := collectMetrics()
-			if err != nil {
-				log.Printf("收集系统指标失败: %v", err)
-				continue
-			}
-
-			if err := conn.WriteJSON(metrics); err != nil {
-				log.Printf("发送数据失败: %v", err)
-				conn.Close()
-				ticker.Stop()
-				break
-			}
-		}
-	}
+// withJitter 在等待时间上增加最多 50% 的随机抖动，避免大量客户端同时重连
+func withJitter(d time.Duration) time.Duration {
+	return d + time.Duration(rand.Int63n(int64(d)/2+1))
 }
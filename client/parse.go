@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// parseNagios 解析 Nagios 插件格式的输出
+// 退出码 0-3 分别表示 OK、WARNING、CRITICAL、UNKNOWN，作为 <name>_status 指标上报；
+// "|" 之后的性能数据 'label'=value[UOM];warn;crit;min;max 中的每一项作为一个指标上报。
+func parseNagios(name string, output []byte, exitCode int) ([]Sample, error) {
+	if exitCode < 0 || exitCode > 3 {
+		return nil, fmt.Errorf("无效的 Nagios 退出码 %d", exitCode)
+	}
+	samples := []Sample{{Name: name + "_status", Value: float64(exitCode)}}
+
+	// 第一行 "|" 之后是性能数据，其余各行中第一个 "|" 之后的内容也是性能数据
+	var perfdata []string
+	first, rest, _ := strings.Cut(string(output), "\n")
+	if _, perf, ok := strings.Cut(first, "|"); ok {
+		perfdata = append(perfdata, perf)
+	}
+	if _, perf, ok := strings.Cut(rest, "|"); ok {
+		perfdata = append(perfdata, perf)
+	}
+
+	for _, field := range splitPerfdata(strings.Join(perfdata, " ")) {
+		label, data, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, fmt.Errorf("无效的性能数据: %s", field)
+		}
+		label = strings.Trim(label, "'")
+		value, _, _ := strings.Cut(data, ";")
+		// 去掉单位，U 表示无法确定的值
+		value = strings.TrimRight(value, "%abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+		if value == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("性能数据 %s 的值无效: %v", label, err)
+		}
+		samples = append(samples, Sample{Name: label, Value: v})
+	}
+	return samples, nil
+}
+
+// splitPerfdata 按空格拆分性能数据，单引号中的空格不拆分
+func splitPerfdata(s string) []string {
+	var fields []string
+	var field strings.Builder
+	quoted := false
+	for _, r := range s {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+			field.WriteRune(r)
+		case (r == ' ' || r == '\t' || r == '\n' || r == '\r') && !quoted:
+			if field.Len() > 0 {
+				fields = append(fields, field.String())
+				field.Reset()
+			}
+		default:
+			field.WriteRune(r)
+		}
+	}
+	if field.Len() > 0 {
+		fields = append(fields, field.String())
+	}
+	return fields
+}
+
+// parseJSONMetrics 解析 JSON 格式的输出，支持两种形式：
+//
+//	{"queue_depth": 12, "replication_lag": 0.5}
+//	[{"name": "queue_depth", "value": 12, "labels": {"queue": "mail"}}]
+func parseJSONMetrics(output []byte) ([]Sample, error) {
+	output = bytes.TrimSpace(output)
+	if len(output) > 0 && output[0] == '[' {
+		var samples []Sample
+		if err := json.Unmarshal(output, &samples); err != nil {
+			return nil, fmt.Errorf("解析 JSON 输出失败: %w", err)
+		}
+		for _, s := range samples {
+			if s.Name == "" {
+				return nil, fmt.Errorf("JSON 输出中的指标缺少 name")
+			}
+		}
+		return samples, nil
+	}
+
+	var values map[string]float64
+	if err := json.Unmarshal(output, &values); err != nil {
+		return nil, fmt.Errorf("解析 JSON 输出失败: %w", err)
+	}
+	samples := make([]Sample, 0, len(values))
+	for name, v := range values {
+		samples = append(samples, Sample{Name: name, Value: v})
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].Name < samples[j].Name })
+	return samples, nil
+}
+
+// parsePromText 解析 Prometheus 文本格式，忽略注释和时间戳
+func parsePromText(output []byte) ([]Sample, error) {
+	var samples []Sample
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(nil, len(output)+1)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == '#' {
+			continue
+		}
+		sample, err := parsePromLine(text)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 行: %w", line, err)
+		}
+		samples = append(samples, sample)
+	}
+	return samples, scanner.Err()
+}
+
+// parsePromLine 解析一行 name{label="value",...} value [timestamp]
+func parsePromLine(line string) (Sample, error) {
+	var s Sample
+	end := strings.IndexAny(line, "{ \t")
+	if end <= 0 {
+		return s, fmt.Errorf("无效的指标: %s", line)
+	}
+	s.Name = line[:end]
+	rest := line[end:]
+
+	if rest[0] == '{' {
+		labels, n, err := parsePromLabels(rest)
+		if err != nil {
+			return s, err
+		}
+		if len(labels) > 0 {
+			s.Labels = labels
+		}
+		rest = rest[n:]
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 || len(fields) > 2 {
+		return s, fmt.Errorf("无效的指标值: %s", line)
+	}
+	v, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return s, fmt.Errorf("无效的指标值 %s: %v", fields[0], err)
+	}
+	s.Value = v
+	return s, nil
+}
+
+// parsePromLabels 解析以 { 开头的标签集合，返回标签和消耗的字节数
+func parsePromLabels(s string) (map[string]string, int, error) {
+	labels := make(map[string]string)
+	i := 1
+	for {
+		for i < len(s) && (s[i] == ' ' || s[i] == ',') {
+			i++
+		}
+		if i >= len(s) {
+			return nil, 0, fmt.Errorf("标签没有结束: %s", s)
+		}
+		if s[i] == '}' {
+			return labels, i + 1, nil
+		}
+
+		eq := strings.IndexByte(s[i:], '=')
+		if eq <= 0 {
+			return nil, 0, fmt.Errorf("无效的标签: %s", s[i:])
+		}
+		name := strings.TrimSpace(s[i : i+eq])
+		i += eq + 1
+		if i >= len(s) || s[i] != '"' {
+			return nil, 0, fmt.Errorf("标签 %s 的值缺少引号", name)
+		}
+		i++
+
+		var value strings.Builder
+		for ; i < len(s) && s[i] != '"'; i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+				switch s[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(s[i])
+				}
+				continue
+			}
+			value.WriteByte(s[i])
+		}
+		if i >= len(s) {
+			return nil, 0, fmt.Errorf("标签 %s 的值没有结束", name)
+		}
+		i++
+		labels[name] = value.String()
+	}
+}
+
+// isFinite 判断指标值能否编码为 JSON
+func isFinite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
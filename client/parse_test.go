@@ -0,0 +1,133 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseNagios(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		output   string
+		exitCode int
+		want     []Sample
+		err      string
+	}{
+		{
+			name:   "性能数据",
+			output: "OK - load average: 0.50|load1=0.5;1;2;0; load5=0.3",
+			want:   []Sample{{Name: "load_status", Value: 0}, {Name: "load1", Value: 0.5}, {Name: "load5", Value: 0.3}},
+		},
+		{
+			name:     "带引号的名称和单位",
+			output:   "WARNING - disk|'disk used'=80%;90;95 'io wait'=12ms\n",
+			exitCode: 1,
+			want:     []Sample{{Name: "load_status", Value: 1}, {Name: "disk used", Value: 80}, {Name: "io wait", Value: 12}},
+		},
+		{
+			name:     "多行输出",
+			output:   "CRITICAL - ping|rta=120ms\nlong output line 1\nline 2 | pl=20%\n",
+			exitCode: 2,
+			want:     []Sample{{Name: "load_status", Value: 2}, {Name: "rta", Value: 120}, {Name: "pl", Value: 20}},
+		},
+		{
+			name:     "没有性能数据且值未知",
+			output:   "UNKNOWN - no data|x=U",
+			exitCode: 3,
+			want:     []Sample{{Name: "load_status", Value: 3}},
+		},
+		{name: "无效的退出码", output: "OK", exitCode: 4, err: "退出码"},
+		{name: "缺少等号", output: "OK|garbage", err: "无效的性能数据"},
+		{name: "无效的值", output: "OK|x=abc1", err: "值无效"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseNagios("load", []byte(tc.output), tc.exitCode)
+			checkParse(t, got, err, tc.want, tc.err)
+		})
+	}
+}
+
+func TestParseJSONMetrics(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		output string
+		want   []Sample
+		err    string
+	}{
+		{
+			name:   "对象",
+			output: `{"replication_lag": 0.5, "queue_depth": 12}`,
+			want:   []Sample{{Name: "queue_depth", Value: 12}, {Name: "replication_lag", Value: 0.5}},
+		},
+		{
+			name:   "数组",
+			output: "  \n[{\"name\": \"queue_depth\", \"value\": 12, \"labels\": {\"queue\": \"mail\"}}]\n",
+			want:   []Sample{{Name: "queue_depth", Value: 12, Labels: map[string]string{"queue": "mail"}}},
+		},
+		{name: "缺少名称", output: `[{"value": 1}]`, err: "缺少 name"},
+		{name: "值不是数字", output: `{"a": "x"}`, err: "解析 JSON 输出失败"},
+		{name: "无效的 JSON", output: `{"a": 1`, err: "解析 JSON 输出失败"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseJSONMetrics([]byte(tc.output))
+			checkParse(t, got, err, tc.want, tc.err)
+		})
+	}
+}
+
+func TestParsePromText(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		output string
+		want   []Sample
+		err    string
+	}{
+		{
+			name: "注释、标签和时间戳",
+			output: `# HELP http_requests_total 请求数
+# TYPE http_requests_total counter
+http_requests_total{method="GET", code="200"} 1027 1395066363000
+
+up 1
+empty_labels{} 2.5e3
+escaped{path="C:\\dir",msg="say \"hi\"\nbye"} -1
+`,
+			want: []Sample{
+				{Name: "http_requests_total", Value: 1027, Labels: map[string]string{"method": "GET", "code": "200"}},
+				{Name: "up", Value: 1},
+				{Name: "empty_labels", Value: 2500},
+				{Name: "escaped", Value: -1, Labels: map[string]string{"path": `C:\dir`, "msg": "say \"hi\"\nbye"}},
+			},
+		},
+		{name: "缺少值", output: "up\n", err: "第 1 行"},
+		{name: "多余的字段", output: "up 1\nup 1 2 3\n", err: "第 2 行"},
+		{name: "无效的值", output: "up one", err: "无效的指标值"},
+		{name: "标签没有结束", output: `up{a="b",`, err: "标签没有结束"},
+		{name: "标签值没有结束", output: `up{a="b} 1`, err: "值没有结束"},
+		{name: "标签值缺少引号", output: `up{a=b} 1`, err: "缺少引号"},
+		{name: "无效的标签", output: `up{a} 1`, err: "无效的标签"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parsePromText([]byte(tc.output))
+			checkParse(t, got, err, tc.want, tc.err)
+		})
+	}
+}
+
+// checkParse 检查解析结果，wantErr 不为空时期望返回包含该内容的错误
+func checkParse(t *testing.T, got []Sample, err error, want []Sample, wantErr string) {
+	t.Helper()
+	if wantErr != "" {
+		if err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Fatalf("返回 %v, %v，期望包含 %q 的错误", got, err, wantErr)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("解析结果为 %+v，期望 %+v", got, want)
+	}
+}
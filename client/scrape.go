@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// scrapeCollector 定时抓取 Prometheus 文本格式的接口，筛选后通过 WebSocket 转发给服务器
+type scrapeCollector struct {
+	config ScrapeConfig
+	client *http.Client
+}
+
+// newScrapeCollector 根据配置创建抓取采集器
+func newScrapeCollector(config ScrapeConfig) *scrapeCollector {
+	return &scrapeCollector{config: config, client: &http.Client{}}
+}
+
+func (s *scrapeCollector) Name() string            { return "scrape:" + s.config.Name }
+func (s *scrapeCollector) Interval() time.Duration { return s.config.Interval.Duration }
+
+func (s *scrapeCollector) Collect(ctx context.Context) ([]Sample, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.config.Timeout.Duration)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.config.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/plain;version=0.0.4")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("抓取失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("抓取失败: %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, int64(s.config.MaxBody)+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+	if len(body) > s.config.MaxBody {
+		return nil, fmt.Errorf("响应超过 %d 字节", s.config.MaxBody)
+	}
+
+	all, err := parsePromText(body)
+	if err != nil {
+		return nil, err
+	}
+	samples := all[:0]
+	for _, sample := range all {
+		if !s.keep(sample) {
+			continue
+		}
+		if len(samples) == s.config.MaxSamples {
+			return nil, fmt.Errorf("筛选后的指标超过 %d 个，请通过 metrics、dropMetrics 或 matchLabels 缩小范围", s.config.MaxSamples)
+		}
+		for k, v := range s.config.Labels {
+			if sample.Labels == nil {
+				sample.Labels = make(map[string]string, len(s.config.Labels))
+			}
+			sample.Labels[k] = v
+		}
+		samples = append(samples, sample)
+	}
+	return samples, nil
+}
+
+// keep 判断指标是否通过名称和标签筛选
+func (s *scrapeCollector) keep(sample Sample) bool {
+	if len(s.config.Metrics) > 0 && !matchAny(s.config.Metrics, sample.Name) {
+		return false
+	}
+	if matchAny(s.config.DropMetrics, sample.Name) {
+		return false
+	}
+	for label, re := range s.config.MatchLabels {
+		if !re.MatchString(sample.Labels[label]) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchAny 判断名称是否匹配任一正则
+func matchAny(patterns []Regexp, name string) bool {
+	for _, re := range patterns {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mustRegexp 与配置文件相同的方式编译正则表达式
+func mustRegexp(t *testing.T, expr string) Regexp {
+	t.Helper()
+	var re Regexp
+	if err := re.UnmarshalJSON([]byte(strconv.Quote(expr))); err != nil {
+		t.Fatal(err)
+	}
+	return re
+}
+
+func TestScrapeKeep(t *testing.T) {
+	s := &scrapeCollector{config: ScrapeConfig{
+		Metrics:     []Regexp{mustRegexp(t, "nginx_.*"), mustRegexp(t, "up")},
+		DropMetrics: []Regexp{mustRegexp(t, ".*_bucket")},
+		MatchLabels: map[string]Regexp{"job": mustRegexp(t, "web|")},
+	}}
+	for _, tc := range []struct {
+		sample Sample
+		want   bool
+	}{
+		{Sample{Name: "up"}, true},
+		{Sample{Name: "nginx_requests_total", Labels: map[string]string{"job": "web"}}, true},
+		// 正则需要完整匹配名称
+		{Sample{Name: "upstream"}, false},
+		{Sample{Name: "go_goroutines"}, false},
+		{Sample{Name: "nginx_latency_bucket"}, false},
+		{Sample{Name: "up", Labels: map[string]string{"job": "db"}}, false},
+		// 缺少的标签按空字符串匹配
+		{Sample{Name: "up", Labels: map[string]string{"instance": "a"}}, true},
+	} {
+		if got := s.keep(tc.sample); got != tc.want {
+			t.Errorf("keep(%+v) = %v，期望 %v", tc.sample, got, tc.want)
+		}
+	}
+
+	// 没有配置 metrics 时保留全部
+	all := &scrapeCollector{config: ScrapeConfig{}}
+	if !all.keep(Sample{Name: "anything"}) {
+		t.Error("没有筛选条件时应保留所有指标")
+	}
+}
+
+func TestScrapeCollect(t *testing.T) {
+	body := "# TYPE up gauge\nup 1\nreqs{code=\"200\"} 10\nreqs{code=\"500\"} 2\ngo_goroutines 8\n"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/metrics":
+			w.Write([]byte(body))
+		case "/invalid":
+			w.Write([]byte("up{"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	collector := func(path string, update func(c *ScrapeConfig)) *scrapeCollector {
+		config := ScrapeConfig{
+			Name:       "app",
+			URL:        srv.URL + path,
+			Timeout:    Duration{5 * time.Second},
+			MaxSamples: 500,
+			MaxBody:    4 << 20,
+		}
+		if update != nil {
+			update(&config)
+		}
+		return newScrapeCollector(config)
+	}
+
+	samples, err := collector("/metrics", func(c *ScrapeConfig) {
+		c.DropMetrics = []Regexp{mustRegexp(t, "go_.*")}
+		c.Labels = map[string]string{"service": "app"}
+	}).Collect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, s := range samples {
+		got = append(got, s.Name+"/"+s.Labels["code"]+"/"+s.Labels["service"])
+	}
+	if strings.Join(got, ",") != "up//app,reqs/200/app,reqs/500/app" {
+		t.Fatalf("抓取结果为 %v", got)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		path   string
+		update func(c *ScrapeConfig)
+		err    string
+	}{
+		{"筛选后的指标过多", "/metrics", func(c *ScrapeConfig) { c.MaxSamples = 3 }, "筛选后的指标超过 3 个"},
+		{"响应过大", "/metrics", func(c *ScrapeConfig) { c.MaxBody = 10 }, "响应超过 10 字节"},
+		{"状态码不是 200", "/missing", nil, "404"},
+		{"格式无效", "/invalid", nil, "第 1 行"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			samples, err := collector(tc.path, tc.update).Collect(context.Background())
+			if err == nil || !strings.Contains(err.Error(), tc.err) || samples != nil {
+				t.Fatalf("返回 %v, %v，期望包含 %q 的错误", samples, err, tc.err)
+			}
+		})
+	}
+
+	// 筛选后恰好等于上限时不报错
+	if samples, err := collector("/metrics", func(c *ScrapeConfig) { c.MaxSamples = 4 }).Collect(context.Background()); err != nil || len(samples) != 4 {
+		t.Fatalf("达到上限时返回 %d 个指标, %v", len(samples), err)
+	}
+}
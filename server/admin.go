@@ -0,0 +1,671 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"golang.org/x/term"
+)
+
+// offlineBaseURL 直接操作数据目录时接口请求使用的地址，请求不会离开当前进程
+const offlineBaseURL = "http://gonitor.local"
+
+// adminCommands admin 子命令支持的命令及说明
+var adminCommands = []struct {
+	name, usage, help string
+	run               func(ctx context.Context, c *adminClient, args []string, in io.Reader, out io.Writer) error
+}{
+	{"clients", "[-group 分组] [-tag 标签] [-label key=value]", "列出客户端", adminListClients},
+	{"add", "[-group 分组] [-tags a,b] <名称>", "添加客户端，输出客户端 ID", adminAddClient},
+	{"delete", "<ID 或名称>...", "删除客户端", adminDeleteClients},
+	{"rename", "<ID 或名称> <新名称>", "重命名客户端", adminRenameClient},
+	{"token", "-name 名称 [-scope read|write|admin] [-expires 2160h]", "创建个人令牌，输出令牌原文", adminCreateToken},
+	{"export", "[-format json|csv] [-o 文件] [-group 分组] [-tag 标签]", "导出客户端", adminExportClients},
+	{"import", "[-format json|csv] [-dry-run] <文件>", "导入客户端，文件为 - 时从标准输入读取", adminImportClients},
+	{"watch", "[-interval 2s] [-group 分组] [-tag 标签]", "持续输出客户端上报的指标，按 Ctrl+C 退出", adminWatchClients},
+	{"top", "[-interval 2s] [-width 120] [-no-color] [-group 分组] [-tag 标签]", "终端仪表盘：实时显示客户端的使用率，可以排序、筛选并查看单个客户端的历史", adminTop},
+	{"reset-password", "", "重置 -user 指定用户的密码，新密码从标准输入读取，终端中不回显；只能直接操作数据目录", nil},
+}
+
+// runAdmin 执行 admin 子命令：指定 -url 时通过运行中的服务端的接口管理，否则直接操作已停止的服务端的数据目录
+func runAdmin(args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return admin(ctx, args, os.Stdin, os.Stdout)
+}
+
+// admin 解析 admin 子命令的参数并执行命令，in 和 out 为命令的输入和输出
+func admin(ctx context.Context, args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("admin", flag.ContinueOnError)
+	serverURL := fs.String("url", "", "运行中的服务端地址，如 http://localhost:44123；为空时直接操作数据目录")
+	username := fs.String("user", "admin", "用户名：通过接口管理时用于登录，密码从环境变量 "+passwordEnv+" 读取，设置了 "+tokenEnv+" 时使用个人令牌；直接操作数据目录时以该用户的身份执行")
+	dir := fs.String("data", dataDir, "数据目录，不指定 -url 时使用")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "用法: %s admin [-url 地址 | -data 数据目录] [-user 用户名] <命令> [参数]\n\n命令:\n", os.Args[0])
+		for _, cmd := range adminCommands {
+			fmt.Fprintf(fs.Output(), "  %s\n    \t%s\n", strings.TrimSpace(cmd.name+" "+cmd.usage), cmd.help)
+		}
+		fmt.Fprintln(fs.Output(), "\n选项:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("缺少命令")
+	}
+
+	name, args := fs.Arg(0), fs.Args()[1:]
+	if name == "reset-password" {
+		if *serverURL != "" {
+			return errors.New("重置密码需要直接操作数据目录，请停止服务端后去掉 -url 执行")
+		}
+		return adminResetPassword(*dir, *username, in, out)
+	}
+	for _, cmd := range adminCommands {
+		if cmd.name != name || cmd.run == nil {
+			continue
+		}
+		var c *adminClient
+		var err error
+		if *serverURL != "" {
+			c, err = connectServer(strings.TrimSuffix(*serverURL, "/"), *username, os.Getenv(passwordEnv), os.Getenv(tokenEnv))
+		} else {
+			c, err = openDataDir(*dir, *username)
+		}
+		if err != nil {
+			return err
+		}
+		defer c.close()
+		return cmd.run(ctx, c, args, in, out)
+	}
+	fs.Usage()
+	return fmt.Errorf("未知的命令 %s", name)
+}
+
+// adminClient 通过服务端的接口执行管理命令，直接操作数据目录时接口由当前进程内的服务端提供
+type adminClient struct {
+	baseURL string
+	http    *http.Client
+	bearer  bool // 使用个人令牌认证
+	offline bool // 直接操作数据目录
+	close   func()
+}
+
+// bearerTransport 为每个请求附带个人令牌
+type bearerTransport struct {
+	token string
+}
+
+func (b bearerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", "Bearer "+b.token)
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// handlerTransport 在当前进程内处理请求，并附带会话
+type handlerTransport struct {
+	handler http.Handler
+	session string
+}
+
+func (t handlerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.AddCookie(&http.Cookie{Name: "session", Value: t.session})
+	rb := &responseBuffer{header: make(http.Header)}
+	t.handler.ServeHTTP(rb, r)
+	rb.WriteHeader(http.StatusOK)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", rb.status, http.StatusText(rb.status)),
+		StatusCode:    rb.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        rb.header,
+		Body:          io.NopCloser(&rb.body),
+		ContentLength: int64(rb.body.Len()),
+		Request:       r,
+	}, nil
+}
+
+// responseBuffer 在内存中保存处理器的响应，供 handlerTransport 使用
+type responseBuffer struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *responseBuffer) Header() http.Header {
+	return b.header
+}
+
+func (b *responseBuffer) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *responseBuffer) Write(p []byte) (int, error) {
+	b.WriteHeader(http.StatusOK)
+	return b.body.Write(p)
+}
+
+// newAPIClient 返回访问服务端接口的 HTTP 客户端，token 不为空时使用个人令牌，否则先用密码登录
+func newAPIClient(baseURL, username, password, token string) (*http.Client, error) {
+	if token != "" {
+		return &http.Client{Transport: bearerTransport{token}}, nil
+	}
+
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar}
+	body, _ := json.Marshal(map[string]string{"username": username, "password": password})
+	resp, err := client.Post(baseURL+"/api/login", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("连接服务端失败: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("登录失败: %s，请检查用户名和环境变量 %s", resp.Status, passwordEnv)
+	}
+	return client, nil
+}
+
+// connectServer 连接运行中的服务端
+func connectServer(baseURL, username, password, token string) (*adminClient, error) {
+	client, err := newAPIClient(baseURL, username, password, token)
+	if err != nil {
+		return nil, err
+	}
+	return &adminClient{baseURL: baseURL, http: client, bearer: token != "", close: func() {}}, nil
+}
+
+// openDataDir 打开已停止的服务端的数据目录，在当前进程内以 username 的身份提供接口
+func openDataDir(dir, username string) (*adminClient, error) {
+	st, err := openDataStore(dir)
+	if err != nil {
+		return nil, err
+	}
+	s, err := newServer(st, nil)
+	if err != nil {
+		st.Close()
+		return nil, err
+	}
+	closeAll := func() {
+		s.Close(context.Background())
+		st.Close()
+	}
+	if _, err := st.GetUser(username); err != nil {
+		err = userError(st, username, err)
+		closeAll()
+		return nil, err
+	}
+
+	// 会话只在命令执行期间有效，结束时删除
+	session := newToken()
+	now := time.Now()
+	if err := st.SaveSession(session, Session{Username: username, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}); err != nil {
+		closeAll()
+		return nil, err
+	}
+	return &adminClient{
+		baseURL: offlineBaseURL,
+		http:    &http.Client{Transport: handlerTransport{s.Handler(), session}},
+		offline: true,
+		close: func() {
+			st.DeleteSession(session)
+			closeAll()
+		},
+	}, nil
+}
+
+// openDataStore 打开数据目录中的数据库，不会创建新的数据库
+func openDataStore(dir string) (*boltStore, error) {
+	path := filepath.Join(dir, dbFile)
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("数据目录 %s 中没有数据库: %w", dir, err)
+	}
+	st, err := openBoltStore(path)
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, errors.New("数据库正在被服务端使用，请使用 -url 通过接口管理")
+	}
+	return st, err
+}
+
+// userError 用户不存在时列出已有的用户
+func userError(st Store, username string, err error) error {
+	if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	users, _ := st.ListUsers()
+	names := make([]string, len(users))
+	for i, u := range users {
+		names[i] = u.Username
+	}
+	return fmt.Errorf("用户 %s 不存在，已有的用户: %s", username, strings.Join(names, ", "))
+}
+
+// do 发送请求，状态码不是 2xx 时返回响应中的错误信息
+func (c *adminClient) do(ctx context.Context, method, path, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("连接服务端失败: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		return nil, responseError(resp)
+	}
+	return resp, nil
+}
+
+// call 以 JSON 发送请求，v 不为 nil 时解析 JSON 响应
+func (c *adminClient) call(ctx context.Context, method, path string, body, v any) error {
+	var r io.Reader
+	contentType := ""
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r, contentType = bytes.NewReader(data), "application/json"
+	}
+	resp, err := c.do(ctx, method, path, contentType, r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// responseError 读取错误响应，v1 接口的错误为 JSON，旧接口的错误为纯文本
+func responseError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	msg := strings.TrimSpace(string(data))
+	var body struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(data, &body) == nil && body.Error.Message != "" {
+		msg = body.Error.Message
+	}
+	if msg == "" {
+		return fmt.Errorf("服务端返回 %s", resp.Status)
+	}
+	return fmt.Errorf("服务端返回 %s: %s", resp.Status, msg)
+}
+
+// resolveClient 按 ID 查找客户端，找不到时按名称查找，名称必须唯一
+func (c *adminClient) resolveClient(ctx context.Context, ref string) (Client, error) {
+	var clients []Client
+	if err := c.call(ctx, http.MethodGet, apiV1Prefix+"/clients", nil, &clients); err != nil {
+		return Client{}, err
+	}
+	var matched []Client
+	for _, client := range clients {
+		if client.ID == ref {
+			return client, nil
+		}
+		if client.Name == ref {
+			matched = append(matched, client)
+		}
+	}
+	switch len(matched) {
+	case 0:
+		return Client{}, fmt.Errorf("客户端 %s 不存在", ref)
+	case 1:
+		return matched[0], nil
+	}
+	ids := make([]string, len(matched))
+	for i, client := range matched {
+		ids[i] = client.ID
+	}
+	return Client{}, fmt.Errorf("有 %d 个客户端名为 %s，请使用 ID: %s", len(matched), ref, strings.Join(ids, ", "))
+}
+
+// filterFlags 添加客户端筛选参数，返回的函数生成对应的查询参数
+func filterFlags(fs *flag.FlagSet) func() url.Values {
+	group := fs.String("group", "", "只包含该分组及其下级分组中的客户端")
+	tag := fs.String("tag", "", "只包含带有这些标签的客户端，以逗号分隔")
+	label := fs.String("label", "", "只包含带有这些客户端标签的客户端，格式为 key=value，以逗号分隔")
+	return func() url.Values {
+		query := url.Values{}
+		for name, value := range map[string]string{"group": *group, "tag": *tag, "label": *label} {
+			if value != "" {
+				query.Set(name, value)
+			}
+		}
+		return query
+	}
+}
+
+// parseArgs 解析命令的参数并检查位置参数的数量，max 小于 0 表示不限
+func parseArgs(fs *flag.FlagSet, args []string, min, max int) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < min || (max >= 0 && fs.NArg() > max) {
+		fs.Usage()
+		return fmt.Errorf("%s 的参数数量不正确", fs.Name())
+	}
+	return nil
+}
+
+// adminListClients 以表格列出客户端
+func adminListClients(ctx context.Context, c *adminClient, args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("clients", flag.ContinueOnError)
+	query := filterFlags(fs)
+	if err := parseArgs(fs, args, 0, 0); err != nil {
+		return err
+	}
+	var clients []Client
+	if err := c.call(ctx, http.MethodGet, apiV1Prefix+"/clients?"+query().Encode(), nil, &clients); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\t名称\t分组\t标签\t状态\tCPU\t内存\t磁盘")
+	for _, client := range clients {
+		status, usage := "离线", "-\t-\t-"
+		if client.Connected {
+			status = "在线"
+			usage = fmt.Sprintf("%.1f%%\t%.1f%%\t%.1f%%", client.CPU, client.Memory, client.DiskUsage)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", client.ID, client.Name, orDash(client.Group), orDash(strings.Join(client.Tags, ",")), status, usage)
+	}
+	return tw.Flush()
+}
+
+// orDash 空字符串显示为 -，保持表格对齐
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// adminAddClient 添加客户端并输出其 ID
+func adminAddClient(ctx context.Context, c *adminClient, args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("add", flag.ContinueOnError)
+	group := fs.String("group", "", "分组路径，如 prod/web")
+	tags := fs.String("tags", "", "标签，以逗号分隔")
+	if err := parseArgs(fs, args, 1, 1); err != nil {
+		return err
+	}
+	body := map[string]any{"name": fs.Arg(0), "group": *group}
+	if *tags != "" {
+		body["tags"] = strings.Split(*tags, ",")
+	}
+	var client Client
+	if err := c.call(ctx, http.MethodPost, apiV1Prefix+"/clients", body, &client); err != nil {
+		return err
+	}
+	fmt.Fprintln(out, client.ID)
+	return nil
+}
+
+// adminDeleteClients 删除客户端，遇到错误时停止
+func adminDeleteClients(ctx context.Context, c *adminClient, args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("delete", flag.ContinueOnError)
+	if err := parseArgs(fs, args, 1, -1); err != nil {
+		return err
+	}
+	for _, ref := range fs.Args() {
+		client, err := c.resolveClient(ctx, ref)
+		if err != nil {
+			return err
+		}
+		if err := c.call(ctx, http.MethodDelete, apiV1Prefix+"/clients/"+client.ID, nil, nil); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "已删除 %s (%s)\n", client.Name, client.ID)
+	}
+	return nil
+}
+
+// adminRenameClient 重命名客户端
+func adminRenameClient(ctx context.Context, c *adminClient, args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("rename", flag.ContinueOnError)
+	if err := parseArgs(fs, args, 2, 2); err != nil {
+		return err
+	}
+	client, err := c.resolveClient(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err := c.call(ctx, http.MethodPatch, apiV1Prefix+"/clients/"+client.ID, map[string]string{"name": fs.Arg(1)}, &client); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "已重命名为 %s (%s)\n", client.Name, client.ID)
+	return nil
+}
+
+// adminCreateToken 创建个人令牌并输出令牌原文，令牌的管理需要登录会话，不能使用个人令牌
+func adminCreateToken(ctx context.Context, c *adminClient, args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("token", flag.ContinueOnError)
+	name := fs.String("name", "", "令牌名称，如 ci-deploy")
+	scope := fs.String("scope", scopeRead, "令牌的权限，read、write 或 admin")
+	expires := fs.Duration("expires", 90*24*time.Hour, "有效期，0 表示永不过期")
+	if err := parseArgs(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if c.bearer {
+		return fmt.Errorf("个人令牌不能创建新的令牌，请取消环境变量 %s 并使用密码登录", tokenEnv)
+	}
+	body := map[string]any{"name": *name, "scopes": []string{*scope}}
+	if *expires > 0 {
+		body["expiresAt"] = time.Now().Add(*expires)
+	}
+	var result struct {
+		Token string `json:"token"`
+	}
+	if err := c.call(ctx, http.MethodPost, "/api/tokens/create", body, &result); err != nil {
+		return err
+	}
+	fmt.Fprintln(out, result.Token)
+	return nil
+}
+
+// adminExportClients 导出客户端到文件或标准输出
+func adminExportClients(ctx context.Context, c *adminClient, args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", "json", "导出格式，json 或 csv")
+	output := fs.String("o", "", "输出文件，为空时输出到标准输出")
+	query := filterFlags(fs)
+	if err := parseArgs(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	params := query()
+	params.Set("format", *format)
+	resp, err := c.do(ctx, http.MethodGet, "/api/clients/export?"+params.Encode(), "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if *output == "" {
+		_, err := io.Copy(out, resp.Body)
+		return err
+	}
+	return writeFile(*output, resp.Body)
+}
+
+// adminImportClients 导入客户端并输出修改的内容
+func adminImportClients(ctx context.Context, c *adminClient, args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := fs.String("format", "", "文件格式，json 或 csv，默认按扩展名判断")
+	dryRun := fs.Bool("dry-run", false, "只显示将要进行的修改，不做任何修改")
+	if err := parseArgs(fs, args, 1, 1); err != nil {
+		return err
+	}
+
+	path := fs.Arg(0)
+	body := in
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		body = f
+	}
+	if *format == "" {
+		*format = "json"
+		if strings.EqualFold(filepath.Ext(path), ".csv") {
+			*format = "csv"
+		}
+	}
+
+	query := url.Values{"format": {*format}, "dryRun": {fmt.Sprint(*dryRun)}}
+	resp, err := c.do(ctx, http.MethodPost, "/api/clients/import?"+query.Encode(), "application/octet-stream", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var result ImportResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+
+	for _, diff := range result.Diffs {
+		if diff.Action == "create" {
+			fmt.Fprintf(out, "新建 %s (%s)\n", diff.Name, diff.ID)
+			continue
+		}
+		fmt.Fprintf(out, "修改 %s (%s)\n", diff.Name, diff.ID)
+		for _, change := range diff.Changes {
+			fmt.Fprintf(out, "  %s: %q -> %q\n", change.Field, change.Old, change.New)
+		}
+	}
+	summary := "已导入"
+	if result.DryRun {
+		summary = "试运行，没有做任何修改"
+	}
+	fmt.Fprintf(out, "%s：新建 %d 个，修改 %d 个，%d 个没有变化\n", summary, result.Created, result.Updated, result.Unchanged)
+	return nil
+}
+
+// adminWatchClients 定时读取客户端，输出有新数据或状态变化的客户端，直到 ctx 取消
+func adminWatchClients(ctx context.Context, c *adminClient, args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	interval := fs.Duration("interval", 2*time.Second, "读取间隔")
+	query := filterFlags(fs)
+	if err := parseArgs(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if c.offline {
+		return errors.New("实时指标需要使用 -url 连接运行中的服务端")
+	}
+	if *interval <= 0 {
+		return errors.New("读取间隔必须大于 0")
+	}
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	seen := make(map[string]Client)
+	for {
+		var clients []Client
+		if err := c.call(ctx, http.MethodGet, apiV1Prefix+"/clients?"+query().Encode(), nil, &clients); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		for _, client := range clients {
+			last, ok := seen[client.ID]
+			if ok && last.Connected == client.Connected && last.LastSeen.Equal(client.LastSeen) {
+				continue
+			}
+			seen[client.ID] = client
+			fmt.Fprintln(out, formatClientLine(&client))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// formatClientLine 返回客户端指标的一行文本
+func formatClientLine(c *Client) string {
+	if !c.Connected {
+		return fmt.Sprintf("%s  %s  离线", time.Now().Format("15:04:05"), c.Name)
+	}
+	return fmt.Sprintf("%s  %s  CPU %.1f%%  内存 %.1f%%  磁盘 %.1f%%  读 %s  写 %s  上传 %s  下载 %s",
+		c.LastSeen.Local().Format("15:04:05"), c.Name, c.CPU, c.Memory, c.DiskUsage,
+		formatRate(c.DiskReadSpeed), formatRate(c.DiskWriteSpeed), formatRate(c.UploadSpeed), formatRate(c.DownloadSpeed))
+}
+
+// formatRate 格式化以 KB/s 为单位的速度
+func formatRate(kbps float64) string {
+	if kbps >= 1024 {
+		return fmt.Sprintf("%.1f MB/s", kbps/1024)
+	}
+	return fmt.Sprintf("%.1f KB/s", kbps)
+}
+
+// readPassword 读取一行密码，in 为终端时不回显输入的内容
+func readPassword(in io.Reader) (string, error) {
+	if f, ok := in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		password, err := term.ReadPassword(int(f.Fd()))
+		return string(password), err
+	}
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// adminResetPassword 重置用户的密码，用于忘记密码时恢复访问，该用户已有的会话全部失效
+func adminResetPassword(dir, username string, in io.Reader, out io.Writer) error {
+	fmt.Fprintf(out, "请输入用户 %s 的新密码: ", username)
+	password, err := readPassword(in)
+	fmt.Fprintln(out)
+	if err != nil {
+		return errors.New("没有读取到新密码")
+	}
+	if password == "" {
+		return errors.New("新密码不能为空")
+	}
+
+	st, err := openDataStore(dir)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	if err := st.ResetPassword(username, hashPassword(password)); err != nil {
+		return userError(st, username, err)
+	}
+	fmt.Fprintf(out, "已重置用户 %s 的密码，该用户已登录的会话全部失效\n", username)
+	return nil
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runAdminCmd 执行 admin 子命令并返回输出，出错时测试失败
+func runAdminCmd(t *testing.T, in string, args ...string) string {
+	t.Helper()
+	var out strings.Builder
+	if err := admin(context.Background(), args, strings.NewReader(in), &out); err != nil {
+		t.Fatalf("admin %v 出错: %v", args, err)
+	}
+	return out.String()
+}
+
+func TestAdminOverAPI(t *testing.T) {
+	server, ts := newTestServer(t)
+	browser := loginClient(t, ts)
+	_, token := createToken(t, browser, ts.URL, "cli", scopeWrite, time.Time{})
+	t.Setenv(tokenEnv, token)
+
+	id := strings.TrimSpace(runAdminCmd(t, "", "-url", ts.URL, "add", "-group", "prod", "-tags", "b,a", "web"))
+	c, ok := server.clients.Snapshot().Get(id)
+	if !ok || c.Name != "web" || c.Group != "prod" || strings.Join(c.Tags, ",") != "a,b" {
+		t.Fatalf("添加的客户端为 %+v", c)
+	}
+	other := strings.TrimSpace(runAdminCmd(t, "", "-url", ts.URL, "add", "db"))
+
+	if out := runAdminCmd(t, "", "-url", ts.URL, "clients", "-group", "prod"); !strings.Contains(out, id) || strings.Contains(out, other) {
+		t.Fatalf("按分组列出的客户端为:\n%s", out)
+	}
+
+	// 可以使用名称代替 ID
+	runAdminCmd(t, "", "-url", ts.URL, "rename", "db", "db-1")
+	if c, _ := server.clients.Snapshot().Get(other); c.Name != "db-1" {
+		t.Fatalf("重命名后的客户端为 %+v", c)
+	}
+
+	// 导出后修改再导入
+	file := filepath.Join(t.TempDir(), "clients.csv")
+	runAdminCmd(t, "", "-url", ts.URL, "export", "-format", "csv", "-o", file)
+	data, err := os.ReadFile(file)
+	if err != nil || !strings.Contains(string(data), "db-1") {
+		t.Fatalf("导出的文件为 %q, %v", data, err)
+	}
+	os.WriteFile(file, []byte(strings.Replace(string(data), "db-1", "db-2", 1)), 0600)
+	out := runAdminCmd(t, "", "-url", ts.URL, "import", "-dry-run", file)
+	if !strings.Contains(out, `"db-1" -> "db-2"`) || !strings.Contains(out, "修改 1 个") {
+		t.Fatalf("试运行的输出为:\n%s", out)
+	}
+	if c, _ := server.clients.Snapshot().Get(other); c.Name != "db-1" {
+		t.Fatalf("试运行修改了客户端 %+v", c)
+	}
+	runAdminCmd(t, "", "-url", ts.URL, "import", file)
+	if c, _ := server.clients.Snapshot().Get(other); c.Name != "db-2" {
+		t.Fatalf("导入后的客户端为 %+v", c)
+	}
+
+	runAdminCmd(t, "", "-url", ts.URL, "delete", "db-2")
+	if err := admin(context.Background(), []string{"-url", ts.URL, "delete", "db-2"}, nil, io.Discard); err == nil {
+		t.Fatal("删除不存在的客户端应返回错误")
+	}
+
+	// 令牌不能创建令牌，使用密码登录后可以
+	if err := admin(context.Background(), []string{"-url", ts.URL, "token", "-name", "x"}, nil, io.Discard); err == nil {
+		t.Fatal("使用个人令牌创建令牌应返回错误")
+	}
+	t.Setenv(tokenEnv, "")
+	t.Setenv(passwordEnv, "admin")
+	if out := runAdminCmd(t, "", "-url", ts.URL, "token", "-name", "ci", "-scope", scopeRead); !strings.HasPrefix(out, apiTokenPrefix) {
+		t.Fatalf("创建的令牌为 %q", out)
+	}
+
+	// 持续输出客户端上报的指标
+	server.clients.UpdateMetrics(id, Metrics{CPU: 12.5, UploadSpeed: 2048})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r, w := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- admin(ctx, []string{"-url", ts.URL, "watch", "-interval", "10ms", "-group", "prod"}, nil, w)
+		w.Close()
+	}()
+	lines := bufio.NewScanner(r)
+	if !lines.Scan() || !strings.Contains(lines.Text(), "web") || !strings.Contains(lines.Text(), "CPU 12.5%") || !strings.Contains(lines.Text(), "上传 2.0 MB/s") {
+		t.Fatalf("输出的指标为 %q", lines.Text())
+	}
+	cancel()
+	go io.Copy(io.Discard, r)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAdminDataDir(t *testing.T) {
+	dir := t.TempDir()
+	st, err := openBoltStore(filepath.Join(dir, dbFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SaveUser(User{Username: "admin", PasswordHash: hashPassword("admin")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SaveSession("old", Session{Username: "admin", ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 服务端运行时不能直接操作数据目录
+	if err := admin(context.Background(), []string{"-data", dir, "clients"}, nil, io.Discard); err == nil || !strings.Contains(err.Error(), "-url") {
+		t.Fatalf("数据库被占用时的错误为 %v", err)
+	}
+	st.Close()
+
+	id := strings.TrimSpace(runAdminCmd(t, "", "-data", dir, "add", "web"))
+	if out := runAdminCmd(t, "", "-data", dir, "clients"); !strings.Contains(out, id) || !strings.Contains(out, "离线") {
+		t.Fatalf("列出的客户端为:\n%s", out)
+	}
+	if out := runAdminCmd(t, "", "-data", dir, "token", "-name", "ci"); !strings.HasPrefix(out, apiTokenPrefix) {
+		t.Fatalf("创建的令牌为 %q", out)
+	}
+	if err := admin(context.Background(), []string{"-data", dir, "watch"}, nil, io.Discard); err == nil {
+		t.Fatal("直接操作数据目录时不能输出实时指标")
+	}
+	if err := admin(context.Background(), []string{"-data", dir, "-user", "root", "clients"}, nil, io.Discard); err == nil || !strings.Contains(err.Error(), "admin") {
+		t.Fatalf("用户不存在时的错误为 %v", err)
+	}
+
+	runAdminCmd(t, "secret\n", "-data", dir, "reset-password")
+	if err := admin(context.Background(), []string{"-data", dir, "reset-password"}, strings.NewReader("\n"), io.Discard); err == nil {
+		t.Fatal("空密码应返回错误")
+	}
+	if err := admin(context.Background(), []string{"-url", "http://localhost:1", "reset-password"}, strings.NewReader("x\n"), io.Discard); err == nil {
+		t.Fatal("通过接口重置密码应返回错误")
+	}
+
+	st, err = openBoltStore(filepath.Join(dir, dbFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	if user, err := st.GetUser("admin"); err != nil || !checkPassword("secret", user.PasswordHash) {
+		t.Fatalf("重置后的用户为 %+v, %v", user, err)
+	}
+	if _, err := st.GetSession("old"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("重置密码后旧会话应失效: %v", err)
+	}
+	if clients, err := st.ListClients(); err != nil || len(clients) != 1 {
+		t.Fatalf("保存的客户端为 %v, %v", clients, err)
+	}
+	if tokens, err := st.ListAPITokens(); err != nil || len(tokens) != 1 {
+		t.Fatalf("保存的令牌为 %v, %v", tokens, err)
+	}
+}
+
+func TestHandlerTransport(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie("session")
+		switch {
+		case err != nil || cookie.Value != "s1":
+			http.Error(w, "未授权", http.StatusUnauthorized)
+		case r.URL.Path == "/empty":
+		default:
+			w.Write([]byte("ok"))
+		}
+	})
+	client := &http.Client{Transport: handlerTransport{handler, "s1"}}
+	for _, tc := range []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/", http.StatusOK, "ok"},
+		// 处理器没有写入任何内容时为 200
+		{"/empty", http.StatusOK, ""},
+	} {
+		resp, err := client.Get(offlineBaseURL + tc.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tc.status || string(body) != tc.body || resp.Status != "200 OK" {
+			t.Fatalf("GET %s 返回 %s %q", tc.path, resp.Status, body)
+		}
+	}
+
+	client.Transport = handlerTransport{handler, "other"}
+	resp, err := client.Get(offlineBaseURL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("Content-Type") == "" || resp.ContentLength != int64(len("未授权\n")) {
+		t.Fatalf("会话无效时返回 %s %v", resp.Status, resp.Header)
+	}
+}
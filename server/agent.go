@@ -0,0 +1,117 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// handleClientConnection 处理客户端WebSocket连接
+func (s *Server) handleClientConnection(w http.ResponseWriter, r *http.Request) {
+	clientID := r.URL.Query().Get("id")
+	if clientID == "" {
+		http.Error(w, "缺少客户端ID", http.StatusBadRequest)
+		return
+	}
+
+	// 检查客户端ID是否存在
+	client, exists := s.clients.Snapshot().Get(clientID)
+
+	if !exists {
+		http.Error(w, "未注册的客户端ID", http.StatusBadRequest)
+		return
+	}
+
+	// 自助注册的客户端需要携带注册时分配的密钥
+	if !client.checkSecret(r.Header.Get(secretHeader)) {
+		http.Error(w, "客户端密钥无效", http.StatusUnauthorized)
+		return
+	}
+
+	// 客户端声明的标签，无效的标签只记录日志，不影响连接
+	labels, err := parseAgentLabels(r.Header.Get(labelsHeader))
+	if err != nil {
+		log.Printf("客户端 %s 声明的标签有误: %v", clientID, err)
+	}
+
+	// 升级HTTP连接为WebSocket
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("升级WebSocket连接失败: %v", err)
+		return
+	}
+
+	// 更新客户端连接信息
+	oldConn, err := s.clients.Connect(clientID, conn, labels)
+	if err != nil {
+		// 客户端在升级连接期间被删除
+		conn.Close()
+		return
+	}
+	if oldConn != nil {
+		oldConn.Close()
+	}
+
+	// log.Printf("客户端 %s 已连接", clientID)
+
+	// 启动一个goroutine处理WebSocket消息
+	s.agentConns.Add(1)
+	go s.handleClientMessages(conn, clientID)
+}
+
+// handleClientMessages 处理来自客户端的WebSocket消息
+func (s *Server) handleClientMessages(conn *websocket.Conn, clientID string) {
+	defer s.agentConns.Done()
+	defer func() {
+		conn.Close()
+		s.clients.Disconnect(clientID, conn)
+		// log.Printf("客户端 %s 连接已关闭", clientID)
+	}()
+
+	// 收到任何消息或心跳回应都会延长读取期限，超过 s.pongTimeout 没有动静即视为连接断开
+	conn.SetReadDeadline(time.Now().Add(s.pongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(s.pongTimeout))
+	})
+
+	// 定时发送心跳，直到消息处理结束
+	done := make(chan struct{})
+	defer close(done)
+	go s.pingClient(conn, done)
+
+	for {
+		var metrics Metrics
+		if err := conn.ReadJSON(&metrics); err != nil {
+			// log.Printf("从客户端 %s 读取数据失败: %v", clientID, err)
+			break
+		}
+		conn.SetReadDeadline(time.Now().Add(s.pongTimeout))
+
+		// 按合并后的状态记录历史，未发送的其他指标沿用上一次的值
+		if c, ok := s.clients.UpdateMetrics(clientID, metrics); ok {
+			s.history.Record(clientID, c.metrics(), time.Now())
+			if len(metrics.Checks) > 0 {
+				s.recordChecks(clientID, metrics.Checks)
+			}
+		}
+	}
+}
+
+// pingClient 定时向客户端发送心跳，发送失败时关闭连接使读取立即返回
+func (s *Server) pingClient(conn *websocket.Conn, done <-chan struct{}) {
+	ticker := time.NewTicker(s.pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				conn.Close()
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// alertInterval 检查告警规则的间隔
+const alertInterval = 10 * time.Second
+
+// alertTarget 表示告警规则可以作用的对象，即客户端或服务端探测
+type alertTarget struct {
+	ID     string
+	Name   string
+	Values map[string]float64 // 指标名称 -> 当前值，缺少的指标视为不满足条件
+	Labels map[string]string  // 客户端的全部标签，服务端探测没有标签
+	// 处于维护窗口中，不触发新的告警，已触发的告警保持不变
+	Suppressed bool
+}
+
+// alertState 记录一条规则在一个对象上的状态
+type alertState struct {
+	since  time.Time // 条件开始满足的时间
+	firing bool
+}
+
+// alertEngine 定期检查告警规则，条件持续满足 Duration 秒后触发，不再满足时恢复
+// 状态只由调用 evaluate 的协程访问
+type alertEngine struct {
+	states map[string]*alertState // 键为 <规则ID>/<对象ID>
+}
+
+func newAlertEngine() *alertEngine {
+	return &alertEngine{states: make(map[string]*alertState)}
+}
+
+// normalizeAlertRule 校验告警规则
+func normalizeAlertRule(rule *AlertRule) error {
+	if rule.Name == "" || rule.Metric == "" {
+		return errors.New("名称和指标不能为空")
+	}
+	if _, err := compare(0, rule.Operator, 0); err != nil {
+		return err
+	}
+	if rule.Duration < 0 {
+		return errors.New("持续时间不能为负数")
+	}
+	return validateLabelSelector(rule.Labels)
+}
+
+// listAlertRules 按创建时间返回所有告警规则
+func (s *Server) listAlertRules() ([]AlertRule, error) {
+	rules, err := s.store.ListAlertRules()
+	if err != nil {
+		return nil, fmt.Errorf("读取告警规则出错: %w", err)
+	}
+	if rules == nil {
+		rules = []AlertRule{}
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].CreatedAt.Before(rules[j].CreatedAt) })
+	return rules, nil
+}
+
+// alertRule 返回指定的告警规则，不存在时返回 404 错误
+func (s *Server) alertRule(id string) (AlertRule, error) {
+	rules, err := s.store.ListAlertRules()
+	if err != nil {
+		return AlertRule{}, fmt.Errorf("读取告警规则出错: %w", err)
+	}
+	i := slices.IndexFunc(rules, func(rule AlertRule) bool { return rule.ID == id })
+	if i < 0 {
+		return AlertRule{}, errNotFound("告警规则不存在")
+	}
+	return rules[i], nil
+}
+
+// saveAlertRule 校验并保存告警规则，ID 为空时添加，否则修改已有的规则并保留创建时间
+func (s *Server) saveAlertRule(rule AlertRule) (AlertRule, error) {
+	if err := normalizeAlertRule(&rule); err != nil {
+		return rule, errInvalid(err.Error())
+	}
+	if rule.ID == "" {
+		rule.ID = newID()
+		rule.CreatedAt = time.Now()
+	} else {
+		old, err := s.alertRule(rule.ID)
+		if err != nil {
+			return rule, err
+		}
+		rule.CreatedAt = old.CreatedAt
+	}
+	if err := s.store.SaveAlertRule(rule); err != nil {
+		return rule, fmt.Errorf("保存告警规则出错: %w", err)
+	}
+	return rule, nil
+}
+
+// compare 按运算符比较当前值和阈值
+func compare(value float64, operator string, threshold float64) (bool, error) {
+	switch operator {
+	case ">":
+		return value > threshold, nil
+	case ">=":
+		return value >= threshold, nil
+	case "<":
+		return value < threshold, nil
+	case "<=":
+		return value <= threshold, nil
+	}
+	return false, fmt.Errorf("比较运算符 %q 无效，可选 >、>=、<、<=", operator)
+}
+
+// evaluate 用当前的指标检查所有启用的规则，返回状态发生变化产生的告警事件
+func (e *alertEngine) evaluate(rules []AlertRule, targets []alertTarget, now time.Time) []AlertEvent {
+	var events []AlertEvent
+	seen := make(map[string]bool)
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+		for _, target := range targets {
+			if rule.ClientID != "" && rule.ClientID != target.ID {
+				continue
+			}
+			if !labelsMatch(target.Labels, rule.Labels) {
+				continue
+			}
+			key := rule.ID + "/" + target.ID
+			seen[key] = true
+
+			st := e.states[key]
+			if target.Suppressed {
+				// 维护结束后重新计算持续时间，给对象留出恢复的时间
+				if st != nil && !st.firing {
+					delete(e.states, key)
+				}
+				continue
+			}
+
+			value, ok := target.Values[rule.Metric]
+			matched := false
+			if ok {
+				matched, _ = compare(value, rule.Operator, rule.Threshold)
+			}
+			if !matched {
+				if st != nil && st.firing {
+					events = append(events, AlertEvent{
+						RuleID:   rule.ID,
+						ClientID: target.ID,
+						State:    "resolved",
+						Value:    value,
+						Message:  fmt.Sprintf("%s: %s 的 %s 已恢复", rule.Name, target.Name, rule.Metric),
+						Time:     now,
+					})
+				}
+				delete(e.states, key)
+				continue
+			}
+			if st == nil {
+				st = &alertState{since: now}
+				e.states[key] = st
+			}
+			if !st.firing && now.Sub(st.since) >= time.Duration(rule.Duration)*time.Second {
+				st.firing = true
+				events = append(events, AlertEvent{
+					RuleID:   rule.ID,
+					ClientID: target.ID,
+					State:    "firing",
+					Value:    value,
+					Message: fmt.Sprintf("%s: %s 的 %s 为 %s（%s %s）", rule.Name, target.Name, rule.Metric,
+						formatFloat(value), rule.Operator, formatFloat(rule.Threshold)),
+					Time: now,
+				})
+			}
+		}
+	}
+
+	// 规则被删除、停用，对象被删除或标签不再匹配时，正在触发的告警视为恢复
+	for key, st := range e.states {
+		if seen[key] {
+			continue
+		}
+		if st.firing {
+			ruleID, targetID, _ := strings.Cut(key, "/")
+			events = append(events, AlertEvent{
+				RuleID:   ruleID,
+				ClientID: targetID,
+				State:    "resolved",
+				Message:  "告警规则已删除或停用，或监控对象已删除或不再匹配",
+				Time:     now,
+			})
+		}
+		delete(e.states, key)
+	}
+	return events
+}
+
+// formatFloat 以最短的形式格式化数值
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// alertTargets 返回所有客户端和服务端探测的当前指标
+// 离线客户端只有 connected 指标，便于针对离线设置告警
+// 读取维护窗口失败时不抑制告警
+func (s *Server) alertTargets(now time.Time) []alertTarget {
+	maintenances, err := s.activeMaintenances(now)
+	if err != nil {
+		log.Print(err)
+	}
+	suppressed := func(id, group string) bool {
+		return slices.ContainsFunc(maintenances, func(m Maintenance) bool { return m.covers(id, group) })
+	}
+
+	var targets []alertTarget
+	for _, c := range s.clients.Snapshot().List() {
+		values := map[string]float64{"connected": 0}
+		if c.Connected {
+			values = c.metrics().values()
+			values["connected"] = 1
+		}
+		targets = append(targets, alertTarget{
+			ID:         c.ID,
+			Name:       c.Name,
+			Values:     values,
+			Labels:     c.allLabels(),
+			Suppressed: suppressed(c.ID, c.Group),
+		})
+	}
+	for _, m := range s.monitors.List() {
+		if m.Latest == nil {
+			continue
+		}
+		targets = append(targets, alertTarget{
+			ID:         m.ID,
+			Name:       m.Name,
+			Values:     monitorValues(*m.Latest, now),
+			Suppressed: suppressed(m.ID, ""),
+		})
+	}
+	return targets
+}
+
+// evaluateAlerts 定期检查告警规则并保存产生的告警事件
+func (s *Server) evaluateAlerts(ctx context.Context) {
+	ticker := time.NewTicker(alertInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.checkAlerts(now)
+		}
+	}
+}
+
+// checkAlerts 执行一轮告警检查
+func (s *Server) checkAlerts(now time.Time) {
+	rules, err := s.store.ListAlertRules()
+	if err != nil {
+		log.Printf("读取告警规则出错: %v", err)
+		return
+	}
+	for _, event := range s.alerts.evaluate(rules, s.alertTargets(now), now) {
+		if _, err := s.store.AppendAlertEvent(event); err != nil {
+			log.Printf("保存告警事件出错: %v", err)
+		}
+		if event.State == "firing" {
+			log.Printf("告警触发: %s", event.Message)
+		} else {
+			log.Printf("告警恢复: %s", event.Message)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAlertEngineDuration(t *testing.T) {
+	e := newAlertEngine()
+	rule := AlertRule{ID: "r", Name: "CPU 过高", Metric: "cpu", Operator: ">", Threshold: 80, Duration: 30, Enabled: true}
+	target := func(cpu float64) []alertTarget {
+		return []alertTarget{{ID: "c", Name: "web", Values: map[string]float64{"cpu": cpu}}}
+	}
+
+	now := time.Now()
+	if events := e.evaluate([]AlertRule{rule}, target(90), now); len(events) != 0 {
+		t.Fatalf("条件刚满足时不应触发: %+v", events)
+	}
+	// 中途恢复后重新计时
+	e.evaluate([]AlertRule{rule}, target(50), now.Add(10*time.Second))
+	if events := e.evaluate([]AlertRule{rule}, target(90), now.Add(20*time.Second)); len(events) != 0 {
+		t.Fatalf("重新计时后不应立即触发: %+v", events)
+	}
+	events := e.evaluate([]AlertRule{rule}, target(95), now.Add(50*time.Second))
+	if len(events) != 1 || events[0].State != "firing" || events[0].Value != 95 || events[0].ClientID != "c" {
+		t.Fatalf("告警事件为 %+v", events)
+	}
+	if events := e.evaluate([]AlertRule{rule}, target(95), now.Add(60*time.Second)); len(events) != 0 {
+		t.Fatalf("持续触发时不应重复产生事件: %+v", events)
+	}
+	events = e.evaluate([]AlertRule{rule}, target(40), now.Add(70*time.Second))
+	if len(events) != 1 || events[0].State != "resolved" {
+		t.Fatalf("告警事件为 %+v", events)
+	}
+
+	// 正在触发的规则被停用时视为恢复
+	rule.Duration = 0
+	if events := e.evaluate([]AlertRule{rule}, target(90), now.Add(80*time.Second)); len(events) != 1 {
+		t.Fatalf("告警事件为 %+v", events)
+	}
+	rule.Enabled = false
+	events = e.evaluate([]AlertRule{rule}, target(90), now.Add(90*time.Second))
+	if len(events) != 1 || events[0].State != "resolved" || events[0].RuleID != "r" || events[0].ClientID != "c" {
+		t.Fatalf("告警事件为 %+v", events)
+	}
+}
+
+func TestAlertRulesAPI(t *testing.T) {
+	server, ts := newTestServer(t)
+	admin := loginClient(t, ts)
+	id := addClient(t, admin, ts, "a")
+
+	mustPost(t, admin, ts.URL+"/api/alerts/rules/save", AlertRule{Name: "x", Metric: "cpu", Operator: "!="}, http.StatusBadRequest)
+	rule := AlertRule{Name: "离线", ClientID: id, Metric: "connected", Operator: "<", Threshold: 1, Enabled: true}
+	rule.ID = mustPost(t, admin, ts.URL+"/api/alerts/rules/save", rule, http.StatusOK)["id"]
+
+	var rules []AlertRule
+	mustGet(t, admin, ts.URL+"/api/alerts/rules", &rules)
+	if len(rules) != 1 || rules[0].ID != rule.ID || rules[0].CreatedAt.IsZero() {
+		t.Fatalf("告警规则为 %+v", rules)
+	}
+
+	// 从未连接过的客户端处于离线状态
+	server.checkAlerts(time.Now())
+	var events []AlertEvent
+	mustGet(t, admin, ts.URL+"/api/alerts/events", &events)
+	if len(events) != 1 || events[0].State != "firing" || events[0].ClientID != id {
+		t.Fatalf("告警事件为 %+v", events)
+	}
+
+	// 连接后恢复
+	conn := dialAgent(t, ts, id)
+	defer conn.Close()
+	waitFor(t, "客户端上线", func() bool {
+		c, _ := server.clients.Snapshot().Get(id)
+		return c.Connected
+	})
+	server.checkAlerts(time.Now())
+	mustGet(t, admin, ts.URL+"/api/alerts/events", &events)
+	if len(events) != 2 || events[0].State != "resolved" {
+		t.Fatalf("告警事件为 %+v", events)
+	}
+
+	mustPost(t, admin, ts.URL+"/api/alerts/rules/delete", map[string]string{"id": rule.ID}, http.StatusOK)
+	mustGet(t, admin, ts.URL+"/api/alerts/rules", &rules)
+	if len(rules) != 0 {
+		t.Fatalf("删除后仍有告警规则 %+v", rules)
+	}
+}
+
+func TestMonitorFeedsAlerts(t *testing.T) {
+	server, ts := newTestServer(t)
+	startMonitors(t, server)
+	admin := loginClient(t, ts)
+
+	id := mustPost(t, admin, ts.URL+"/api/monitors/save", Monitor{Name: "db", Type: "tcp", Target: closedPort(t)}, http.StatusOK)["id"]
+	mustPost(t, admin, ts.URL+"/api/alerts/rules/save", AlertRule{Name: "站点故障", Metric: "up", Operator: "<", Threshold: 1, Enabled: true}, http.StatusOK)
+	waitFor(t, "第一次探测", func() bool {
+		m, _ := server.monitors.Get(id)
+		return m.Latest != nil
+	})
+
+	server.checkAlerts(time.Now())
+	events, _ := server.store.ListAlertEvents(0)
+	if len(events) != 1 || events[0].ClientID != id || events[0].State != "firing" {
+		t.Fatalf("告警事件为 %+v", events)
+	}
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// apiTokenPrefix 个人令牌的前缀，便于在配置和日志中识别泄露的令牌
+const apiTokenPrefix = "gnt_"
+
+// 个人令牌的权限
+const (
+	scopeRead  = "read"  // 只能发送 GET 和 HEAD 请求
+	scopeWrite = "write" // 可以发送所有请求，包含 read
+	scopeAdmin = "admin" // 还可以下载备份、查看登录记录以及导入和导出客户端密钥的哈希，包含 write
+)
+
+// apiTokenTouchInterval 更新个人令牌最后使用时间的最小间隔，避免每个请求都写数据库
+const apiTokenTouchInterval = time.Minute
+
+// errForbidden 个人令牌没有请求需要的权限
+var errForbidden = &apiError{http.StatusForbidden, "forbidden", "令牌没有修改权限"}
+
+// errForbiddenAdmin 个人令牌没有管理权限
+var errForbiddenAdmin = &apiError{http.StatusForbidden, "forbidden", "令牌没有管理权限"}
+
+// APITokenStatus 在个人令牌的基础上附带当前是否可用
+type APITokenStatus struct {
+	APIToken
+	Valid bool `json:"valid"`
+}
+
+// expired 判断令牌在 now 时是否已过期
+func (t *APIToken) expired(now time.Time) bool {
+	return !t.ExpiresAt.IsZero() && !now.Before(t.ExpiresAt)
+}
+
+// allows 判断令牌是否可以发送该方法的请求
+func (t *APIToken) allows(method string) bool {
+	if slices.Contains(t.Scopes, scopeWrite) || slices.Contains(t.Scopes, scopeAdmin) {
+		return true
+	}
+	return slices.Contains(t.Scopes, scopeRead) && (method == http.MethodGet || method == http.MethodHead)
+}
+
+// normalizeScopes 检查并规范化令牌的权限，admin 包含 write，write 包含 read，因此只保留最高的权限
+func normalizeScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, errors.New("请选择令牌的权限")
+	}
+	for _, scope := range scopes {
+		if scope != scopeRead && scope != scopeWrite && scope != scopeAdmin {
+			return nil, errors.New("权限 " + scope + " 无效，可选 read、write、admin")
+		}
+	}
+	if slices.Contains(scopes, scopeAdmin) {
+		return []string{scopeAdmin}, nil
+	}
+	if slices.Contains(scopes, scopeWrite) {
+		return []string{scopeWrite}, nil
+	}
+	return []string{scopeRead}, nil
+}
+
+// bearerToken 返回请求的 Authorization: Bearer 头中的令牌
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+// authorize 检查请求是否已登录或携带有效的个人令牌，个人令牌还需要有请求方法对应的权限
+// 携带了 Authorization 头时只检查令牌，不再检查会话
+func (s *Server) authorize(r *http.Request) error {
+	t, err := s.requestToken(r)
+	if err != nil || t == nil {
+		return err
+	}
+	if !t.allows(r.Method) {
+		return errForbidden
+	}
+	return nil
+}
+
+// authorizeAdmin 与 authorize 相同，但个人令牌需要 admin 权限，
+// 用于备份、登录记录等包含密码哈希或其他敏感信息的接口
+func (s *Server) authorizeAdmin(r *http.Request) error {
+	t, err := s.requestToken(r)
+	if err != nil || t == nil {
+		return err
+	}
+	if !slices.Contains(t.Scopes, scopeAdmin) {
+		return errForbiddenAdmin
+	}
+	return nil
+}
+
+// requestToken 返回请求携带的个人令牌，没有携带令牌但已登录时返回 nil
+// 携带了 Authorization 头时只检查令牌，不再检查会话
+func (s *Server) requestToken(r *http.Request) (*APIToken, error) {
+	token, ok := bearerToken(r)
+	if !ok {
+		if _, ok := s.currentSession(r); ok {
+			return nil, nil
+		}
+		return nil, errUnauthorized
+	}
+	t, ok := s.apiToken(token)
+	if !ok {
+		return nil, errUnauthorized
+	}
+	return &t, nil
+}
+
+// apiToken 返回未过期的个人令牌，并记录最后使用时间
+func (s *Server) apiToken(token string) (APIToken, bool) {
+	if !strings.HasPrefix(token, apiTokenPrefix) {
+		return APIToken{}, false
+	}
+	t, err := s.store.GetAPIToken(token)
+	if err != nil {
+		if !errors.Is(err, ErrNotFound) {
+			log.Printf("读取个人令牌出错: %v", err)
+		}
+		return APIToken{}, false
+	}
+	now := time.Now()
+	if t.expired(now) {
+		return APIToken{}, false
+	}
+	if now.Sub(t.LastUsedAt) >= apiTokenTouchInterval {
+		if err := s.store.TouchAPIToken(token, now); err != nil {
+			log.Printf("更新个人令牌的使用时间出错: %v", err)
+		}
+	}
+	return t, true
+}
+
+// userAPITokens 返回用户的所有个人令牌，新创建的在前
+func (s *Server) userAPITokens(username string) ([]APIToken, error) {
+	tokens, err := s.store.ListAPITokens()
+	if err != nil {
+		return nil, err
+	}
+	tokens = slices.DeleteFunc(tokens, func(t APIToken) bool { return t.Username != username })
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].CreatedAt.After(tokens[j].CreatedAt) })
+	return tokens, nil
+}
+
+// handleGetAPITokens 返回当前用户的个人令牌，不包含令牌原文
+// 令牌的管理只能通过登录会话进行，个人令牌不能创建新的令牌
+func (s *Server) handleGetAPITokens(w http.ResponseWriter, r *http.Request) {
+	session, ok := s.currentSession(r)
+	if !ok {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	tokens, err := s.userAPITokens(session.Username)
+	if err != nil {
+		log.Printf("读取个人令牌出错: %v", err)
+		http.Error(w, "服务器内部错误", http.StatusInternalServerError)
+		return
+	}
+	now := time.Now()
+	statuses := make([]APITokenStatus, len(tokens))
+	for i, t := range tokens {
+		statuses[i] = APITokenStatus{APIToken: t, Valid: !t.expired(now)}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statuses)
+}
+
+// handleCreateAPIToken 为当前用户创建个人令牌，令牌原文只在创建时返回一次
+func (s *Server) handleCreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	session, ok := s.currentSession(r)
+	if !ok {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	var info struct {
+		Name      string    `json:"name"`
+		Scopes    []string  `json:"scopes"`
+		ExpiresAt time.Time `json:"expiresAt"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&info); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	t, token, err := s.createAPIToken(session.Username, info.Name, info.Scopes, info.ExpiresAt)
+	if err != nil {
+		writeTextError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "success",
+		"id":     t.ID,
+		"token":  token,
+	})
+}
+
+// createAPIToken 校验并保存个人令牌，返回令牌和令牌原文
+func (s *Server) createAPIToken(username, name string, scopes []string, expiresAt time.Time) (APIToken, string, error) {
+	now := time.Now()
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return APIToken{}, "", errInvalid("名称不能为空")
+	}
+	scopes, err := normalizeScopes(scopes)
+	if err != nil {
+		return APIToken{}, "", errInvalid(err.Error())
+	}
+	if !expiresAt.IsZero() && !expiresAt.After(now) {
+		return APIToken{}, "", errInvalid("过期时间必须晚于当前时间")
+	}
+
+	token := apiTokenPrefix + newToken()
+	t := APIToken{
+		ID:        newID(),
+		Name:      name,
+		Username:  username,
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+		CreatedAt: now,
+	}
+	if err := s.store.SaveAPIToken(token, t); err != nil {
+		return APIToken{}, "", err
+	}
+	log.Printf("用户 %s 创建了个人令牌 %s", username, name)
+	return t, token, nil
+}
+
+// handleDeleteAPIToken 吊销当前用户的个人令牌，立即生效
+func (s *Server) handleDeleteAPIToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	session, ok := s.currentSession(r)
+	if !ok {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	var info struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&info); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 只能吊销自己的令牌
+	tokens, err := s.userAPITokens(session.Username)
+	if err != nil {
+		log.Printf("读取个人令牌出错: %v", err)
+		http.Error(w, "服务器内部错误", http.StatusInternalServerError)
+		return
+	}
+	if !slices.ContainsFunc(tokens, func(t APIToken) bool { return t.ID == info.ID }) {
+		http.Error(w, "令牌不存在", http.StatusNotFound)
+		return
+	}
+	if err := s.store.DeleteAPIToken(info.ID); err != nil && !errors.Is(err, ErrNotFound) {
+		log.Printf("删除个人令牌出错: %v", err)
+		http.Error(w, "服务器内部错误", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tokenClient 返回使用个人令牌访问接口的 HTTP 客户端
+func tokenClient(token string) *http.Client {
+	return &http.Client{Transport: bearerTransport{token}}
+}
+
+// createToken 通过登录会话创建个人令牌，返回令牌 ID 和令牌原文
+func createToken(t *testing.T, c *http.Client, url, name, scope string, expiresAt time.Time) (string, string) {
+	t.Helper()
+	body := map[string]any{"name": name, "scopes": []string{scope}}
+	if !expiresAt.IsZero() {
+		body["expiresAt"] = expiresAt
+	}
+	result := mustPost(t, c, url+"/api/tokens/create", body, http.StatusOK)
+	if result["id"] == "" || result["token"] == "" {
+		t.Fatalf("创建令牌的响应为 %v", result)
+	}
+	return result["id"], result["token"]
+}
+
+func TestAPITokenScopes(t *testing.T) {
+	server, ts := newTestServer(t)
+	admin := loginClient(t, ts)
+	api := ts.URL + apiV1Prefix
+	addClient(t, admin, ts, "web")
+
+	_, readToken := createToken(t, admin, ts.URL, "monitoring", scopeRead, time.Time{})
+	_, writeToken := createToken(t, admin, ts.URL, "ci", scopeWrite, time.Now().Add(time.Hour))
+	reader, writer := tokenClient(readToken), tokenClient(writeToken)
+
+	// 只读令牌可以查询，不能修改
+	var clients []Client
+	v1Request(t, reader, "GET", api+"/clients", nil, http.StatusOK, &clients)
+	if len(clients) != 1 {
+		t.Fatalf("客户端为 %+v", clients)
+	}
+	if clients := getClients(t, reader, ts.URL); len(clients) != 1 {
+		t.Fatalf("旧接口返回的客户端为 %+v", clients)
+	}
+	var body apiErrorBody
+	v1Request(t, reader, "POST", api+"/clients", map[string]any{"name": "db"}, http.StatusForbidden, &body)
+	if body.Error.Code != "forbidden" {
+		t.Fatalf("只读令牌修改的错误为 %+v", body)
+	}
+	mustPost(t, reader, ts.URL+"/api/clients/add", map[string]string{"name": "db"}, http.StatusUnauthorized)
+
+	// 读写令牌可以修改
+	v1Request(t, writer, "POST", api+"/clients", map[string]any{"name": "db"}, http.StatusCreated, nil)
+
+	// 令牌不能管理令牌
+	mustPost(t, writer, ts.URL+"/api/tokens/create", map[string]any{"name": "x", "scopes": []string{scopeWrite}}, http.StatusUnauthorized)
+
+	// 无效的令牌不会回退到会话
+	v1Request(t, tokenClient("gnt_invalid"), "GET", api+"/clients", nil, http.StatusUnauthorized, nil)
+
+	var tokens []APITokenStatus
+	mustGet(t, admin, ts.URL+"/api/tokens", &tokens)
+	if len(tokens) != 2 || tokens[0].Name != "ci" || !tokens[0].Valid || tokens[1].LastUsedAt.IsZero() {
+		t.Fatalf("令牌列表为 %+v", tokens)
+	}
+
+	// 修改用户名后令牌仍然可用
+	mustPost(t, admin, ts.URL+"/api/change-password", map[string]string{"username": "root", "oldPassword": "admin", "newPassword": "admin"}, http.StatusOK)
+	v1Request(t, reader, "GET", api+"/clients", nil, http.StatusOK, nil)
+	if tokens, err := server.userAPITokens("root"); err != nil || len(tokens) != 2 {
+		t.Fatalf("改名后的令牌为 %+v, %v", tokens, err)
+	}
+
+}
+
+func TestAPITokenAdminScope(t *testing.T) {
+	server, ts := newTestServer(t)
+	browser := loginClient(t, ts)
+	id := server.clients.AddEnrolled("web", "", hashSecret("secret"), "autoscaling").ID
+
+	_, readToken := createToken(t, browser, ts.URL, "monitoring", scopeRead, time.Time{})
+	_, writeToken := createToken(t, browser, ts.URL, "ci", scopeWrite, time.Time{})
+	_, adminToken := createToken(t, browser, ts.URL, "backup", scopeAdmin, time.Time{})
+
+	// 备份包含用户的密码哈希，登录记录包含用户名和来源 IP，只读和读写令牌都不能访问
+	for scope, token := range map[string]string{scopeRead: readToken, scopeWrite: writeToken} {
+		c := tokenClient(token)
+		for _, path := range []string{"/api/backup", "/api/auth/events"} {
+			resp, err := c.Get(ts.URL + path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusForbidden {
+				t.Fatalf("%s 权限的令牌访问 %s 返回 %s", scope, path, resp.Status)
+			}
+		}
+		mustPost(t, c, ts.URL+"/api/auth/unlock", map[string]string{"ip": "192.0.2.1"}, http.StatusForbidden)
+
+		// 导出的客户端不包含密钥的哈希
+		var records map[string]ClientRecord
+		mustGet(t, c, ts.URL+"/api/clients/export", &records)
+		if len(records) != 1 || records[id].SecretHash != "" {
+			t.Fatalf("%s 权限的令牌导出的客户端为 %+v", scope, records)
+		}
+	}
+
+	// 读写令牌也不能通过导入替换密钥的哈希冒充客户端，试运行同样被拒绝；不包含密钥的导入不受影响
+	writer := tokenClient(writeToken)
+	stolen := `[{"id": "` + id + `", "name": "web", "secretHash": "` + hashSecret("stolen") + `"}]`
+	importClients(t, writer, ts.URL+"/api/clients/import?dryRun=1", stolen, http.StatusForbidden)
+	importClients(t, writer, ts.URL+"/api/clients/import", stolen, http.StatusForbidden)
+	if c, _ := server.clients.Snapshot().Get(id); !c.checkSecret("secret") || c.checkSecret("stolen") {
+		t.Fatal("读写令牌修改了客户端的密钥")
+	}
+	importClients(t, writer, ts.URL+"/api/clients/import", `[{"id": "`+id+`", "name": "web-1"}]`, http.StatusOK)
+
+	full := tokenClient(adminToken)
+	var events map[string]any
+	mustGet(t, full, ts.URL+"/api/auth/events", &events)
+	var records map[string]ClientRecord
+	mustGet(t, full, ts.URL+"/api/clients/export", &records)
+	if len(records) != 1 || records[id].SecretHash != hashSecret("secret") {
+		t.Fatalf("admin 令牌导出的客户端为 %+v", records)
+	}
+	importClients(t, full, ts.URL+"/api/clients/import", `[{"id": "`+id+`", "name": "web", "secretHash": "`+hashSecret("rotated")+`"}]`, http.StatusOK)
+	if c, _ := server.clients.Snapshot().Get(id); !c.checkSecret("rotated") {
+		t.Fatal("admin 令牌导入的密钥没有生效")
+	}
+	// 登录用户仍然可以导出密钥的哈希
+	mustGet(t, browser, ts.URL+"/api/clients/export", &records)
+	if len(records) != 1 || records[id].SecretHash == "" {
+		t.Fatalf("登录用户导出的客户端为 %+v", records)
+	}
+
+	// 通过令牌备份，不需要密码，但需要 admin 权限
+	t.Setenv(passwordEnv, "")
+	t.Setenv(tokenEnv, readToken)
+	if err := runBackup([]string{"-url", ts.URL, "-o", filepath.Join(t.TempDir(), "backup.db")}); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("只读令牌备份返回 %v", err)
+	}
+	t.Setenv(tokenEnv, adminToken)
+	if err := runBackup([]string{"-url", ts.URL, "-o", filepath.Join(t.TempDir(), "backup.db")}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAPITokenRevokeAndExpiry(t *testing.T) {
+	server, ts := newTestServer(t)
+	admin := loginClient(t, ts)
+	api := ts.URL + apiV1Prefix
+
+	id, token := createToken(t, admin, ts.URL, "ci", scopeRead, time.Time{})
+	c := tokenClient(token)
+	v1Request(t, c, "GET", api+"/clients", nil, http.StatusOK, nil)
+
+	mustPost(t, admin, ts.URL+"/api/tokens/delete", map[string]string{"id": id}, http.StatusOK)
+	v1Request(t, c, "GET", api+"/clients", nil, http.StatusUnauthorized, nil)
+	mustPost(t, admin, ts.URL+"/api/tokens/delete", map[string]string{"id": id}, http.StatusNotFound)
+
+	// 过期的令牌不能使用
+	_, token = createToken(t, admin, ts.URL, "short", scopeRead, time.Now().Add(time.Hour))
+	stored, err := server.store.GetAPIToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored.ExpiresAt = time.Now().Add(-time.Second)
+	if err := server.store.SaveAPIToken(token, stored); err != nil {
+		t.Fatal(err)
+	}
+	v1Request(t, tokenClient(token), "GET", api+"/clients", nil, http.StatusUnauthorized, nil)
+	var tokens []APITokenStatus
+	mustGet(t, admin, ts.URL+"/api/tokens", &tokens)
+	if len(tokens) != 1 || tokens[0].Valid {
+		t.Fatalf("令牌列表为 %+v", tokens)
+	}
+
+	for _, body := range []map[string]any{
+		{"name": " ", "scopes": []string{scopeRead}},
+		{"name": "x"},
+		{"name": "x", "scopes": []string{"owner"}},
+		{"name": "x", "scopes": []string{scopeRead}, "expiresAt": time.Now().Add(-time.Hour)},
+	} {
+		mustPost(t, admin, ts.URL+"/api/tokens/create", body, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,487 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// apiV1Prefix v1 接口的路径前缀
+const apiV1Prefix = "/api/v1"
+
+// maxRequestBody v1 接口请求内容的最大字节数
+const maxRequestBody = 1 << 20
+
+// openAPISpec v1 接口的 OpenAPI 文档，修改接口时需要同步更新
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// apiError 表示返回给调用方的错误，Code 为稳定的错误码，供程序判断；Message 为说明
+type apiError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return e.Message
+}
+
+var (
+	errUnauthorized     = &apiError{http.StatusUnauthorized, "unauthorized", "未授权"}
+	errMethodNotAllowed = &apiError{http.StatusMethodNotAllowed, "method_not_allowed", "方法不允许"}
+	// errInternal 具体原因只记录在日志中，不返回给调用方
+	errInternal = &apiError{http.StatusInternalServerError, "internal", "服务器内部错误"}
+)
+
+// errInvalid 返回请求内容无效的错误
+func errInvalid(message string) *apiError {
+	return &apiError{http.StatusBadRequest, "invalid_request", message}
+}
+
+// errNotFound 返回资源不存在的错误
+func errNotFound(message string) *apiError {
+	return &apiError{http.StatusNotFound, "not_found", message}
+}
+
+// toAPIError 把处理请求时的错误转换为 apiError，其他错误记录日志后作为内部错误返回
+func toAPIError(err error) *apiError {
+	var e *apiError
+	if errors.As(err, &e) {
+		return e
+	}
+	log.Printf("处理请求出错: %v", err)
+	return errInternal
+}
+
+// writeJSON 以 JSON 格式返回 v
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeAPIError 以 v1 接口的格式返回错误：{"error": {"code": "...", "message": "..."}}
+func writeAPIError(w http.ResponseWriter, err error) {
+	e := toAPIError(err)
+	writeJSON(w, e.Status, map[string]any{
+		"error": map[string]string{"code": e.Code, "message": e.Message},
+	})
+}
+
+// writeTextError 以旧接口的纯文本格式返回错误
+func writeTextError(w http.ResponseWriter, err error) {
+	e := toAPIError(err)
+	http.Error(w, e.Message, e.Status)
+}
+
+// decodeBody 读取 JSON 格式的请求内容，未知的字段视为错误，避免拼错的字段被忽略
+func decodeBody(w http.ResponseWriter, r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return errInvalid("请求内容无效: " + err.Error())
+	}
+	return nil
+}
+
+// deprecated 标记旧接口已被 v1 接口取代，旧接口的请求和响应保持不变
+func deprecated(w http.ResponseWriter, successor string) {
+	w.Header().Set("Deprecation", "true")
+	w.Header().Set("Link", fmt.Sprintf("<%s%s>; rel=\"successor-version\"", apiV1Prefix, successor))
+}
+
+// apiHandler 处理 v1 接口某个路径的一种请求方法，返回的错误以 JSON 格式发送给调用方
+type apiHandler func(w http.ResponseWriter, r *http.Request) error
+
+// v1Route 表示 v1 接口的一个路径及其支持的请求方法
+type v1Route struct {
+	path    string
+	methods map[string]apiHandler
+}
+
+// v1Routes 返回 v1 接口的所有路由，路径相对于 apiV1Prefix，{id} 通过 r.PathValue 读取
+// openapi.json 中的路径和方法与这里一一对应
+func (s *Server) v1Routes() []v1Route {
+	return []v1Route{
+		{"/clients", map[string]apiHandler{
+			http.MethodGet:  s.v1ListClients,
+			http.MethodPost: s.v1CreateClient,
+		}},
+		{"/clients/{id}", map[string]apiHandler{
+			http.MethodGet:    s.v1GetClient,
+			http.MethodPatch:  s.v1UpdateClient,
+			http.MethodDelete: s.v1DeleteClient,
+		}},
+		{"/groups", map[string]apiHandler{
+			http.MethodGet: s.v1ListGroups,
+		}},
+		{"/alert-rules", map[string]apiHandler{
+			http.MethodGet:  s.v1ListAlertRules,
+			http.MethodPost: s.v1CreateAlertRule,
+		}},
+		{"/alert-rules/{id}", map[string]apiHandler{
+			http.MethodGet:    s.v1GetAlertRule,
+			http.MethodPut:    s.v1ReplaceAlertRule,
+			http.MethodDelete: s.v1DeleteAlertRule,
+		}},
+		{"/monitors", map[string]apiHandler{
+			http.MethodGet:  s.v1ListMonitors,
+			http.MethodPost: s.v1CreateMonitor,
+		}},
+		{"/monitors/{id}", map[string]apiHandler{
+			http.MethodGet:    s.v1GetMonitor,
+			http.MethodPut:    s.v1ReplaceMonitor,
+			http.MethodDelete: s.v1DeleteMonitor,
+		}},
+	}
+}
+
+// registerV1 在 mux 上注册 v1 接口和 OpenAPI 文档
+func (s *Server) registerV1(mux *http.ServeMux) {
+	mux.HandleFunc(apiV1Prefix+"/openapi.json", s.handleOpenAPI)
+	for _, route := range s.v1Routes() {
+		mux.HandleFunc(apiV1Prefix+route.path, s.v1Handler(route.methods))
+	}
+	// 其他路径也以 JSON 格式返回错误
+	mux.HandleFunc(apiV1Prefix+"/", func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, errNotFound("接口不存在"))
+	})
+}
+
+// v1Handler 检查登录状态或个人令牌，按请求方法分发到对应的处理函数
+func (s *Server) v1Handler(methods map[string]apiHandler) http.HandlerFunc {
+	allow := strings.Join(slices.Sorted(maps.Keys(methods)), ", ")
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.authorize(r); err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		handle, ok := methods[r.Method]
+		if !ok {
+			w.Header().Set("Allow", allow)
+			writeAPIError(w, errMethodNotAllowed)
+			return
+		}
+		if err := handle(w, r); err != nil {
+			writeAPIError(w, err)
+		}
+	}
+}
+
+// handleOpenAPI 返回 v1 接口的 OpenAPI 文档，不需要登录
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeAPIError(w, errMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
+
+// created 返回新建的资源，Location 指向其地址
+func created(w http.ResponseWriter, path string, v any) {
+	w.Header().Set("Location", apiV1Prefix+path)
+	writeJSON(w, http.StatusCreated, v)
+}
+
+// clientPatch 表示对客户端的修改，为 nil 的字段保持不变
+type clientPatch struct {
+	Name         *string   `json:"name"`
+	Group        *string   `json:"group"`
+	Tags         *[]string `json:"tags"`
+	Public       *bool     `json:"public"` // 公开时生成新的徽章标识，取消公开后旧地址失效
+	DisplayOrder *int      `json:"displayOrder"`
+}
+
+// normalize 校验并规范化要修改的字段
+func (p *clientPatch) normalize() error {
+	if p.Name != nil {
+		name := strings.TrimSpace(*p.Name)
+		if name == "" {
+			return errInvalid("客户端名称不能为空")
+		}
+		if len([]rune(name)) > maxClientName {
+			return errInvalid(fmt.Sprintf("客户端名称不能超过 %d 个字符", maxClientName))
+		}
+		p.Name = &name
+	}
+	if p.Group != nil {
+		group := normalizeGroup(*p.Group)
+		p.Group = &group
+	}
+	if p.Tags != nil {
+		tags := normalizeTags(*p.Tags)
+		if err := validateTags(tags); err != nil {
+			return errInvalid(err.Error())
+		}
+		p.Tags = &tags
+	}
+	if p.DisplayOrder != nil && *p.DisplayOrder < 0 {
+		return errInvalid("显示顺序不能为负数")
+	}
+	return nil
+}
+
+// apply 把修改写入客户端，调用前需先 normalize
+func (p *clientPatch) apply(c *Client) {
+	if p.Name != nil {
+		c.Name = *p.Name
+	}
+	if p.Group != nil {
+		c.Group = *p.Group
+	}
+	if p.Tags != nil {
+		c.Tags = *p.Tags
+	}
+	if p.DisplayOrder != nil {
+		c.DisplayOrder = *p.DisplayOrder
+	}
+	switch {
+	case p.Public == nil:
+	case !*p.Public:
+		c.BadgeID = ""
+	case c.BadgeID == "":
+		c.BadgeID = newID()
+	}
+}
+
+// createClient 按 patch 添加客户端，名称必须提供；未指定显示顺序时排在最后
+func (s *Server) createClient(p clientPatch) (Client, error) {
+	if p.Name == nil {
+		return Client{}, errInvalid("客户端名称不能为空")
+	}
+	if err := p.normalize(); err != nil {
+		return Client{}, err
+	}
+	return s.clients.Create(p.apply), nil
+}
+
+// updateClient 按 patch 修改客户端
+func (s *Server) updateClient(id string, p clientPatch) (Client, error) {
+	if err := p.normalize(); err != nil {
+		return Client{}, err
+	}
+	c, err := s.clients.Update(id, p.apply)
+	if errors.Is(err, ErrNotFound) {
+		return c, errNotFound("客户端不存在")
+	}
+	return c, err
+}
+
+// clientView 返回用于接口输出的客户端，不包含客户端密钥的哈希值
+func clientView(c Client) Client {
+	c.SecretHash = ""
+	return c
+}
+
+// v1ListClients 按显示顺序返回客户端，支持与 /api/clients 相同的 group、tag、label 筛选参数
+func (s *Server) v1ListClients(w http.ResponseWriter, r *http.Request) error {
+	query, err := parseClientQuery(r)
+	if err != nil {
+		return errInvalid(err.Error())
+	}
+	list := []Client{}
+	for _, c := range s.clients.Snapshot().List() {
+		if query.matches(&c) {
+			list = append(list, clientView(c))
+		}
+	}
+	writeJSON(w, http.StatusOK, list)
+	return nil
+}
+
+// v1CreateClient 添加客户端，返回的 id 用于启动客户端
+func (s *Server) v1CreateClient(w http.ResponseWriter, r *http.Request) error {
+	var p clientPatch
+	if err := decodeBody(w, r, &p); err != nil {
+		return err
+	}
+	c, err := s.createClient(p)
+	if err != nil {
+		return err
+	}
+	created(w, "/clients/"+c.ID, clientView(c))
+	return nil
+}
+
+func (s *Server) v1GetClient(w http.ResponseWriter, r *http.Request) error {
+	c, ok := s.clients.Snapshot().Get(r.PathValue("id"))
+	if !ok {
+		return errNotFound("客户端不存在")
+	}
+	writeJSON(w, http.StatusOK, clientView(c))
+	return nil
+}
+
+// v1UpdateClient 只修改请求中出现的字段
+func (s *Server) v1UpdateClient(w http.ResponseWriter, r *http.Request) error {
+	var p clientPatch
+	if err := decodeBody(w, r, &p); err != nil {
+		return err
+	}
+	c, err := s.updateClient(r.PathValue("id"), p)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusOK, clientView(c))
+	return nil
+}
+
+// v1DeleteClient 删除客户端及其历史数据，并断开其连接
+func (s *Server) v1DeleteClient(w http.ResponseWriter, r *http.Request) error {
+	if !s.clients.Delete(r.PathValue("id")) {
+		return errNotFound("客户端不存在")
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// v1ListGroups 返回每个分组的汇总，支持 tag 和 label 筛选参数
+func (s *Server) v1ListGroups(w http.ResponseWriter, r *http.Request) error {
+	query, err := parseClientQuery(r)
+	if err != nil {
+		return errInvalid(err.Error())
+	}
+	query.group = ""
+	list := slices.DeleteFunc(s.clients.Snapshot().List(), func(c Client) bool {
+		return !query.matches(&c)
+	})
+	writeJSON(w, http.StatusOK, groupStats(list))
+	return nil
+}
+
+func (s *Server) v1ListAlertRules(w http.ResponseWriter, r *http.Request) error {
+	rules, err := s.listAlertRules()
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusOK, rules)
+	return nil
+}
+
+// v1CreateAlertRule 添加告警规则，请求中的 id 和 createdAt 被忽略
+func (s *Server) v1CreateAlertRule(w http.ResponseWriter, r *http.Request) error {
+	var rule AlertRule
+	if err := decodeBody(w, r, &rule); err != nil {
+		return err
+	}
+	rule.ID = ""
+	rule, err := s.saveAlertRule(rule)
+	if err != nil {
+		return err
+	}
+	created(w, "/alert-rules/"+rule.ID, rule)
+	return nil
+}
+
+func (s *Server) v1GetAlertRule(w http.ResponseWriter, r *http.Request) error {
+	rule, err := s.alertRule(r.PathValue("id"))
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusOK, rule)
+	return nil
+}
+
+// v1ReplaceAlertRule 以请求内容替换告警规则的全部设置
+func (s *Server) v1ReplaceAlertRule(w http.ResponseWriter, r *http.Request) error {
+	var rule AlertRule
+	if err := decodeBody(w, r, &rule); err != nil {
+		return err
+	}
+	id := r.PathValue("id")
+	if rule.ID != "" && rule.ID != id {
+		return errInvalid("请求内容中的 id 与路径不一致")
+	}
+	rule.ID = id
+	rule, err := s.saveAlertRule(rule)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusOK, rule)
+	return nil
+}
+
+func (s *Server) v1DeleteAlertRule(w http.ResponseWriter, r *http.Request) error {
+	id := r.PathValue("id")
+	if _, err := s.alertRule(id); err != nil {
+		return err
+	}
+	if err := s.store.DeleteAlertRule(id); err != nil {
+		return fmt.Errorf("删除告警规则出错: %w", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// v1ListMonitors 按显示顺序返回服务端探测及其最新结果
+func (s *Server) v1ListMonitors(w http.ResponseWriter, r *http.Request) error {
+	writeJSON(w, http.StatusOK, s.monitors.List())
+	return nil
+}
+
+// v1CreateMonitor 添加服务端探测，排在最后，请求中的 id、createdAt 和 displayOrder 被忽略
+func (s *Server) v1CreateMonitor(w http.ResponseWriter, r *http.Request) error {
+	var m Monitor
+	if err := decodeBody(w, r, &m); err != nil {
+		return err
+	}
+	m.ID = ""
+	status, err := s.saveMonitor(m)
+	if err != nil {
+		return err
+	}
+	created(w, "/monitors/"+status.ID, status)
+	return nil
+}
+
+func (s *Server) v1GetMonitor(w http.ResponseWriter, r *http.Request) error {
+	status, ok := s.monitors.Get(r.PathValue("id"))
+	if !ok {
+		return errNotFound("服务端探测不存在")
+	}
+	writeJSON(w, http.StatusOK, status)
+	return nil
+}
+
+// v1ReplaceMonitor 以请求内容替换服务端探测的设置，创建时间和显示顺序保持不变
+func (s *Server) v1ReplaceMonitor(w http.ResponseWriter, r *http.Request) error {
+	var m Monitor
+	if err := decodeBody(w, r, &m); err != nil {
+		return err
+	}
+	id := r.PathValue("id")
+	if m.ID != "" && m.ID != id {
+		return errInvalid("请求内容中的 id 与路径不一致")
+	}
+	m.ID = id
+	status, err := s.saveMonitor(m)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusOK, status)
+	return nil
+}
+
+// v1DeleteMonitor 删除服务端探测及其探测结果
+func (s *Server) v1DeleteMonitor(w http.ResponseWriter, r *http.Request) error {
+	id := r.PathValue("id")
+	if _, ok := s.monitors.Get(id); !ok {
+		return errNotFound("服务端探测不存在")
+	}
+	s.monitors.Remove(id)
+	if err := s.store.DeleteMonitor(id); err != nil {
+		return fmt.Errorf("删除服务端探测出错: %w", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// v1Request 发送 v1 接口请求并检查状态码，v 不为 nil 时解析 JSON 响应
+func v1Request(t *testing.T, c *http.Client, method, url string, body any, wantStatus int, v any) *http.Response {
+	t.Helper()
+	var r io.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, url, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != wantStatus {
+		t.Fatalf("%s %s 返回 %s，期望 %d: %s", method, url, resp.Status, wantStatus, data)
+	}
+	if v != nil {
+		if err := json.Unmarshal(data, v); err != nil {
+			t.Fatalf("%s %s 的响应 %q 无法解析: %v", method, url, data, err)
+		}
+	}
+	return resp
+}
+
+// apiErrorBody 表示 v1 接口的错误响应
+type apiErrorBody struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func TestV1Clients(t *testing.T) {
+	_, ts := newTestServer(t)
+	admin := loginClient(t, ts)
+	api := ts.URL + apiV1Prefix
+
+	var c Client
+	resp := v1Request(t, admin, "POST", api+"/clients", map[string]any{"name": " web ", "group": "prod/ web", "tags": []string{"b", "a"}}, http.StatusCreated, &c)
+	if c.ID == "" || c.Name != "web" || c.Group != "prod/web" || !slices.Equal(c.Tags, []string{"a", "b"}) || c.DisplayOrder != 1 {
+		t.Fatalf("新建的客户端为 %+v", c)
+	}
+	if loc := resp.Header.Get("Location"); loc != apiV1Prefix+"/clients/"+c.ID {
+		t.Fatalf("Location 为 %q", loc)
+	}
+	// 旧接口添加的客户端在 v1 接口中可见
+	old := addClient(t, admin, ts, "db")
+
+	var list []Client
+	v1Request(t, admin, "GET", api+"/clients?group=prod", nil, http.StatusOK, &list)
+	if len(list) != 1 || list[0].ID != c.ID {
+		t.Fatalf("按分组筛选的客户端为 %+v", list)
+	}
+
+	// PATCH 只修改出现的字段
+	v1Request(t, admin, "PATCH", api+"/clients/"+c.ID, map[string]any{"public": true, "displayOrder": 5}, http.StatusOK, &c)
+	if c.Name != "web" || c.Group != "prod/web" || c.BadgeID == "" || c.DisplayOrder != 5 {
+		t.Fatalf("修改后的客户端为 %+v", c)
+	}
+	v1Request(t, admin, "GET", api+"/clients/"+old, nil, http.StatusOK, &c)
+	if c.Name != "db" {
+		t.Fatalf("客户端为 %+v", c)
+	}
+
+	for _, tc := range []struct {
+		method, path string
+		body         any
+		status       int
+		code         string
+	}{
+		{"POST", "/clients", map[string]any{"group": "x"}, http.StatusBadRequest, "invalid_request"},
+		{"POST", "/clients", map[string]any{"name": "x", "nmae": "typo"}, http.StatusBadRequest, "invalid_request"},
+		{"PATCH", "/clients/" + old, map[string]any{"name": "  "}, http.StatusBadRequest, "invalid_request"},
+		{"PATCH", "/clients/" + old, map[string]any{"tags": []string{"a,b"}}, http.StatusBadRequest, "invalid_request"},
+		{"PATCH", "/clients/missing", map[string]any{"name": "x"}, http.StatusNotFound, "not_found"},
+		{"GET", "/clients?label=bad", nil, http.StatusBadRequest, "invalid_request"},
+		{"PUT", "/clients/" + old, map[string]any{}, http.StatusMethodNotAllowed, "method_not_allowed"},
+		{"GET", "/nothing", nil, http.StatusNotFound, "not_found"},
+	} {
+		var body apiErrorBody
+		resp := v1Request(t, admin, tc.method, api+tc.path, tc.body, tc.status, &body)
+		if body.Error.Code != tc.code || body.Error.Message == "" {
+			t.Errorf("%s %s 的错误为 %+v", tc.method, tc.path, body)
+		}
+		if tc.status == http.StatusMethodNotAllowed && resp.Header.Get("Allow") != "DELETE, GET, PATCH" {
+			t.Errorf("Allow 为 %q", resp.Header.Get("Allow"))
+		}
+	}
+
+	v1Request(t, admin, "DELETE", api+"/clients/"+old, nil, http.StatusNoContent, nil)
+	v1Request(t, admin, "DELETE", api+"/clients/"+old, nil, http.StatusNotFound, nil)
+	if clients := getClients(t, admin, ts.URL); len(clients) != 1 {
+		t.Fatalf("删除后的客户端为 %+v", clients)
+	}
+
+	var body apiErrorBody
+	v1Request(t, newCookieClient(), "GET", api+"/clients", nil, http.StatusUnauthorized, &body)
+	if body.Error.Code != "unauthorized" {
+		t.Fatalf("匿名访问的错误为 %+v", body)
+	}
+}
+
+func TestV1AlertRulesAndMonitors(t *testing.T) {
+	_, ts := newTestServer(t)
+	admin := loginClient(t, ts)
+	api := ts.URL + apiV1Prefix
+
+	var rule AlertRule
+	v1Request(t, admin, "POST", api+"/alert-rules", AlertRule{Name: "CPU", Metric: "cpu", Operator: ">", Threshold: 90}, http.StatusCreated, &rule)
+	if rule.ID == "" || rule.CreatedAt.IsZero() {
+		t.Fatalf("新建的告警规则为 %+v", rule)
+	}
+	created := rule.CreatedAt
+	rule.Threshold, rule.CreatedAt = 80, created.AddDate(-1, 0, 0)
+	v1Request(t, admin, "PUT", api+"/alert-rules/"+rule.ID, rule, http.StatusOK, &rule)
+	if rule.Threshold != 80 || !rule.CreatedAt.Equal(created) {
+		t.Fatalf("修改后的告警规则为 %+v", rule)
+	}
+	v1Request(t, admin, "PUT", api+"/alert-rules/"+rule.ID, AlertRule{ID: "other", Name: "x", Metric: "cpu", Operator: ">"}, http.StatusBadRequest, nil)
+	v1Request(t, admin, "PUT", api+"/alert-rules/missing", AlertRule{Name: "x", Metric: "cpu", Operator: ">"}, http.StatusNotFound, nil)
+	v1Request(t, admin, "POST", api+"/alert-rules", AlertRule{Name: "x", Metric: "cpu", Operator: "!="}, http.StatusBadRequest, nil)
+
+	// 旧接口读取同一份数据，并标记为弃用
+	resp, err := admin.Get(ts.URL + "/api/alerts/rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rules []AlertRule
+	json.NewDecoder(resp.Body).Decode(&rules)
+	resp.Body.Close()
+	if len(rules) != 1 || rules[0].Threshold != 80 {
+		t.Fatalf("旧接口返回的告警规则为 %+v", rules)
+	}
+	if resp.Header.Get("Deprecation") != "true" || !strings.Contains(resp.Header.Get("Link"), apiV1Prefix+"/alert-rules") {
+		t.Fatalf("旧接口的响应头为 %v", resp.Header)
+	}
+	v1Request(t, admin, "DELETE", api+"/alert-rules/"+rule.ID, nil, http.StatusNoContent, nil)
+	v1Request(t, admin, "GET", api+"/alert-rules/"+rule.ID, nil, http.StatusNotFound, nil)
+
+	var first, second MonitorStatus
+	v1Request(t, admin, "POST", api+"/monitors", Monitor{Name: "a", Type: "tcp", Target: "127.0.0.1:1"}, http.StatusCreated, &first)
+	v1Request(t, admin, "POST", api+"/monitors", Monitor{Name: "b", Type: "dns", Target: "localhost", DisplayOrder: 99}, http.StatusCreated, &second)
+	if first.Interval != 60 || second.DisplayOrder != first.DisplayOrder+1 {
+		t.Fatalf("新建的服务端探测为 %+v, %+v", first, second)
+	}
+	first.Name = "a2"
+	v1Request(t, admin, "PUT", api+"/monitors/"+first.ID, first.Monitor, http.StatusOK, &first)
+	v1Request(t, admin, "GET", api+"/monitors/"+first.ID, nil, http.StatusOK, &second)
+	if second.Name != "a2" || second.DisplayOrder != first.DisplayOrder {
+		t.Fatalf("修改后的服务端探测为 %+v", second)
+	}
+	v1Request(t, admin, "POST", api+"/monitors", Monitor{Name: "c", Type: "ftp", Target: "x"}, http.StatusBadRequest, nil)
+	v1Request(t, admin, "DELETE", api+"/monitors/"+first.ID, nil, http.StatusNoContent, nil)
+	var monitors []MonitorStatus
+	v1Request(t, admin, "GET", api+"/monitors", nil, http.StatusOK, &monitors)
+	if len(monitors) != 1 || monitors[0].Name != "b" {
+		t.Fatalf("删除后的服务端探测为 %+v", monitors)
+	}
+}
+
+func TestOpenAPISpec(t *testing.T) {
+	server, ts := newTestServer(t)
+
+	// 文档不需要登录
+	var spec struct {
+		OpenAPI string                    `json:"openapi"`
+		Paths   map[string]map[string]any `json:"paths"`
+	}
+	mustGet(t, newCookieClient(), ts.URL+apiV1Prefix+"/openapi.json", &spec)
+	if spec.OpenAPI == "" {
+		t.Fatal("文档中缺少 openapi 版本")
+	}
+
+	// 文档中的路径和方法与注册的路由一一对应
+	routes := server.v1Routes()
+	if len(spec.Paths) != len(routes) {
+		t.Errorf("文档中有 %d 个路径，路由有 %d 个", len(spec.Paths), len(routes))
+	}
+	for _, route := range routes {
+		item, ok := spec.Paths[route.path]
+		if !ok {
+			t.Errorf("文档中缺少 %s", route.path)
+			continue
+		}
+		var documented []string
+		for method := range item {
+			if method != "parameters" {
+				documented = append(documented, strings.ToUpper(method))
+			}
+		}
+		slices.Sort(documented)
+		var registered []string
+		for method := range route.methods {
+			registered = append(registered, method)
+		}
+		slices.Sort(registered)
+		if !slices.Equal(documented, registered) {
+			t.Errorf("%s 的文档方法为 %v，注册的方法为 %v", route.path, documented, registered)
+		}
+	}
+}
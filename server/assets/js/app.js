@@ -56,6 +56,71 @@ const app = createApp({
         const renameForm = reactive({ name: '' });
         const renameError = ref('');
         const isRenaming = ref(false);
+        // 历史指标相关状态
+        const historyClient = ref(null);
+        const historySeries = ref([]);
+        const historyMetric = ref('cpu');
+        const historyRange = ref('1h');
+        const historyPoints = ref([]);
+        const isLoadingHistory = ref(false);
+        // 探测相关状态
+        const checksClient = ref(null);
+        const checkStatuses = ref([]);
+        const isLoadingChecks = ref(false);
+        // 服务端探测相关状态
+        const monitors = ref([]);
+        const monitorForm = reactive({ id: '', name: '', type: 'http', target: '', interval: 60, timeout: 10, expectStatus: '', bodyMatch: '', minCertDays: 0 });
+        const monitorError = ref('');
+        const isSavingMonitor = ref(false);
+        const monitorToDelete = ref(null);
+        const isDeletingMonitor = ref(false);
+        // 状态页设置相关状态
+        const statusPageForm = reactive({ enabled: false, title: '', notice: '' });
+        const statusPageItems = ref([]);
+        const statusPageError = ref('');
+        const isSavingStatusPage = ref(false);
+        // 分组和标签相关状态
+        const groupFilter = ref('');
+        const tagFilter = ref([]);
+        const labelFilter = ref([]);
+        const groupStatsList = ref([]);
+        const clientToGroup = ref(null);
+        const clientGroupForm = reactive({ group: '', tags: '' });
+        const clientGroupError = ref('');
+        const isSavingClientGroup = ref(false);
+        // 维护窗口和故障事件相关状态
+        const maintenances = ref([]);
+        const maintenanceForm = reactive({ id: '', title: '', mode: 'once', startsAt: '', endsAt: '', cron: '', duration: 60, targets: [], groups: [] });
+        const maintenanceError = ref('');
+        const isSavingMaintenance = ref(false);
+        const incidents = ref([]);
+        const incidentForm = reactive({ id: '', title: '', status: 'investigating', message: '' });
+        const incidentError = ref('');
+        const isSavingIncident = ref(false);
+        const importPreview = ref(null);
+        const importError = ref('');
+        const isImporting = ref(false);
+        let importFile = null;
+        const enrollTokens = ref([]);
+        const enrollTokenForm = reactive({ name: '', group: '', maxUses: 0, expiresIn: 24 });
+        const enrollTokenError = ref('');
+        const isSavingEnrollToken = ref(false);
+        const newEnrollToken = ref('');
+        const apiTokens = ref([]);
+        const apiTokenForm = reactive({ name: '', scope: 'read', expiresIn: 24 * 90 });
+        const apiTokenError = ref('');
+        const isSavingAPIToken = ref(false);
+        const newAPIToken = ref('');
+        const apiScopeNames = { read: '只读', write: '读写', admin: '管理' };
+        const authEvents = ref([]);
+        const authLockouts = ref([]);
+        const authResultNames = { success: '成功', failure: '失败', locked: '已锁定' };
+        const incidentStatusNames = {
+            investigating: '调查中',
+            identified: '已定位',
+            monitoring: '观察中',
+            resolved: '已解决'
+        };
         
         // 响应式布局状态
         const isMobileView = ref(window.innerWidth <= 768);
@@ -66,7 +131,7 @@ const app = createApp({
         };
 
         // 模态框实例
-        let loginModal, settingsModal, addClientModal, deleteClientModal, clientIdModal, sortClientsModal, renameClientModal;
+        let loginModal, settingsModal, addClientModal, deleteClientModal, clientIdModal, sortClientsModal, renameClientModal, historyModal, checksModal, monitorModal, deleteMonitorModal, statusPageModal, maintenanceModal, incidentModal, clientGroupModal, enrollTokenModal, apiTokenModal, authEventModal, importModal;
 
         // 初始化Bootstrap模态框
         const initModals = () => {
@@ -77,6 +142,18 @@ const app = createApp({
             clientIdModal = new bootstrap.Modal(document.getElementById('clientIdModal'));
             sortClientsModal = new bootstrap.Modal(document.getElementById('sortClientsModal'));
             renameClientModal = new bootstrap.Modal(document.getElementById('renameClientModal'));
+            historyModal = new bootstrap.Modal(document.getElementById('historyModal'));
+            checksModal = new bootstrap.Modal(document.getElementById('checksModal'));
+            monitorModal = new bootstrap.Modal(document.getElementById('monitorModal'));
+            deleteMonitorModal = new bootstrap.Modal(document.getElementById('deleteMonitorModal'));
+            statusPageModal = new bootstrap.Modal(document.getElementById('statusPageModal'));
+            maintenanceModal = new bootstrap.Modal(document.getElementById('maintenanceModal'));
+            incidentModal = new bootstrap.Modal(document.getElementById('incidentModal'));
+            enrollTokenModal = new bootstrap.Modal(document.getElementById('enrollTokenModal'));
+            apiTokenModal = new bootstrap.Modal(document.getElementById('apiTokenModal'));
+            authEventModal = new bootstrap.Modal(document.getElementById('authEventModal'));
+            importModal = new bootstrap.Modal(document.getElementById('importModal'));
+            clientGroupModal = new bootstrap.Modal(document.getElementById('clientGroupModal'));
         };
 
         // 拖拽选项
@@ -294,6 +371,29 @@ const app = createApp({
                     // console.error('更新客户端数据失败:', error);
                 }
             }, 1000);
+
+            // 服务端探测的间隔至少为 5 秒，不需要每秒刷新
+            fetchMonitors();
+            setInterval(fetchMonitors, 5000);
+            // 维护窗口按分钟生效，每 30 秒刷新一次即可
+            fetchMaintenances();
+            setInterval(fetchMaintenances, 30000);
+            fetchGroups();
+            setInterval(fetchGroups, 5000);
+        };
+
+        // 获取服务端探测列表
+        const fetchMonitors = async () => {
+            try {
+                const response = await fetch('/api/monitors', {
+                    credentials: 'include'
+                });
+                if (response.ok) {
+                    monitors.value = await response.json();
+                }
+            } catch (error) {
+                // console.error('获取服务端探测失败:', error);
+            }
         };
 
         // 根据使用率获取进度条样式
@@ -378,6 +478,8 @@ const app = createApp({
                     setTimeout(async () => {
                         // console.log('重新加载客户端数据');
                         await fetchClients(); // 重新获取客户端数据，包括ID
+                        await fetchMaintenances();
+                        await fetchGroups();
                         // console.log('客户端数据已更新');
                     }, 300);
                 } else {
@@ -408,6 +510,9 @@ const app = createApp({
                 isLoggedIn.value = false;
                 username.value = '';
                 clientsLoaded.value = false; // 重置客户端加载状态
+                maintenances.value = [];
+                groupStatsList.value = [];
+                clearClientFilter();
                 
                 // 重新获取客户端数据（不含敏感信息）
                 await fetchClients();
@@ -533,6 +638,16 @@ const app = createApp({
             }
         };
 
+        // v1 接口的错误信息，响应不是 JSON 格式时返回 fallback
+        const apiErrorMessage = async (response, fallback) => {
+            try {
+                const data = await response.json();
+                return (data.error && data.error.message) || fallback;
+            } catch (error) {
+                return fallback;
+            }
+        };
+
         // 客户端管理相关函数
         const showAddClientModal = () => {
             newClientForm.name = '';
@@ -550,7 +665,7 @@ const app = createApp({
             addClientError.value = '';
 
             try {
-                const response = await fetch('/api/clients/add', {
+                const response = await fetch('/api/v1/clients', {
                     method: 'POST',
                     headers: {
                         'Content-Type': 'application/json'
@@ -568,8 +683,7 @@ const app = createApp({
                     await fetchClients();
                     clientIdModal.show();
                 } else {
-                    const data = await response.text();
-                    addClientError.value = data || '添加客户端失败';
+                    addClientError.value = await apiErrorMessage(response, '添加客户端失败');
                 }
             } catch (error) {
                 // console.error('添加客户端失败:', error);
@@ -590,15 +704,9 @@ const app = createApp({
             isDeletingClient.value = true;
 
             try {
-                const response = await fetch('/api/clients/delete', {
-                    method: 'POST',
-                    headers: {
-                        'Content-Type': 'application/json'
-                    },
-                    credentials: 'include', // 确保发送Cookie
-                    body: JSON.stringify({
-                        id: clientToDelete.value.id
-                    })
+                const response = await fetch(`/api/v1/clients/${encodeURIComponent(clientToDelete.value.id)}`, {
+                    method: 'DELETE',
+                    credentials: 'include' // 确保发送Cookie
                 });
 
                 if (response.ok) {
@@ -670,6 +778,32 @@ const app = createApp({
             });
         };
 
+        // 公开或取消公开客户端的徽章
+        const setClientPublic = async (client, isPublic) => {
+            try {
+                const response = await fetch(`/api/v1/clients/${encodeURIComponent(client.id)}`, {
+                    method: 'PATCH',
+                    headers: {
+                        'Content-Type': 'application/json'
+                    },
+                    credentials: 'include',
+                    body: JSON.stringify({ public: isPublic })
+                });
+                if (response.ok) {
+                    const data = await response.json();
+                    client.badgeId = data.badgeId;
+                    showNotification(isPublic ? '已公开徽章' : '已取消公开，原徽章地址失效', 'success');
+                } else {
+                    showNotification('设置失败', 'error');
+                }
+            } catch (error) {
+                showNotification('网络错误，请稍后重试', 'error');
+            }
+        };
+
+        // 徽章地址
+        const badgeUrl = (client) => `${window.location.origin}/badge/${client.badgeId}.svg`;
+
         // 处理拖拽排序变更
         const onDragChange = async () => {
             // 如果不是登录状态，不处理排序
@@ -834,20 +968,17 @@ const app = createApp({
             
             try {
                 // 发送重命名请求
-                const response = await fetch('/api/clients/rename', {
-                    method: 'POST',
+                const response = await fetch(`/api/v1/clients/${encodeURIComponent(clientToRename.value.id)}`, {
+                    method: 'PATCH',
                     headers: {
                         'Content-Type': 'application/json'
                     },
                     body: JSON.stringify({
-                        id: clientToRename.value.id,
                         name: renameForm.name.trim()
                     }),
                     credentials: 'include'
                 });
                 
-                const data = await response.json();
-                
                 if (response.ok) {
                     // 更新本地客户端数据
                     const clientIndex = clients.value.findIndex(c => c.id === clientToRename.value.id);
@@ -862,7 +993,7 @@ const app = createApp({
                     showNotification('客户端重命名成功', 'success');
                 } else {
                     // 显示错误信息
-                    renameError.value = data.error || '重命名失败，请重试';
+                    renameError.value = await apiErrorMessage(response, '重命名失败，请重试');
                 }
             } catch (error) {
                 console.error('重命名客户端出错:', error);
@@ -872,6 +1003,978 @@ const app = createApp({
             }
         };
 
+        // 指标的显示名称，带标签的指标为 name{k="v"}，与服务端的 Sample.Key 一致
+        const sampleKey = (sample) => {
+            const labels = Object.keys(sample.labels || {}).sort();
+            if (labels.length === 0) {
+                return sample.name;
+            }
+            return sample.name + '{' + labels.map(k => `${k}=${JSON.stringify(sample.labels[k])}`).join(',') + '}';
+        };
+
+        // 格式化自定义指标的值
+        const formatSampleValue = (value) => {
+            return Number.isInteger(value) ? String(value) : value.toFixed(2);
+        };
+
+        // 显示历史指标模态框
+        const showHistoryModal = async (client) => {
+            historyClient.value = client;
+            historySeries.value = [];
+            historyPoints.value = [];
+            historyMetric.value = 'cpu';
+            historyModal.show();
+            try {
+                const response = await fetch(`/api/clients/series?id=${encodeURIComponent(client.id)}`, {
+                    credentials: 'include'
+                });
+                if (response.ok) {
+                    historySeries.value = (await response.json()).sort();
+                }
+            } catch (error) {
+                console.error('获取指标列表出错:', error);
+            }
+            await loadHistory();
+        };
+
+        // 加载当前选择的指标历史数据
+        const loadHistory = async () => {
+            if (!historyClient.value) {
+                return;
+            }
+            isLoadingHistory.value = true;
+            try {
+                const params = new URLSearchParams({
+                    id: historyClient.value.id,
+                    metric: historyMetric.value,
+                    range: historyRange.value
+                });
+                const response = await fetch(`/api/clients/history?${params}`, {
+                    credentials: 'include'
+                });
+                historyPoints.value = response.ok ? await response.json() : [];
+            } catch (error) {
+                console.error('获取历史指标出错:', error);
+                historyPoints.value = [];
+            } finally {
+                isLoadingHistory.value = false;
+            }
+        };
+
+        // 历史曲线，坐标系为 600x200 的 SVG 视图
+        const historyChart = computed(() => {
+            const points = historyPoints.value;
+            if (points.length === 0) {
+                return null;
+            }
+            const times = points.map(p => new Date(p.time).getTime());
+            const values = points.map(p => p.value);
+            const minTime = Math.min(...times), maxTime = Math.max(...times);
+            const minValue = Math.min(0, ...values), maxValue = Math.max(...values);
+            const x = t => maxTime === minTime ? 300 : (t - minTime) / (maxTime - minTime) * 600;
+            const y = v => maxValue === minValue ? 100 : 200 - (v - minValue) / (maxValue - minValue) * 190;
+            return {
+                path: points.map((p, i) => `${x(times[i]).toFixed(1)},${y(values[i]).toFixed(1)}`).join(' '),
+                min: formatSampleValue(Math.min(...values)),
+                max: formatSampleValue(maxValue),
+                last: formatSampleValue(values[values.length - 1]),
+                from: new Date(minTime).toLocaleString(),
+                to: new Date(maxTime).toLocaleString()
+            };
+        });
+
+        // 显示探测详情模态框
+        const showChecksModal = async (client) => {
+            checksClient.value = client;
+            checkStatuses.value = [];
+            isLoadingChecks.value = true;
+            checksModal.show();
+            try {
+                const response = await fetch(`/api/clients/checks?id=${encodeURIComponent(client.id)}`, {
+                    credentials: 'include'
+                });
+                if (response.ok) {
+                    checkStatuses.value = await response.json();
+                }
+            } catch (error) {
+                console.error('获取探测结果出错:', error);
+            } finally {
+                isLoadingChecks.value = false;
+            }
+        };
+
+        // 显示服务端探测详情，与客户端探测共用同一个模态框
+        const showMonitorStatus = async (monitor) => {
+            checksClient.value = monitor;
+            checkStatuses.value = [];
+            isLoadingChecks.value = true;
+            checksModal.show();
+            try {
+                const response = await fetch(`/api/monitors/status?id=${encodeURIComponent(monitor.id)}`, {
+                    credentials: 'include'
+                });
+                if (response.ok) {
+                    checkStatuses.value = [await response.json()];
+                }
+            } catch (error) {
+                console.error('获取探测结果出错:', error);
+            } finally {
+                isLoadingChecks.value = false;
+            }
+        };
+
+        // 显示添加或编辑服务端探测的模态框，monitor 为空时添加
+        const showMonitorModal = (monitor) => {
+            Object.assign(monitorForm, {
+                id: '', name: '', type: 'http', target: '', interval: 60, timeout: 10,
+                expectStatus: '', bodyMatch: '', minCertDays: 0
+            });
+            if (monitor) {
+                Object.assign(monitorForm, {
+                    id: monitor.id,
+                    name: monitor.name,
+                    type: monitor.type,
+                    target: monitor.target,
+                    interval: monitor.interval,
+                    timeout: monitor.timeout,
+                    expectStatus: (monitor.expectStatus || []).join(','),
+                    bodyMatch: monitor.bodyMatch || '',
+                    minCertDays: monitor.minCertDays || 0
+                });
+            }
+            monitorError.value = '';
+            monitorModal.show();
+        };
+
+        // 保存服务端探测
+        const saveMonitor = async () => {
+            if (!monitorForm.name || !monitorForm.target) {
+                monitorError.value = '请输入名称和目标';
+                return;
+            }
+            const expectStatus = monitorForm.expectStatus.split(',').map(v => v.trim()).filter(v => v !== '').map(Number);
+            if (expectStatus.some(v => !Number.isInteger(v))) {
+                monitorError.value = '期望状态码应为以逗号分隔的数字';
+                return;
+            }
+
+            isSavingMonitor.value = true;
+            monitorError.value = '';
+            try {
+                // 没有ID时添加，否则替换已有探测的设置
+                const url = monitorForm.id ? `/api/v1/monitors/${encodeURIComponent(monitorForm.id)}` : '/api/v1/monitors';
+                const response = await fetch(url, {
+                    method: monitorForm.id ? 'PUT' : 'POST',
+                    headers: {
+                        'Content-Type': 'application/json'
+                    },
+                    credentials: 'include',
+                    body: JSON.stringify({
+                        name: monitorForm.name,
+                        type: monitorForm.type,
+                        target: monitorForm.target,
+                        interval: Number(monitorForm.interval),
+                        timeout: Number(monitorForm.timeout),
+                        expectStatus: expectStatus,
+                        bodyMatch: monitorForm.bodyMatch,
+                        minCertDays: Number(monitorForm.minCertDays)
+                    })
+                });
+                if (response.ok) {
+                    monitorModal.hide();
+                    await fetchMonitors();
+                } else {
+                    monitorError.value = await apiErrorMessage(response, '保存失败');
+                }
+            } catch (error) {
+                monitorError.value = '网络错误，请稍后重试';
+            } finally {
+                isSavingMonitor.value = false;
+            }
+        };
+
+        const confirmDeleteMonitor = (monitor) => {
+            monitorToDelete.value = monitor;
+            deleteMonitorModal.show();
+        };
+
+        // 删除服务端探测
+        const deleteMonitor = async () => {
+            if (!monitorToDelete.value) return;
+
+            isDeletingMonitor.value = true;
+            try {
+                const response = await fetch(`/api/v1/monitors/${encodeURIComponent(monitorToDelete.value.id)}`, {
+                    method: 'DELETE',
+                    credentials: 'include'
+                });
+                if (response.ok) {
+                    deleteMonitorModal.hide();
+                    await fetchMonitors();
+                } else {
+                    showNotification('删除失败', 'error');
+                }
+            } catch (error) {
+                showNotification('网络错误，请稍后重试', 'error');
+            } finally {
+                isDeletingMonitor.value = false;
+            }
+        };
+
+        // 显示状态页设置，可选的对象为所有客户端、客户端探测和站点监控
+        const showStatusPageModal = async () => {
+            statusPageError.value = '';
+            let page = { enabled: false, title: '', notice: '', items: [] };
+            try {
+                const response = await fetch('/api/status-page', {
+                    credentials: 'include'
+                });
+                if (response.ok) {
+                    page = await response.json();
+                }
+            } catch (error) {
+                console.error('获取状态页设置出错:', error);
+            }
+            Object.assign(statusPageForm, { enabled: page.enabled, title: page.title, notice: page.notice });
+
+            const candidates = [];
+            for (const client of clients.value) {
+                candidates.push({ kind: 'client', id: client.id, label: client.name });
+                for (const check of client.checks || []) {
+                    candidates.push({ kind: 'check', id: `${client.id}/${check.name}`, label: `${client.name} / ${check.name}` });
+                }
+            }
+            for (const monitor of monitors.value) {
+                candidates.push({ kind: 'monitor', id: monitor.id, label: monitor.name });
+            }
+
+            // 已公开的对象按保存的顺序排在前面
+            const selected = page.items.map(item => {
+                const candidate = candidates.find(c => c.kind === item.kind && c.id === item.id);
+                return candidate && { ...candidate, selected: true, name: item.name };
+            }).filter(Boolean);
+            const rest = candidates
+                .filter(c => !page.items.some(item => item.kind === c.kind && item.id === c.id))
+                .map(c => ({ ...c, selected: false, name: '' }));
+            statusPageItems.value = [...selected, ...rest];
+            statusPageModal.show();
+        };
+
+        // 调整状态页上对象的顺序
+        const moveStatusPageItem = (index, delta) => {
+            const items = statusPageItems.value;
+            const target = index + delta;
+            if (target < 0 || target >= items.length) return;
+            [items[index], items[target]] = [items[target], items[index]];
+        };
+
+        // 保存状态页设置
+        const saveStatusPage = async () => {
+            isSavingStatusPage.value = true;
+            statusPageError.value = '';
+            try {
+                const response = await fetch('/api/status-page/save', {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json'
+                    },
+                    credentials: 'include',
+                    body: JSON.stringify({
+                        enabled: statusPageForm.enabled,
+                        title: statusPageForm.title,
+                        notice: statusPageForm.notice,
+                        items: statusPageItems.value
+                            .filter(item => item.selected)
+                            .map(item => ({ kind: item.kind, id: item.id, name: item.name }))
+                    })
+                });
+                if (response.ok) {
+                    statusPageModal.hide();
+                    showNotification('状态页设置已保存', 'success');
+                } else {
+                    statusPageError.value = (await response.text()) || '保存失败';
+                }
+            } catch (error) {
+                statusPageError.value = '网络错误，请稍后重试';
+            } finally {
+                isSavingStatusPage.value = false;
+            }
+        };
+
+        // 所有已使用的分组，包括上级分组，按路径排序
+        const allGroups = computed(() => {
+            const groups = new Set();
+            for (const client of clients.value) {
+                if (!client.group) continue;
+                const parts = client.group.split('/');
+                parts.forEach((_, i) => groups.add(parts.slice(0, i + 1).join('/')));
+            }
+            return [...groups].sort();
+        });
+
+        // 所有已使用的标签，key=value 形式的标签作为客户端标签筛选，不在这里重复列出
+        const allTags = computed(() => {
+            return [...new Set(clients.value.flatMap(client => client.tags || []))].filter(tag => !tag.includes('=')).sort();
+        });
+
+        // 客户端的全部标签：客户端声明的标签被管理员设置的 key=value 同名标签覆盖，与服务端的规则一致
+        const clientLabels = (client) => {
+            const labels = { ...(client.labels || {}) };
+            for (const tag of client.tags || []) {
+                const index = tag.indexOf('=');
+                const name = tag.slice(0, index);
+                if (index > 0 && index < tag.length - 1 && /^[a-zA-Z_][a-zA-Z0-9_]*$/.test(name) &&
+                    !['id', 'name', 'group'].includes(name)) {
+                    labels[name] = tag.slice(index + 1);
+                }
+            }
+            return labels;
+        };
+
+        // 所有客户端的标签，形式为 key=value
+        const allLabels = computed(() => {
+            const labels = clients.value.flatMap(client => Object.entries(clientLabels(client)).map(([k, v]) => `${k}=${v}`));
+            return [...new Set(labels)].sort();
+        });
+
+        const filterActive = computed(() => groupFilter.value !== '' || tagFilter.value.length > 0 || labelFilter.value.length > 0);
+
+        // 判断客户端是否满足当前的筛选条件，与服务端 /api/clients 的筛选规则一致
+        const clientVisible = (client) => {
+            const group = groupFilter.value;
+            if (group && client.group !== group && !(client.group || '').startsWith(group + '/')) return false;
+            if (!tagFilter.value.every(tag => (client.tags || []).includes(tag))) return false;
+            const labels = clientLabels(client);
+            return labelFilter.value.every(label => {
+                const index = label.indexOf('=');
+                return labels[label.slice(0, index)] === label.slice(index + 1);
+            });
+        };
+
+        // 切换标签或客户端标签筛选
+        const toggleFilter = (list, value) => {
+            const index = list.value.indexOf(value);
+            if (index >= 0) {
+                list.value.splice(index, 1);
+            } else {
+                list.value.push(value);
+            }
+            fetchGroups();
+        };
+        const toggleTagFilter = (tag) => toggleFilter(tagFilter, tag);
+        const toggleLabelFilter = (label) => toggleFilter(labelFilter, label);
+
+        const clearClientFilter = () => {
+            groupFilter.value = '';
+            tagFilter.value = [];
+            labelFilter.value = [];
+            fetchGroups();
+        };
+
+        // 获取分组汇总，按当前的标签筛选
+        const fetchGroups = async () => {
+            if (!isLoggedIn.value) return;
+            try {
+                const query = [
+                    ...tagFilter.value.map(tag => 'tag=' + encodeURIComponent(tag)),
+                    ...labelFilter.value.map(label => 'label=' + encodeURIComponent(label))
+                ].join('&');
+                const response = await fetch('/api/groups' + (query ? '?' + query : ''), {
+                    credentials: 'include'
+                });
+                if (response.ok) {
+                    groupStatsList.value = await response.json();
+                }
+            } catch (error) {
+                // console.error('获取分组汇总失败:', error);
+            }
+        };
+
+        // 当前分组的下一级分组
+        const childGroups = computed(() => {
+            const parent = groupFilter.value;
+            return groupStatsList.value.filter(group => {
+                const index = group.path.lastIndexOf('/');
+                return (index < 0 ? '' : group.path.slice(0, index)) === parent;
+            });
+        });
+
+        // 显示分组与标签设置
+        const showClientGroupModal = (client) => {
+            clientToGroup.value = client;
+            clientGroupForm.group = client.group || '';
+            clientGroupForm.tags = (client.tags || []).join(', ');
+            clientGroupError.value = '';
+            clientGroupModal.show();
+        };
+
+        // 保存分组与标签，标签按与原标签的差异添加和移除
+        const saveClientGroup = async () => {
+            const client = clientToGroup.value;
+            if (!client) return;
+            const tags = clientGroupForm.tags.split(/[,，]/).map(tag => tag.trim()).filter(tag => tag !== '');
+            const oldTags = client.tags || [];
+
+            isSavingClientGroup.value = true;
+            clientGroupError.value = '';
+            try {
+                const post = (url, body) => fetch(url, {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json'
+                    },
+                    credentials: 'include',
+                    body: JSON.stringify(body)
+                });
+                let response = await post('/api/clients/group', { ids: [client.id], group: clientGroupForm.group });
+                if (response.ok) {
+                    response = await post('/api/clients/tags', {
+                        ids: [client.id],
+                        add: tags.filter(tag => !oldTags.includes(tag)),
+                        remove: oldTags.filter(tag => !tags.includes(tag))
+                    });
+                }
+                if (response.ok) {
+                    clientGroupModal.hide();
+                    await fetchClients();
+                    await fetchGroups();
+                } else {
+                    clientGroupError.value = (await response.text()) || '保存失败';
+                }
+            } catch (error) {
+                clientGroupError.value = '网络错误，请稍后重试';
+            } finally {
+                isSavingClientGroup.value = false;
+            }
+        };
+
+        // 获取维护窗口，仅登录后可用
+        const fetchMaintenances = async () => {
+            if (!isLoggedIn.value) return;
+            try {
+                const response = await fetch('/api/maintenances', {
+                    credentials: 'include'
+                });
+                if (response.ok) {
+                    maintenances.value = await response.json();
+                }
+            } catch (error) {
+                // console.error('获取维护窗口失败:', error);
+            }
+        };
+
+        // 当前生效的维护窗口
+        const activeMaintenances = computed(() => maintenances.value.filter(m => m.active));
+
+        // 判断客户端或站点监控是否处于维护中，group 为客户端的分组，与服务端的规则一致
+        const inMaintenance = (id, group) => {
+            return activeMaintenances.value.some(m => {
+                const groups = m.groups || [];
+                if (m.targets.length === 0 && groups.length === 0) return true;
+                if (m.targets.includes(id)) return true;
+                return !!group && groups.some(g => group === g || group.startsWith(g + '/'));
+            });
+        };
+
+        // 维护窗口可以选择的对象
+        const maintenanceCandidates = computed(() => [
+            ...clients.value.map(client => ({ id: client.id, name: client.name, monitor: false })),
+            ...monitors.value.map(monitor => ({ id: monitor.id, name: monitor.name, monitor: true }))
+        ]);
+
+        // 维护时间的说明文字
+        const maintenanceSchedule = (m) => {
+            if (m.cron) return `cron ${m.cron}，每次 ${m.duration} 分钟`;
+            return `${new Date(m.startsAt).toLocaleString()} 至 ${new Date(m.endsAt).toLocaleString()}`;
+        };
+
+        // 维护对象的说明文字，已删除的对象不显示
+        const maintenanceTargets = (m) => {
+            const groups = m.groups || [];
+            if (m.targets.length === 0 && groups.length === 0) return '全部';
+            const names = [
+                ...groups.map(group => `分组 ${group}`),
+                ...maintenanceCandidates.value.filter(c => m.targets.includes(c.id)).map(c => c.name)
+            ];
+            return names.length > 0 ? names.join('、') : '对象已删除';
+        };
+
+        // 将时间转换为 datetime-local 输入框使用的本地时间格式
+        const toLocalInput = (value) => {
+            if (!value) return '';
+            const date = new Date(value);
+            date.setMinutes(date.getMinutes() - date.getTimezoneOffset());
+            return date.toISOString().slice(0, 16);
+        };
+
+        // 编辑维护窗口，m 为空时清空表单用于添加
+        const editMaintenance = (m) => {
+            Object.assign(maintenanceForm, {
+                id: '', title: '', mode: 'once', startsAt: '', endsAt: '', cron: '', duration: 60, targets: [], groups: []
+            });
+            if (m) {
+                Object.assign(maintenanceForm, {
+                    id: m.id,
+                    title: m.title,
+                    mode: m.cron ? 'cron' : 'once',
+                    startsAt: toLocalInput(m.startsAt),
+                    endsAt: toLocalInput(m.endsAt),
+                    cron: m.cron || '',
+                    duration: m.duration || 60,
+                    targets: [...m.targets],
+                    groups: [...(m.groups || [])]
+                });
+            }
+            maintenanceError.value = '';
+        };
+
+        const showMaintenanceModal = async () => {
+            editMaintenance(null);
+            await fetchMaintenances();
+            maintenanceModal.show();
+        };
+
+        // 保存维护窗口
+        const saveMaintenance = async () => {
+            const body = {
+                id: maintenanceForm.id,
+                title: maintenanceForm.title,
+                targets: maintenanceForm.targets,
+                groups: maintenanceForm.groups
+            };
+            if (maintenanceForm.mode === 'cron') {
+                body.cron = maintenanceForm.cron;
+                body.duration = Number(maintenanceForm.duration);
+            } else {
+                if (!maintenanceForm.startsAt || !maintenanceForm.endsAt) {
+                    maintenanceError.value = '请选择开始和结束时间';
+                    return;
+                }
+                body.startsAt = new Date(maintenanceForm.startsAt).toISOString();
+                body.endsAt = new Date(maintenanceForm.endsAt).toISOString();
+            }
+
+            isSavingMaintenance.value = true;
+            maintenanceError.value = '';
+            try {
+                const response = await fetch('/api/maintenances/save', {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json'
+                    },
+                    credentials: 'include',
+                    body: JSON.stringify(body)
+                });
+                if (response.ok) {
+                    editMaintenance(null);
+                    await fetchMaintenances();
+                    showNotification('维护窗口已保存', 'success');
+                } else {
+                    maintenanceError.value = (await response.text()) || '保存失败';
+                }
+            } catch (error) {
+                maintenanceError.value = '网络错误，请稍后重试';
+            } finally {
+                isSavingMaintenance.value = false;
+            }
+        };
+
+        // 删除维护窗口
+        const deleteMaintenance = async (m) => {
+            try {
+                const response = await fetch('/api/maintenances/delete', {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json'
+                    },
+                    credentials: 'include',
+                    body: JSON.stringify({
+                        id: m.id
+                    })
+                });
+                if (response.ok) {
+                    if (maintenanceForm.id === m.id) editMaintenance(null);
+                    await fetchMaintenances();
+                } else {
+                    showNotification('删除失败', 'error');
+                }
+            } catch (error) {
+                showNotification('网络错误，请稍后重试', 'error');
+            }
+        };
+
+        // 获取故障事件
+        const fetchIncidents = async () => {
+            try {
+                const response = await fetch('/api/incidents', {
+                    credentials: 'include'
+                });
+                if (response.ok) {
+                    incidents.value = await response.json();
+                }
+            } catch (error) {
+                console.error('获取故障事件出错:', error);
+            }
+        };
+
+        // 为故障事件添加进展，incident 为空时清空表单用于创建
+        const editIncident = (incident) => {
+            Object.assign(incidentForm, { id: '', title: '', status: 'investigating', message: '' });
+            if (incident) {
+                Object.assign(incidentForm, { id: incident.id, title: incident.title, status: incident.status });
+            }
+            incidentError.value = '';
+        };
+
+        const showIncidentModal = async () => {
+            editIncident(null);
+            await fetchIncidents();
+            incidentModal.show();
+        };
+
+        // 创建故障事件或添加进展
+        const saveIncident = async () => {
+            if ((!incidentForm.id && !incidentForm.title) || !incidentForm.message) {
+                incidentError.value = '请输入标题和进展说明';
+                return;
+            }
+
+            isSavingIncident.value = true;
+            incidentError.value = '';
+            try {
+                const response = await fetch(incidentForm.id ? '/api/incidents/update' : '/api/incidents/create', {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json'
+                    },
+                    credentials: 'include',
+                    body: JSON.stringify({
+                        id: incidentForm.id,
+                        title: incidentForm.title,
+                        status: incidentForm.status,
+                        message: incidentForm.message
+                    })
+                });
+                if (response.ok) {
+                    editIncident(null);
+                    await fetchIncidents();
+                } else {
+                    incidentError.value = (await response.text()) || '保存失败';
+                }
+            } catch (error) {
+                incidentError.value = '网络错误，请稍后重试';
+            } finally {
+                isSavingIncident.value = false;
+            }
+        };
+
+        // 删除故障事件
+        const deleteIncident = async (incident) => {
+            try {
+                const response = await fetch('/api/incidents/delete', {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json'
+                    },
+                    credentials: 'include',
+                    body: JSON.stringify({
+                        id: incident.id
+                    })
+                });
+                if (response.ok) {
+                    if (incidentForm.id === incident.id) editIncident(null);
+                    await fetchIncidents();
+                } else {
+                    showNotification('删除失败', 'error');
+                }
+            } catch (error) {
+                showNotification('网络错误，请稍后重试', 'error');
+            }
+        };
+
+        const showImportModal = () => {
+            importPreview.value = null;
+            importError.value = '';
+            importFile = null;
+            document.getElementById('importFile').value = '';
+            importModal.show();
+        };
+
+        // 导入客户端文件，按扩展名判断格式，dryRun 时只返回将要进行的修改
+        const importClients = async (dryRun) => {
+            const format = importFile.name.toLowerCase().endsWith('.csv') ? 'csv' : 'json';
+            const response = await fetch(`/api/clients/import?format=${format}&dryRun=${dryRun}`, {
+                method: 'POST',
+                credentials: 'include',
+                body: await importFile.text()
+            });
+            if (!response.ok) {
+                throw new Error((await response.text()) || '导入失败');
+            }
+            return response.json();
+        };
+
+        // 选择文件后预览导入的修改
+        const previewImport = async (event) => {
+            importFile = event.target.files[0] || null;
+            importPreview.value = null;
+            importError.value = '';
+            if (!importFile) return;
+            try {
+                importPreview.value = await importClients(true);
+            } catch (error) {
+                importError.value = error.message;
+            }
+        };
+
+        const applyImport = async () => {
+            isImporting.value = true;
+            importError.value = '';
+            try {
+                const result = await importClients(false);
+                importModal.hide();
+                showNotification(`已新建 ${result.created} 个、修改 ${result.updated} 个客户端`, 'success');
+                await fetchClients();
+            } catch (error) {
+                importError.value = error.message;
+            } finally {
+                isImporting.value = false;
+            }
+        };
+
+        // 获取注册令牌
+        const fetchEnrollTokens = async () => {
+            try {
+                const response = await fetch('/api/enroll-tokens', {
+                    credentials: 'include'
+                });
+                if (response.ok) {
+                    enrollTokens.value = await response.json();
+                }
+            } catch (error) {
+                console.error('获取注册令牌出错:', error);
+            }
+        };
+
+        const showEnrollTokenModal = async () => {
+            Object.assign(enrollTokenForm, { name: '', group: '', maxUses: 0, expiresIn: 24 });
+            enrollTokenError.value = '';
+            newEnrollToken.value = '';
+            await fetchEnrollTokens();
+            enrollTokenModal.show();
+        };
+
+        // 创建注册令牌，令牌只在创建后显示一次
+        const createEnrollToken = async () => {
+            if (!enrollTokenForm.name.trim()) {
+                enrollTokenError.value = '请输入名称';
+                return;
+            }
+
+            isSavingEnrollToken.value = true;
+            enrollTokenError.value = '';
+            try {
+                const expiresIn = enrollTokenForm.expiresIn;
+                const response = await fetch('/api/enroll-tokens/create', {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json'
+                    },
+                    credentials: 'include',
+                    body: JSON.stringify({
+                        name: enrollTokenForm.name,
+                        group: enrollTokenForm.group,
+                        maxUses: enrollTokenForm.maxUses || 0,
+                        expiresAt: expiresIn ? new Date(Date.now() + expiresIn * 3600 * 1000).toISOString() : undefined
+                    })
+                });
+                if (response.ok) {
+                    const data = await response.json();
+                    newEnrollToken.value = data.token;
+                    Object.assign(enrollTokenForm, { name: '', group: '' });
+                    await fetchEnrollTokens();
+                } else {
+                    enrollTokenError.value = (await response.text()) || '创建失败';
+                }
+            } catch (error) {
+                enrollTokenError.value = '网络错误，请稍后重试';
+            } finally {
+                isSavingEnrollToken.value = false;
+            }
+        };
+
+        // 删除注册令牌，已注册的客户端不受影响
+        const deleteEnrollToken = async (token) => {
+            try {
+                const response = await fetch('/api/enroll-tokens/delete', {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json'
+                    },
+                    credentials: 'include',
+                    body: JSON.stringify({
+                        id: token.id
+                    })
+                });
+                if (response.ok) {
+                    await fetchEnrollTokens();
+                } else {
+                    showNotification('删除失败', 'error');
+                }
+            } catch (error) {
+                showNotification('网络错误，请稍后重试', 'error');
+            }
+        };
+
+        // 获取当前用户的个人令牌
+        const fetchAPITokens = async () => {
+            try {
+                const response = await fetch('/api/tokens', {
+                    credentials: 'include'
+                });
+                if (response.ok) {
+                    apiTokens.value = await response.json();
+                }
+            } catch (error) {
+                console.error('获取个人令牌出错:', error);
+            }
+        };
+
+        const showAPITokenModal = async () => {
+            Object.assign(apiTokenForm, { name: '', scope: 'read', expiresIn: 24 * 90 });
+            apiTokenError.value = '';
+            newAPIToken.value = '';
+            await fetchAPITokens();
+            apiTokenModal.show();
+        };
+
+        // 创建个人令牌，令牌只在创建后显示一次
+        const createAPIToken = async () => {
+            if (!apiTokenForm.name.trim()) {
+                apiTokenError.value = '请输入名称';
+                return;
+            }
+
+            isSavingAPIToken.value = true;
+            apiTokenError.value = '';
+            try {
+                const expiresIn = apiTokenForm.expiresIn;
+                const response = await fetch('/api/tokens/create', {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json'
+                    },
+                    credentials: 'include',
+                    body: JSON.stringify({
+                        name: apiTokenForm.name,
+                        scopes: [apiTokenForm.scope],
+                        expiresAt: expiresIn ? new Date(Date.now() + expiresIn * 3600 * 1000).toISOString() : undefined
+                    })
+                });
+                if (response.ok) {
+                    const data = await response.json();
+                    newAPIToken.value = data.token;
+                    apiTokenForm.name = '';
+                    await fetchAPITokens();
+                } else {
+                    apiTokenError.value = (await response.text()) || '创建失败';
+                }
+            } catch (error) {
+                apiTokenError.value = '网络错误，请稍后重试';
+            } finally {
+                isSavingAPIToken.value = false;
+            }
+        };
+
+        // 吊销个人令牌，使用该令牌的脚本立即失去访问权限
+        const deleteAPIToken = async (token) => {
+            try {
+                const response = await fetch('/api/tokens/delete', {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json'
+                    },
+                    credentials: 'include',
+                    body: JSON.stringify({
+                        id: token.id
+                    })
+                });
+                if (response.ok) {
+                    await fetchAPITokens();
+                } else {
+                    showNotification('吊销失败', 'error');
+                }
+            } catch (error) {
+                showNotification('网络错误，请稍后重试', 'error');
+            }
+        };
+
+        // 获取最近的登录记录和正在锁定的 IP、用户名
+        const fetchAuthEvents = async () => {
+            try {
+                const response = await fetch('/api/auth/events', {
+                    credentials: 'include'
+                });
+                if (response.ok) {
+                    const data = await response.json();
+                    authEvents.value = data.events;
+                    authLockouts.value = data.lockouts;
+                }
+            } catch (error) {
+                console.error('获取登录记录出错:', error);
+            }
+        };
+
+        const showAuthEventModal = async () => {
+            await fetchAuthEvents();
+            authEventModal.show();
+        };
+
+        // 提前解除锁定
+        const unlockLogin = async (lockout) => {
+            try {
+                const response = await fetch('/api/auth/unlock', {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json'
+                    },
+                    credentials: 'include',
+                    body: JSON.stringify({
+                        kind: lockout.kind,
+                        value: lockout.value
+                    })
+                });
+                if (response.ok) {
+                    showNotification('已解除锁定', 'success');
+                } else {
+                    showNotification('解除失败', 'error');
+                }
+                await fetchAuthEvents();
+            } catch (error) {
+                showNotification('网络错误，请稍后重试', 'error');
+            }
+        };
+
+        // 格式化可用率
+        const formatUptime = (value) => {
+            return value === null || value === undefined ? '-' : value.toFixed(2) + '%';
+        };
+
+        // 每天可用率色块的样式
+        const uptimeDayClass = (day) => {
+            if (day.total === 0) return 'uptime-none';
+            const ratio = day.up / day.total;
+            if (ratio >= 0.999) return 'uptime-good';
+            if (ratio >= 0.95) return 'uptime-degraded';
+            return 'uptime-bad';
+        };
+
+        // 每天可用率色块的提示文字
+        const uptimeDayTitle = (day) => {
+            if (day.total === 0) return `${day.date} 无数据`;
+            return `${day.date} 可用率 ${(day.up * 100 / day.total).toFixed(2)}%`;
+        };
+
         // 初始化应用
         onMounted(() => {
             // 初始化模态框
@@ -948,10 +2051,116 @@ const app = createApp({
             onDragChange,
             setTheme,
             copyToClipboard,
+            setClientPublic,
+            badgeUrl,
             showRenameClientModal,
             renameClient,
             formatNetworkSpeed,
             getNetworkSpeedPercent,
+            historyClient,
+            historySeries,
+            historyMetric,
+            historyRange,
+            isLoadingHistory,
+            historyChart,
+            sampleKey,
+            formatSampleValue,
+            showHistoryModal,
+            loadHistory,
+            checksClient,
+            checkStatuses,
+            isLoadingChecks,
+            showChecksModal,
+            formatUptime,
+            statusPageForm,
+            statusPageItems,
+            statusPageError,
+            isSavingStatusPage,
+            showStatusPageModal,
+            moveStatusPageItem,
+            saveStatusPage,
+            groupFilter,
+            tagFilter,
+            labelFilter,
+            allGroups,
+            allTags,
+            allLabels,
+            toggleLabelFilter,
+            filterActive,
+            clientVisible,
+            toggleTagFilter,
+            clearClientFilter,
+            childGroups,
+            clientToGroup,
+            clientGroupForm,
+            clientGroupError,
+            isSavingClientGroup,
+            showClientGroupModal,
+            saveClientGroup,
+            maintenances,
+            maintenanceForm,
+            maintenanceError,
+            isSavingMaintenance,
+            activeMaintenances,
+            inMaintenance,
+            maintenanceCandidates,
+            maintenanceSchedule,
+            maintenanceTargets,
+            editMaintenance,
+            showMaintenanceModal,
+            saveMaintenance,
+            deleteMaintenance,
+            incidents,
+            incidentForm,
+            incidentError,
+            isSavingIncident,
+            incidentStatusNames,
+            editIncident,
+            showIncidentModal,
+            saveIncident,
+            deleteIncident,
+            importPreview,
+            importError,
+            isImporting,
+            showImportModal,
+            previewImport,
+            applyImport,
+            enrollTokens,
+            enrollTokenForm,
+            enrollTokenError,
+            isSavingEnrollToken,
+            newEnrollToken,
+            showEnrollTokenModal,
+            createEnrollToken,
+            deleteEnrollToken,
+            apiTokens,
+            apiTokenForm,
+            apiTokenError,
+            isSavingAPIToken,
+            newAPIToken,
+            apiScopeNames,
+            apiBaseUrl: window.location.origin,
+            showAPITokenModal,
+            createAPIToken,
+            deleteAPIToken,
+            authEvents,
+            authLockouts,
+            authResultNames,
+            showAuthEventModal,
+            unlockLogin,
+            monitors,
+            monitorForm,
+            monitorError,
+            isSavingMonitor,
+            monitorToDelete,
+            isDeletingMonitor,
+            showMonitorStatus,
+            showMonitorModal,
+            saveMonitor,
+            confirmDeleteMonitor,
+            deleteMonitor,
+            uptimeDayClass,
+            uptimeDayTitle,
             // 导出响应式布局状态
             isMobileView
         };
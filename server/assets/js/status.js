@@ -0,0 +1,134 @@
+// 公开状态页，只读取 /status/data，不访问任何管理接口
+
+// 创建带有类名和文字的元素，文字一律作为纯文本插入
+function el(tag, className, text) {
+    const node = document.createElement(tag);
+    if (className) node.className = className;
+    if (text !== undefined) node.textContent = text;
+    return node;
+}
+
+// 格式化可用率
+function formatUptime(value) {
+    return value === null || value === undefined ? '-' : value.toFixed(2) + '%';
+}
+
+// 每天可用率色块的样式，与主页一致
+function uptimeDayClass(day) {
+    if (day.total === 0) return 'uptime-none';
+    const ratio = day.up / day.total;
+    if (ratio >= 0.999) return 'uptime-good';
+    if (ratio >= 0.95) return 'uptime-degraded';
+    return 'uptime-bad';
+}
+
+function uptimeDayTitle(day) {
+    if (day.total === 0) return `${day.date} 无数据`;
+    return `${day.date} 可用率 ${(day.up * 100 / day.total).toFixed(2)}%`;
+}
+
+// 故障事件状态的显示名称
+const incidentStatusNames = {
+    investigating: '调查中',
+    identified: '已定位',
+    monitoring: '观察中',
+    resolved: '已解决'
+};
+
+// 渲染一个故障事件及其进展，最新的进展在前
+function renderIncident(incident) {
+    const card = el('div', 'status-incident ' + (incident.status === 'resolved' ? 'resolved' : 'ongoing'));
+
+    const header = el('div', 'd-flex justify-content-between align-items-center mb-2');
+    header.append(
+        el('strong', '', incident.title),
+        el('span', 'incident-status', incidentStatusNames[incident.status] || incident.status)
+    );
+    card.append(header);
+
+    for (const update of [...incident.updates].reverse()) {
+        const row = el('div', 'incident-update');
+        row.append(
+            el('span', 'fw-semibold me-2', incidentStatusNames[update.status] || update.status),
+            el('span', '', update.message),
+            el('div', 'small text-secondary', new Date(update.time).toLocaleString())
+        );
+        card.append(row);
+    }
+    return card;
+}
+
+// 渲染一个对象的状态
+function renderItem(item) {
+    const card = el('div', 'status-item');
+
+    const header = el('div', 'd-flex justify-content-between align-items-center mb-2');
+    const name = el('div');
+    const dot = el('span', 'check-dot ' + (item.up === null ? '' : item.up ? 'up' : 'down'));
+    name.append(dot, el('strong', 'ms-2', item.name));
+    const state = item.up === null ? '暂无数据' : item.up ? '正常' : '异常';
+    header.append(name, el('span', item.up === false ? 'text-danger' : 'text-secondary', state));
+    card.append(header);
+
+    const bars = el('div', 'uptime-bars');
+    for (const day of item.days) {
+        const bar = el('span', 'uptime-day ' + uptimeDayClass(day));
+        bar.title = uptimeDayTitle(day);
+        bars.append(bar);
+    }
+    card.append(bars);
+
+    const footer = el('div', 'd-flex justify-content-between small text-secondary mt-1');
+    footer.append(
+        el('span', '', `24 小时 ${formatUptime(item.uptime24h)}`),
+        el('span', '', `7 天 ${formatUptime(item.uptime7d)}`),
+        el('span', '', `30 天 ${formatUptime(item.uptime30d)}`)
+    );
+    card.append(footer);
+    return card;
+}
+
+// 加载并渲染状态页数据
+async function loadStatus() {
+    const summary = document.getElementById('statusSummary');
+    let data;
+    try {
+        const response = await fetch('/status/data');
+        if (response.status === 404) {
+            summary.className = 'status-summary text-secondary';
+            summary.textContent = '状态页未启用';
+            return;
+        }
+        if (!response.ok) throw new Error(response.statusText);
+        data = await response.json();
+    } catch (error) {
+        summary.className = 'status-summary text-secondary';
+        summary.textContent = '无法获取状态，请稍后刷新';
+        return;
+    }
+
+    document.title = data.title;
+    document.getElementById('statusTitle').textContent = data.title;
+
+    const down = data.items.filter(item => item.up === false).length;
+    const ongoing = data.incidents.filter(incident => incident.status !== 'resolved').length;
+    summary.className = 'status-summary ' + (down === 0 && ongoing === 0 ? 'all-up' : 'some-down');
+    if (down > 0) {
+        summary.textContent = `${down} 项服务异常`;
+    } else if (ongoing > 0) {
+        summary.textContent = `${ongoing} 个故障处理中`;
+    } else {
+        summary.textContent = '所有服务运行正常';
+    }
+
+    const notice = document.getElementById('statusNotice');
+    notice.textContent = data.notice || '';
+    notice.classList.toggle('d-none', !data.notice);
+
+    document.getElementById('statusIncidents').replaceChildren(...data.incidents.map(renderIncident));
+    document.getElementById('statusItems').replaceChildren(...data.items.map(renderItem));
+    document.getElementById('statusUpdated').textContent = `更新于 ${new Date(data.updatedAt).toLocaleString()}`;
+}
+
+loadStatus();
+setInterval(loadStatus, 60000);
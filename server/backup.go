@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// 命令行工具通过接口访问服务端时读取密码和个人令牌的环境变量，避免它们出现在进程列表中
+const (
+	passwordEnv = "GONITOR_PASSWORD"
+	tokenEnv    = "GONITOR_TOKEN"
+)
+
+// handleBackup 下载数据库的一致快照，包含客户端、用户、历史指标、告警规则等全部状态
+func (s *Server) handleBackup(w http.ResponseWriter, r *http.Request) {
+	if err := s.authorizeAdmin(r); err != nil {
+		writeTextError(w, err)
+		return
+	}
+
+	// 先保存已经结束的分钟的历史指标，当前分钟的数据仍在内存中累计
+	now := time.Now()
+	s.history.Flush(now, false)
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="gonitor-%s.db"`, now.Format("20060102-150405")))
+	if err := s.store.Backup(w); err != nil {
+		// 响应已经开始发送，只能中断连接，客户端会得到不完整的文件并在校验时发现
+		log.Printf("备份数据库出错: %v", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// BackupInfo 表示备份文件的校验结果
+type BackupInfo struct {
+	Version int // 数据库结构版本
+	Clients int
+	Users   int
+	Rules   int
+}
+
+// verifyBackup 检查备份文件是否为完整且本程序支持的数据库
+func verifyBackup(path string) (BackupInfo, error) {
+	var info BackupInfo
+	db, err := bolt.Open(path, 0600, &bolt.Options{ReadOnly: true, Timeout: time.Second})
+	if err != nil {
+		return info, fmt.Errorf("不是有效的备份文件: %w", err)
+	}
+	defer db.Close()
+
+	err = db.View(func(tx *bolt.Tx) error {
+		// 需要读完所有错误，检查协程才会结束
+		var errs []error
+		for err := range tx.Check() {
+			errs = append(errs, err)
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("备份文件已损坏: %w", errs[0])
+		}
+		meta := tx.Bucket(bucketMeta)
+		if meta == nil || len(meta.Get(keySchemaVersion)) != 8 {
+			return errors.New("备份文件中没有数据库版本，不是 Gonitor 的数据库")
+		}
+		info.Version = int(binary.BigEndian.Uint64(meta.Get(keySchemaVersion)))
+		if info.Version > len(migrations) {
+			return fmt.Errorf("备份文件的数据库版本 %d 高于程序支持的版本 %d，请升级服务端", info.Version, len(migrations))
+		}
+		for _, c := range []struct {
+			bucket []byte
+			count  *int
+		}{{bucketClients, &info.Clients}, {bucketUsers, &info.Users}, {bucketAlertRules, &info.Rules}} {
+			b := tx.Bucket(c.bucket)
+			if b == nil {
+				return fmt.Errorf("备份文件中缺少 %s", c.bucket)
+			}
+			*c.count = b.Stats().KeyN
+		}
+		return nil
+	})
+	return info, err
+}
+
+// runBackup 执行 backup 子命令：指定 -url 时通过运行中的服务端的接口备份，否则直接读取已停止的服务端的数据目录
+func runBackup(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	output := fs.String("o", "", "备份文件的路径，默认为当前目录下的 gonitor-<时间>.db")
+	serverURL := fs.String("url", "", "运行中的服务端地址，如 http://localhost:44123；为空时直接读取数据目录")
+	username := fs.String("user", "admin", "通过接口备份时登录的用户名，密码从环境变量 "+passwordEnv+" 读取；设置了 "+tokenEnv+" 时使用个人令牌，不需要登录")
+	dir := fs.String("data", dataDir, "数据目录，不指定 -url 时使用")
+	fs.Parse(args)
+
+	path := *output
+	if path == "" {
+		path = fmt.Sprintf("gonitor-%s.db", time.Now().Format("20060102-150405"))
+	}
+	tmp := path + ".tmp"
+	defer os.Remove(tmp)
+
+	var err error
+	if *serverURL != "" {
+		err = downloadBackup(strings.TrimSuffix(*serverURL, "/"), *username, os.Getenv(passwordEnv), os.Getenv(tokenEnv), tmp)
+	} else {
+		err = copyDatabase(filepath.Join(*dir, dbFile), tmp)
+	}
+	if err != nil {
+		return err
+	}
+	info, err := verifyBackup(tmp)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	log.Printf("已备份到 %s：数据库版本 %d，%d 个客户端，%d 个用户，%d 条告警规则", path, info.Version, info.Clients, info.Users, info.Rules)
+	return nil
+}
+
+// downloadBackup 从运行中的服务端下载备份，token 不为空时使用个人令牌，否则先登录
+func downloadBackup(baseURL, username, password, token, path string) error {
+	client, err := newAPIClient(baseURL, username, password, token)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Get(baseURL + "/api/backup")
+	if err != nil {
+		return fmt.Errorf("下载备份失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("下载备份失败: %s", resp.Status)
+	}
+	return writeFile(path, resp.Body)
+}
+
+// copyDatabase 以只读方式打开已停止的服务端的数据库并复制一致快照
+func copyDatabase(src, dst string) error {
+	db, err := bolt.Open(src, 0600, &bolt.Options{ReadOnly: true, Timeout: time.Second})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return errors.New("数据库正在被服务端使用，请使用 -url 通过接口备份")
+	}
+	if err != nil {
+		return fmt.Errorf("打开数据库 %s 失败: %w", src, err)
+	}
+	defer db.Close()
+
+	return db.View(func(tx *bolt.Tx) error {
+		return tx.CopyFile(dst, 0600)
+	})
+}
+
+// writeFile 把 r 的内容写入文件并刷新到磁盘
+func writeFile(path string, r io.Reader) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// runRestore 执行 restore 子命令：校验备份文件后替换数据目录中的数据库，需要先停止服务端
+// 原来的数据库被重命名保留，恢复出错时可以手动改回
+func runRestore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	dir := fs.String("data", dataDir, "数据目录")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "用法: %s restore [-data 数据目录] <备份文件>\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	info, previous, err := restoreBackup(fs.Arg(0), *dir)
+	if err != nil {
+		return err
+	}
+	log.Printf("已从 %s 恢复：数据库版本 %d，%d 个客户端，%d 个用户，%d 条告警规则", fs.Arg(0), info.Version, info.Clients, info.Users, info.Rules)
+	if previous != "" {
+		log.Printf("原来的数据库已保存为 %s", previous)
+	}
+	if info.Version < len(migrations) {
+		log.Printf("备份的数据库版本较旧，服务端启动时会自动升级")
+	}
+	return nil
+}
+
+// restoreBackup 校验备份文件后替换数据目录中的数据库，返回原来的数据库被重命名后的路径
+func restoreBackup(path, dir string) (BackupInfo, string, error) {
+	info, err := verifyBackup(path)
+	if err != nil {
+		return info, "", err
+	}
+	if info.Users == 0 {
+		log.Printf("备份中没有用户，启动时将创建默认用户 admin")
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return info, "", err
+	}
+	target := filepath.Join(dir, dbFile)
+
+	// 先复制到数据目录中的临时文件，保证最后的重命名是原子的
+	src, err := os.Open(path)
+	if err != nil {
+		return info, "", err
+	}
+	defer src.Close()
+	tmp := target + ".restore"
+	defer os.Remove(tmp)
+	if err := writeFile(tmp, src); err != nil {
+		return info, "", err
+	}
+
+	// 能够获得数据库的锁说明服务端已停止
+	previous := ""
+	if _, err := os.Stat(target); err == nil {
+		db, err := bolt.Open(target, 0600, &bolt.Options{Timeout: time.Second})
+		if errors.Is(err, bolt.ErrTimeout) {
+			return info, "", errors.New("数据库正在被服务端使用，请先停止服务端再恢复")
+		}
+		if err == nil {
+			db.Close()
+		}
+		previous = fmt.Sprintf("%s.before-restore-%s", target, time.Now().Format("20060102150405"))
+		if err := os.Rename(target, previous); err != nil {
+			return info, "", err
+		}
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		return info, "", err
+	}
+	if err := syncDir(dir); err != nil {
+		return info, "", err
+	}
+
+	return info, previous, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackupRestore(t *testing.T) {
+	_, ts := newTestServer(t)
+	admin := loginClient(t, ts)
+	addClient(t, admin, ts, "web")
+	addClient(t, admin, ts, "db")
+
+	// 通过接口备份运行中的服务端
+	t.Setenv(passwordEnv, "admin")
+	backup := filepath.Join(t.TempDir(), "backup.db")
+	if err := runBackup([]string{"-url", ts.URL + "/", "-o", backup}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := verifyBackup(backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Version != len(migrations) || info.Clients != 2 || info.Users != 1 {
+		t.Fatalf("备份信息为 %+v", info)
+	}
+	t.Setenv(passwordEnv, "wrong")
+	if err := runBackup([]string{"-url", ts.URL, "-o", backup}); err == nil {
+		t.Fatal("密码错误时备份应失败")
+	}
+
+	// 恢复到另一个数据目录，原来的数据库被保留
+	dir := t.TempDir()
+	st, err := openBoltStore(filepath.Join(dir, dbFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := restoreBackup(backup, dir); err == nil {
+		t.Fatal("数据库被占用时恢复应失败")
+	}
+	st.Close()
+
+	_, previous, err := restoreBackup(backup, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(previous); err != nil {
+		t.Fatalf("原来的数据库没有保留: %v", err)
+	}
+	st, err = openBoltStore(filepath.Join(dir, dbFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clients, err := st.ListClients()
+	st.Close()
+	if err != nil || len(clients) != 2 {
+		t.Fatalf("恢复后的客户端为 %v, %v", clients, err)
+	}
+
+	// 服务端停止时直接读取数据目录
+	offline := filepath.Join(t.TempDir(), "offline.db")
+	if err := runBackup([]string{"-data", dir, "-o", offline}); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := verifyBackup(offline); err != nil || info.Clients != 2 {
+		t.Fatalf("离线备份信息为 %+v, %v", info, err)
+	}
+
+	// 无效的文件不能恢复，数据目录保持不变
+	bad := filepath.Join(t.TempDir(), "bad.db")
+	os.WriteFile(bad, []byte("not a database"), 0600)
+	if _, _, err := restoreBackup(bad, dir); err == nil {
+		t.Fatal("无效的备份文件应返回错误")
+	}
+	if _, err := verifyBackup(filepath.Join(dir, dbFile)); err != nil {
+		t.Fatalf("恢复失败后数据库应保持不变: %v", err)
+	}
+
+	resp, err := newCookieClient().Get(ts.URL + "/api/backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("匿名访问状态码为 %d", resp.StatusCode)
+	}
+}
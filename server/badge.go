@@ -0,0 +1,122 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"time"
+	"unicode/utf8"
+)
+
+// badgeMaxAge 徽章的缓存时间，CDN 和浏览器在此期间可以直接使用缓存
+const badgeMaxAge = 60 * time.Second
+
+// 徽章颜色，与 shields.io 一致
+const (
+	badgeGreen  = "#4c1"
+	badgeYellow = "#dfb317"
+	badgeRed    = "#e05d44"
+	badgeGray   = "#9f9f9f"
+)
+
+// uptimePeriods 可用率徽章支持的统计周期
+var uptimePeriods = map[string]bool{"24h": true, "7d": true, "30d": true}
+
+// badgeContent 根据客户端当前状态生成徽章的文字和颜色
+// metric 为 status、cpu、memory、disk 或 uptime，period 仅用于 uptime
+func (s *Server) badgeContent(c Client, metric, period string, now time.Time) (label, value, color string, err error) {
+	switch metric {
+	case "", "status":
+		if c.Connected {
+			return c.Name, "在线", badgeGreen, nil
+		}
+		return c.Name, "离线", badgeRed, nil
+	case "cpu", "memory", "disk":
+		names := map[string]string{"cpu": "CPU", "memory": "内存", "disk": "硬盘"}
+		label = c.Name + " " + names[metric]
+		if !c.Connected {
+			return label, "离线", badgeGray, nil
+		}
+		v := map[string]float64{"cpu": c.CPU, "memory": c.Memory, "disk": c.DiskUsage}[metric]
+		return label, fmt.Sprintf("%.1f%%", v), usageColor(v), nil
+	case "uptime":
+		if period == "" {
+			period = "30d"
+		}
+		if !uptimePeriods[period] {
+			return "", "", "", fmt.Errorf("统计周期 %q 无效，可选 24h、7d、30d", period)
+		}
+		status, err := s.checkStatus(c.ID, CheckResult{}, now)
+		if err != nil {
+			return "", "", "", err
+		}
+		uptime := map[string]*float64{"24h": status.Uptime24h, "7d": status.Uptime7d, "30d": status.Uptime30d}[period]
+		label = fmt.Sprintf("%s 可用率(%s)", c.Name, period)
+		if uptime == nil {
+			return label, "无数据", badgeGray, nil
+		}
+		return label, fmt.Sprintf("%.2f%%", *uptime), uptimeColor(*uptime), nil
+	}
+	return "", "", "", fmt.Errorf("指标 %q 无效，可选 status、cpu、memory、disk、uptime", metric)
+}
+
+// usageColor 按使用率选择颜色，与主页进度条的阈值一致
+func usageColor(v float64) string {
+	switch {
+	case v >= 80:
+		return badgeRed
+	case v >= 50:
+		return badgeYellow
+	}
+	return badgeGreen
+}
+
+// uptimeColor 按可用率选择颜色
+func uptimeColor(v float64) string {
+	switch {
+	case v >= 99.9:
+		return badgeGreen
+	case v >= 99:
+		return "#97ca00"
+	case v >= 95:
+		return badgeYellow
+	}
+	return badgeRed
+}
+
+// textWidth 估算文字在 11px Verdana 下的宽度，全角字符按两倍计算
+func textWidth(s string) int {
+	w := 0
+	for _, r := range s {
+		if utf8.RuneLen(r) > 1 {
+			w += 12
+		} else {
+			w += 7
+		}
+	}
+	return w
+}
+
+// renderBadge 生成 shields.io 风格的 SVG 徽章
+func renderBadge(label, value, color string) []byte {
+	lw, vw := textWidth(label)+10, textWidth(value)+10
+	w := lw + vw
+	label, value = html.EscapeString(label), html.EscapeString(value)
+	return fmt.Appendf(nil, `<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="20" role="img" aria-label="%[4]s: %[5]s">`+
+		`<title>%[4]s: %[5]s</title>`+
+		`<linearGradient id="s" x2="0" y2="100%%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>`+
+		`<clipPath id="r"><rect width="%[1]d" height="20" rx="3" fill="#fff"/></clipPath>`+
+		`<g clip-path="url(#r)"><rect width="%[2]d" height="20" fill="#555"/><rect x="%[2]d" width="%[3]d" height="20" fill="%[6]s"/><rect width="%[1]d" height="20" fill="url(#s)"/></g>`+
+		`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">`+
+		`<text x="%[7]d" y="15" fill="#010101" fill-opacity=".3">%[4]s</text><text x="%[7]d" y="14">%[4]s</text>`+
+		`<text x="%[8]d" y="15" fill="#010101" fill-opacity=".3">%[5]s</text><text x="%[8]d" y="14">%[5]s</text>`+
+		`</g></svg>`,
+		w, lw, vw, label, value, color, lw/2, lw+vw/2)
+}
+
+// badgeETag 根据徽章内容生成 ETag
+func badgeETag(svg []byte) string {
+	sum := sha256.Sum256(svg)
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
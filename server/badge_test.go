@@ -0,0 +1,79 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBadge(t *testing.T) {
+	server, ts := newTestServer(t)
+	admin := loginClient(t, ts)
+	id := addClient(t, admin, ts, "web-1")
+
+	get := func(path string, header http.Header) (*http.Response, string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp, string(body)
+	}
+
+	// 未公开的客户端不能通过客户端ID访问徽章
+	if resp, _ := get("/badge/"+id+".svg", nil); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("未公开的客户端返回 %s", resp.Status)
+	}
+
+	badgeID := mustPost(t, admin, ts.URL+"/api/clients/public", map[string]any{"id": id, "public": true}, http.StatusOK)["badgeId"]
+	if badgeID == "" || badgeID == id {
+		t.Fatalf("徽章标识为 %q", badgeID)
+	}
+	resp, body := get("/badge/"+badgeID+".svg", nil)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "image/svg+xml; charset=utf-8" {
+		t.Fatalf("徽章返回 %s %s", resp.Status, resp.Header.Get("Content-Type"))
+	}
+	if !strings.Contains(resp.Header.Get("Cache-Control"), "public") || !strings.Contains(body, "web-1: 离线") {
+		t.Fatalf("徽章为 %s %s", resp.Header.Get("Cache-Control"), body)
+	}
+
+	// 内容不变时返回 304
+	resp, _ = get("/badge/"+badgeID+".svg", http.Header{"If-None-Match": {resp.Header.Get("ETag")}})
+	if resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("ETag 匹配时返回 %s", resp.Status)
+	}
+
+	conn := dialAgent(t, ts, id)
+	defer conn.Close()
+	if err := conn.WriteJSON(Metrics{CPU: 91.25}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "指标更新", func() bool {
+		c, _ := server.clients.Snapshot().Get(id)
+		return c.CPU == 91.25
+	})
+	if _, body := get("/badge/"+badgeID+".svg?metric=cpu", nil); !strings.Contains(body, "91.2%") || !strings.Contains(body, badgeRed) {
+		t.Fatalf("CPU 徽章为 %s", body)
+	}
+	server.recordClientUptime(time.Now())
+	if _, body := get("/badge/"+badgeID+".svg?metric=uptime&period=24h&label=<x>", nil); !strings.Contains(body, "&lt;x&gt;: 100.00%") {
+		t.Fatalf("可用率徽章为 %s", body)
+	}
+	if resp, _ := get("/badge/"+badgeID+".svg?metric=load", nil); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("无效指标返回 %s", resp.Status)
+	}
+
+	// 取消公开后旧地址失效
+	mustPost(t, admin, ts.URL+"/api/clients/public", map[string]any{"id": id, "public": false}, http.StatusOK)
+	if resp, _ := get("/badge/"+badgeID+".svg", nil); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("取消公开后返回 %s", resp.Status)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"time"
+)
+
+// CheckResult 表示一次探测的结果
+type CheckResult struct {
+	Name          string    `json:"name"`
+	Type          string    `json:"type"` // http、tcp 或 dns
+	Target        string    `json:"target"`
+	Up            bool      `json:"up"`
+	Latency       float64   `json:"latency"` // 毫秒
+	StatusCode    int       `json:"statusCode,omitempty"`
+	CertExpiresAt time.Time `json:"certExpiresAt,omitzero"`
+	Message       string    `json:"message,omitempty"`
+	Time          time.Time `json:"time"`
+}
+
+// CheckDay 表示某个探测一天内的汇总
+type CheckDay struct {
+	Date       string  `json:"date"` // 服务器本地时区的日期，如 2006-01-02
+	Up         int     `json:"up"`
+	Total      int     `json:"total"`
+	LatencySum float64 `json:"latencySum"`
+}
+
+// CheckStatus 表示探测的最新结果和可用率，可用率为 0-100，没有数据时为 null
+type CheckStatus struct {
+	CheckResult
+	ID        string     `json:"id"`
+	Uptime24h *float64   `json:"uptime24h"`
+	Uptime7d  *float64   `json:"uptime7d"`
+	Uptime30d *float64   `json:"uptime30d"`
+	Days      []CheckDay `json:"days"` // 最近 90 天，没有数据的日期 Total 为 0
+}
+
+// uptimeDays 可用率按天汇总的展示天数
+const uptimeDays = 90
+
+// clientCheckID 返回客户端执行的探测的ID
+func clientCheckID(clientID, name string) string {
+	return clientID + "/" + name
+}
+
+// mergeChecks 用新的结果更新每个探测的最新状态，返回新的切片，不修改 latest
+func mergeChecks(latest, results []CheckResult) []CheckResult {
+	merged := slices.Clone(latest)
+	for _, r := range results {
+		i := slices.IndexFunc(merged, func(c CheckResult) bool { return c.Name == r.Name })
+		switch {
+		case i < 0:
+			merged = append(merged, r)
+		case !r.Time.Before(merged[i].Time):
+			merged[i] = r
+		}
+	}
+	slices.SortFunc(merged, func(a, b CheckResult) int {
+		if a.Name < b.Name {
+			return -1
+		}
+		if a.Name > b.Name {
+			return 1
+		}
+		return 0
+	})
+	return merged
+}
+
+// recordChecks 保存客户端上报的探测结果
+func (s *Server) recordChecks(clientID string, results []CheckResult) {
+	byName := make(map[string][]CheckResult)
+	for _, r := range results {
+		if r.Name == "" {
+			continue
+		}
+		byName[r.Name] = append(byName[r.Name], r)
+	}
+	for name, results := range byName {
+		if err := s.store.AppendCheckResults(clientCheckID(clientID, name), results...); err != nil {
+			log.Printf("保存探测结果出错: %v", err)
+		}
+	}
+}
+
+// recordClientUptime 以客户端ID记录所有客户端当前是否在线，用于计算客户端的可用率
+func (s *Server) recordClientUptime(now time.Time) {
+	for _, c := range s.clients.Snapshot().List() {
+		result := CheckResult{Name: c.Name, Type: "agent", Up: c.Connected, Time: now}
+		if err := s.store.AppendCheckResults(c.ID, result); err != nil {
+			log.Printf("保存客户端在线记录出错: %v", err)
+		}
+	}
+}
+
+// checkStatus 计算探测的可用率并补全最近 90 天的汇总
+func (s *Server) checkStatus(id string, latest CheckResult, now time.Time) (CheckStatus, error) {
+	status := CheckStatus{CheckResult: latest, ID: id}
+
+	results, err := s.store.ListCheckResults(id, now.Add(-24*time.Hour))
+	if err != nil {
+		return status, fmt.Errorf("读取探测结果出错: %w", err)
+	}
+	up := 0
+	for _, r := range results {
+		if r.Up {
+			up++
+		}
+	}
+	status.Uptime24h = percent(up, len(results))
+
+	today := now.Local()
+	first := time.Date(today.Year(), today.Month(), today.Day()-(uptimeDays-1), 0, 0, 0, 0, time.Local)
+	days, err := s.store.ListCheckDays(id, first)
+	if err != nil {
+		return status, fmt.Errorf("读取探测结果出错: %w", err)
+	}
+	byDate := make(map[string]CheckDay, len(days))
+	for _, d := range days {
+		byDate[d.Date] = d
+	}
+	status.Days = make([]CheckDay, uptimeDays)
+	for i := range status.Days {
+		date := first.AddDate(0, 0, i).Format(time.DateOnly)
+		if d, ok := byDate[date]; ok {
+			status.Days[i] = d
+		} else {
+			status.Days[i] = CheckDay{Date: date}
+		}
+	}
+	status.Uptime7d = daysUptime(status.Days[uptimeDays-7:])
+	status.Uptime30d = daysUptime(status.Days[uptimeDays-30:])
+	return status, nil
+}
+
+// daysUptime 计算若干天的总体可用率
+func daysUptime(days []CheckDay) *float64 {
+	up, total := 0, 0
+	for _, d := range days {
+		up += d.Up
+		total += d.Total
+	}
+	return percent(up, total)
+}
+
+// percent 返回百分比，total 为 0 时返回 nil
+func percent(n, total int) *float64 {
+	if total == 0 {
+		return nil
+	}
+	p := float64(n) * 100 / float64(total)
+	return &p
+}
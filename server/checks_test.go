@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAgentCheckResults(t *testing.T) {
+	server, ts := newTestServer(t)
+	admin := loginClient(t, ts)
+	id := addClient(t, admin, ts, "a")
+	conn := dialAgent(t, ts, id)
+
+	// 一次成功、一次失败，随后又成功
+	now := time.Now()
+	send := func(up bool, at time.Time) {
+		t.Helper()
+		result := CheckResult{Name: "web", Type: "http", Target: "http://example.com", Up: up, Latency: 12, Time: at}
+		if err := conn.WriteJSON(Metrics{Checks: []CheckResult{result}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	send(true, now.Add(-2*time.Minute))
+	send(false, now.Add(-time.Minute))
+	send(true, now)
+	waitFor(t, "探测结果", func() bool {
+		results, _ := server.store.ListCheckResults(clientCheckID(id, "web"), time.Time{})
+		return len(results) == 3
+	})
+
+	// 客户端状态中只保留最新结果
+	c, _ := findClient(getClients(t, admin, ts.URL), "a")
+	if len(c.Checks) != 1 || !c.Checks[0].Up || !c.Checks[0].Time.Equal(now) {
+		t.Fatalf("最新探测结果为 %+v", c.Checks)
+	}
+	if v, ok := c.Metric(`check_up{check="web"}`); !ok || v != 1 {
+		t.Fatalf("check_up 为 %v, %v", v, ok)
+	}
+
+	var statuses []CheckStatus
+	mustGet(t, admin, ts.URL+"/api/clients/checks?id="+id, &statuses)
+	if len(statuses) != 1 {
+		t.Fatalf("探测状态为 %+v", statuses)
+	}
+	status := statuses[0]
+	if status.ID != clientCheckID(id, "web") || status.Uptime24h == nil {
+		t.Fatalf("探测状态为 %+v", status)
+	}
+	if got := *status.Uptime24h; got < 66.6 || got > 66.7 {
+		t.Fatalf("24 小时可用率为 %v，期望约 66.7", got)
+	}
+	if len(status.Days) != uptimeDays || status.Days[uptimeDays-1].Total == 0 {
+		t.Fatalf("按天汇总的数据不正确: 共 %d 天，今天 %+v", len(status.Days), status.Days[len(status.Days)-1])
+	}
+
+	var results []CheckResult
+	mustGet(t, admin, ts.URL+"/api/checks/results?id="+status.ID+"&range=1h", &results)
+	if len(results) != 3 || results[1].Up {
+		t.Fatalf("历史探测结果为 %+v", results)
+	}
+
+	// 删除客户端时探测结果一并删除
+	mustPost(t, admin, ts.URL+"/api/clients/delete", map[string]string{"id": id}, http.StatusOK)
+	if results, _ := server.store.ListCheckResults(status.ID, time.Time{}); len(results) != 0 {
+		t.Fatalf("删除客户端后仍有 %d 条探测结果", len(results))
+	}
+	if days, _ := server.store.ListCheckDays(status.ID, time.Time{}); len(days) != 0 {
+		t.Fatalf("删除客户端后仍有 %d 天的汇总", len(days))
+	}
+}
+
+func TestDeleteCheckResultsBefore(t *testing.T) {
+	server, _ := newTestServer(t)
+	st := server.store
+
+	base := time.Now().Add(-10 * 24 * time.Hour)
+	for i := 0; i < 4; i++ {
+		if err := st.AppendCheckResults("x", CheckResult{Name: "x", Up: true, Time: base.Add(time.Duration(i) * 24 * time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := st.DeleteCheckResultsBefore(base.Add(36 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	results, _ := st.ListCheckResults("x", time.Time{})
+	if len(results) != 2 {
+		t.Fatalf("剩余 %d 条探测结果，期望 2", len(results))
+	}
+	// 按天汇总的数据不受影响
+	days, _ := st.ListCheckDays("x", time.Time{})
+	if len(days) != 4 {
+		t.Fatalf("剩余 %d 天的汇总，期望 4", len(days))
+	}
+}
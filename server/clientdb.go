@@ -0,0 +1,670 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Client 表示客户端信息
+type Client struct {
+	ID             string        `json:"id"`
+	Name           string        `json:"name"`
+	Connected      bool          `json:"connected"`
+	LastSeen       time.Time     `json:"lastSeen"`
+	CPU            float64       `json:"cpu"`
+	Memory         float64       `json:"memory"`
+	DiskUsage      float64       `json:"diskUsage"`
+	DiskReadSpeed  float64       `json:"diskReadSpeed"`  // 磁盘读取速度 (KB/s)
+	DiskWriteSpeed float64       `json:"diskWriteSpeed"` // 磁盘写入速度 (KB/s)
+	UploadSpeed    float64       `json:"uploadSpeed"`    // 上传网速 (KB/s)
+	DownloadSpeed  float64       `json:"downloadSpeed"`  // 下载网速 (KB/s)
+	DisplayOrder   int           `json:"displayOrder"`
+	Group          string        `json:"group,omitempty"`   // 分组路径，以 / 分隔层级，如 prod/web
+	Tags           []string      `json:"tags,omitempty"`    // 管理员设置的标签，按名称排序
+	BadgeID        string        `json:"badgeId,omitempty"` // 公开徽章的随机标识，为空表示不公开
+	Samples        []Sample      `json:"samples,omitempty"` // 内置字段之外的其他指标
+	Checks         []CheckResult `json:"checks,omitempty"`  // 客户端执行的每个探测的最新结果
+
+	// Labels 客户端连接时声明的标签，每次连接时替换；与管理员设置的 key=value 标签同名时以后者为准
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// 以下仅用于通过注册令牌自助注册的客户端
+	SecretHash string `json:"secretHash,omitempty"` // 客户端密钥的哈希值，连接时需要携带密钥
+	EnrolledBy string `json:"enrolledBy,omitempty"` // 注册使用的令牌名称
+}
+
+// Metrics 表示客户端上报的一帧系统指标
+type Metrics struct {
+	CPU            float64 `json:"cpu"`
+	Memory         float64 `json:"memory"`
+	DiskUsage      float64 `json:"diskUsage"`
+	DiskReadSpeed  float64 `json:"diskReadSpeed"`
+	DiskWriteSpeed float64 `json:"diskWriteSpeed"`
+	UploadSpeed    float64 `json:"uploadSpeed"`
+	DownloadSpeed  float64 `json:"downloadSpeed"`
+	// Samples 内置字段之外的其他指标，为 null 表示与上一帧相同
+	Samples []Sample `json:"samples"`
+	// Checks 上一帧之后完成的探测结果
+	Checks []CheckResult `json:"checks,omitempty"`
+}
+
+// Sample 表示客户端采集器上报的一个指标值
+type Sample struct {
+	Name   string            `json:"name"`
+	Value  float64           `json:"value"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Key 返回指标的唯一名称，带标签时为 name{k="v",...} 的形式，标签按名称排序
+func (s Sample) Key() string {
+	if len(s.Labels) == 0 {
+		return s.Name
+	}
+	names := make([]string, 0, len(s.Labels))
+	for k := range s.Labels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteString(s.Name)
+	b.WriteByte('{')
+	for i, k := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=%q", k, s.Labels[k])
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// values 返回这一帧中所有指标的值，键为内置指标名称或 Sample.Key
+func (m Metrics) values() map[string]float64 {
+	values := map[string]float64{
+		"cpu":            m.CPU,
+		"memory":         m.Memory,
+		"diskUsage":      m.DiskUsage,
+		"diskReadSpeed":  m.DiskReadSpeed,
+		"diskWriteSpeed": m.DiskWriteSpeed,
+		"uploadSpeed":    m.UploadSpeed,
+		"downloadSpeed":  m.DownloadSpeed,
+	}
+	for _, sample := range m.Samples {
+		values[sample.Key()] = sample.Value
+	}
+	// 每个探测的最新结果，check_up 为 1 表示成功
+	for _, c := range m.Checks {
+		up := 0.0
+		if c.Up {
+			up = 1
+		}
+		labels := map[string]string{"check": c.Name}
+		values[Sample{Name: "check_up", Labels: labels}.Key()] = up
+		values[Sample{Name: "check_latency", Labels: labels}.Key()] = c.Latency
+	}
+	return values
+}
+
+// apply 将指标写入客户端
+func (m Metrics) apply(c *Client) {
+	c.CPU = m.CPU
+	c.Memory = m.Memory
+	c.DiskUsage = m.DiskUsage
+	c.DiskReadSpeed = m.DiskReadSpeed
+	c.DiskWriteSpeed = m.DiskWriteSpeed
+	c.UploadSpeed = m.UploadSpeed
+	c.DownloadSpeed = m.DownloadSpeed
+	if m.Samples != nil {
+		c.Samples = m.Samples
+	}
+	if len(m.Checks) > 0 {
+		c.Checks = mergeChecks(c.Checks, m.Checks)
+	}
+}
+
+// metrics 返回客户端当前的全部指标，Checks 为每个探测的最新结果
+func (c *Client) metrics() Metrics {
+	return Metrics{
+		CPU:            c.CPU,
+		Memory:         c.Memory,
+		DiskUsage:      c.DiskUsage,
+		DiskReadSpeed:  c.DiskReadSpeed,
+		DiskWriteSpeed: c.DiskWriteSpeed,
+		UploadSpeed:    c.UploadSpeed,
+		DownloadSpeed:  c.DownloadSpeed,
+		Samples:        c.Samples,
+		Checks:         c.Checks,
+	}
+}
+
+// Metric 返回客户端当前的某个指标，name 为内置指标名称或 Sample.Key
+func (c *Client) Metric(name string) (float64, bool) {
+	v, ok := c.metrics().values()[name]
+	return v, ok
+}
+
+// ClientSnapshot 是某一时刻所有客户端状态的不可变快照
+// 快照发布后不会再被修改，可以在任意协程中无锁读取
+type ClientSnapshot struct {
+	clients map[string]Client
+	sorted  []Client // 按 DisplayOrder 排序
+}
+
+// Get 返回指定客户端的副本
+func (s *ClientSnapshot) Get(id string) (Client, bool) {
+	c, ok := s.clients[id]
+	return c, ok
+}
+
+// List 按显示顺序返回所有客户端的副本，调用方可以随意修改返回的切片
+func (s *ClientSnapshot) List() []Client {
+	return append([]Client(nil), s.sorted...)
+}
+
+// ByBadgeID 返回公开了徽章且徽章标识为 badgeID 的客户端
+func (s *ClientSnapshot) ByBadgeID(badgeID string) (Client, bool) {
+	if badgeID == "" {
+		return Client{}, false
+	}
+	for _, c := range s.sorted {
+		if c.BadgeID == badgeID {
+			return c, true
+		}
+	}
+	return Client{}, false
+}
+
+// Len 返回客户端数量
+func (s *ClientSnapshot) Len() int {
+	return len(s.sorted)
+}
+
+// clientState 是只属于 ClientDB 协程的可变状态
+type clientState struct {
+	clients map[string]*Client
+	conns   map[string]*websocket.Conn
+	dirty   map[string]bool // 需要持久化的客户端，值为 false 表示需要删除
+}
+
+// touch 标记客户端需要保存
+func (st *clientState) touch(id string) {
+	st.dirty[id] = true
+}
+
+// clientEvent 表示一次对客户端状态的修改
+type clientEvent struct {
+	apply   func(st *clientState)
+	publish bool // 为 false 时修改完成后不立即发布快照
+	done    chan struct{}
+}
+
+// ClientDB 管理所有已注册的客户端
+// 客户端状态由一个协程独占，所有修改都以事件的形式发送给该协程串行执行；
+// 每批修改完成后先持久化，再发布一份新的不可变快照，读取方通过 Snapshot 获取。
+// 客户端每一帧都会上报指标，这类修改只标记快照过期，由下一次读取时统一发布，
+// 避免每一帧都复制所有客户端。
+type ClientDB struct {
+	store  Store
+	events chan clientEvent
+	snap   atomic.Pointer[ClientSnapshot]
+	stale  atomic.Bool // 已发布的快照缺少最近的指标更新
+	stop   chan struct{}
+	exited chan struct{}
+	closed sync.Once
+}
+
+// newClientDB 创建客户端数据库并启动状态协程
+func newClientDB(store Store, clients []*Client) *ClientDB {
+	st := &clientState{
+		clients: make(map[string]*Client, len(clients)),
+		conns:   make(map[string]*websocket.Conn),
+		dirty:   make(map[string]bool),
+	}
+	for _, c := range clients {
+		client := *c
+		client.Connected = false
+		st.clients[client.ID] = &client
+	}
+
+	db := &ClientDB{
+		store:  store,
+		events: make(chan clientEvent, 64),
+		stop:   make(chan struct{}),
+		exited: make(chan struct{}),
+	}
+	db.publish(st)
+	go db.run(st)
+	return db
+}
+
+// run 状态协程的主循环，批量处理事件以减少快照发布次数
+func (db *ClientDB) run(st *clientState) {
+	defer close(db.exited)
+	var batch []clientEvent
+	for {
+		select {
+		case <-db.stop:
+			return
+		case ev := <-db.events:
+			batch = append(batch[:0], ev)
+		}
+	drain:
+		for len(batch) < cap(db.events) {
+			select {
+			case ev := <-db.events:
+				batch = append(batch, ev)
+			default:
+				break drain
+			}
+		}
+
+		publish := false
+		for _, ev := range batch {
+			ev.apply(st)
+			publish = publish || ev.publish
+		}
+		db.persist(st)
+		if publish {
+			db.publish(st)
+		} else {
+			db.stale.Store(true)
+		}
+		for _, ev := range batch {
+			close(ev.done)
+		}
+	}
+}
+
+// do 将修改发送给状态协程并等待其执行完成且快照已发布
+func (db *ClientDB) do(apply func(st *clientState)) {
+	db.send(clientEvent{apply: apply, publish: true, done: make(chan struct{})})
+}
+
+// send 将事件发送给状态协程并等待其执行完成
+func (db *ClientDB) send(ev clientEvent) {
+	select {
+	case db.events <- ev:
+	case <-db.exited:
+		return
+	}
+	select {
+	case <-ev.done:
+	case <-db.exited:
+	}
+}
+
+// persist 保存本批修改涉及的客户端
+func (db *ClientDB) persist(st *clientState) {
+	if len(st.dirty) == 0 {
+		return
+	}
+	var save []*Client
+	for id, keep := range st.dirty {
+		if !keep {
+			if err := db.store.DeleteClient(id); err != nil {
+				log.Printf("删除客户端数据出错: %v", err)
+			}
+			continue
+		}
+		if c, ok := st.clients[id]; ok {
+			client := *c
+			save = append(save, &client)
+		}
+	}
+	clear(st.dirty)
+	if len(save) == 0 {
+		return
+	}
+	if err := db.store.SaveClients(save...); err != nil {
+		log.Printf("保存客户端数据出错: %v", err)
+	}
+}
+
+// publish 根据当前状态生成并发布新的快照
+func (db *ClientDB) publish(st *clientState) {
+	snap := &ClientSnapshot{
+		clients: make(map[string]Client, len(st.clients)),
+		sorted:  make([]Client, 0, len(st.clients)),
+	}
+	for id, c := range st.clients {
+		snap.clients[id] = *c
+	}
+	// 显示顺序很少变化，先沿用上一份快照的顺序，只有顺序被打乱时才重新排序
+	if prev := db.snap.Load(); prev != nil && len(prev.sorted) == len(st.clients) {
+		for _, c := range prev.sorted {
+			if client, ok := snap.clients[c.ID]; ok {
+				snap.sorted = append(snap.sorted, client)
+			}
+		}
+	}
+	if len(snap.sorted) != len(snap.clients) {
+		snap.sorted = snap.sorted[:0]
+		for _, c := range snap.clients {
+			snap.sorted = append(snap.sorted, c)
+		}
+	}
+	if !slices.IsSortedFunc(snap.sorted, compareDisplayOrder) {
+		slices.SortFunc(snap.sorted, compareDisplayOrder)
+	}
+	db.snap.Store(snap)
+	db.stale.Store(false)
+}
+
+// compareDisplayOrder 按显示顺序比较客户端，顺序相同时按ID
+func compareDisplayOrder(a, b Client) int {
+	if a.DisplayOrder != b.DisplayOrder {
+		return cmp.Compare(a.DisplayOrder, b.DisplayOrder)
+	}
+	return strings.Compare(a.ID, b.ID)
+}
+
+// Snapshot 返回当前所有客户端状态的不可变快照
+// 快照缺少最近的指标更新时先发布一份新的快照，同时到达的读取只发布一次
+func (db *ClientDB) Snapshot() *ClientSnapshot {
+	if db.stale.Load() {
+		db.do(func(*clientState) {})
+	}
+	return db.snap.Load()
+}
+
+// Add 添加一个新客户端并返回其副本
+func (db *ClientDB) Add(name string) Client {
+	return db.Create(func(c *Client) { c.Name = name })
+}
+
+// AddEnrolled 添加一个通过注册令牌自助注册的客户端并返回其副本
+func (db *ClientDB) AddEnrolled(name, group, secretHash, tokenName string) Client {
+	return db.Create(func(c *Client) {
+		c.Name, c.Group, c.SecretHash, c.EnrolledBy = name, group, secretHash, tokenName
+	})
+}
+
+// Create 为新客户端分配ID和显示顺序（排在最后），再调用 init 设置其他字段后添加，返回其副本
+// init 可以修改显示顺序，不能修改ID
+func (db *ClientDB) Create(init func(c *Client)) Client {
+	var added Client
+	db.do(func(st *clientState) {
+		// 确定最大的显示顺序
+		maxOrder := 0
+		for _, c := range st.clients {
+			if c.DisplayOrder > maxOrder {
+				maxOrder = c.DisplayOrder
+			}
+		}
+
+		id := newClientID()
+		for st.clients[id] != nil {
+			id = newClientID()
+		}
+		c := &Client{ID: id, DisplayOrder: maxOrder + 1}
+		init(c)
+		c.ID = id
+		st.clients[id] = c
+		st.touch(id)
+		added = *c
+	})
+	return added
+}
+
+// Delete 删除客户端并断开其连接，客户端不存在时返回 false
+func (db *ClientDB) Delete(id string) bool {
+	var found bool
+	db.do(func(st *clientState) {
+		if _, found = st.clients[id]; !found {
+			return
+		}
+		delete(st.clients, id)
+		if conn, ok := st.conns[id]; ok {
+			conn.Close()
+			delete(st.conns, id)
+		}
+		st.dirty[id] = false
+	})
+	return found
+}
+
+// Rename 修改客户端名称，客户端不存在时返回 ErrNotFound
+func (db *ClientDB) Rename(id, name string) error {
+	err := ErrNotFound
+	db.do(func(st *clientState) {
+		if c, ok := st.clients[id]; ok {
+			c.Name = name
+			st.touch(id)
+			err = nil
+		}
+	})
+	return err
+}
+
+// Update 在同一批中修改一个客户端并返回修改后的副本，客户端不存在时返回 ErrNotFound
+func (db *ClientDB) Update(id string, update func(c *Client)) (Client, error) {
+	var updated Client
+	err := ErrNotFound
+	db.do(func(st *clientState) {
+		c, ok := st.clients[id]
+		if !ok {
+			return
+		}
+		update(c)
+		c.ID = id
+		st.touch(id)
+		updated, err = *c, nil
+	})
+	return updated, err
+}
+
+// SetGroup 批量设置客户端的分组，group 需已规范化，为空表示不分组
+// 任一客户端不存在时返回 ErrNotFound，且不做任何修改
+func (db *ClientDB) SetGroup(ids []string, group string) error {
+	return db.updateAll(ids, func(c *Client) {
+		c.Group = group
+	})
+}
+
+// UpdateTags 批量为客户端添加和移除标签，标签需已规范化
+// 任一客户端不存在时返回 ErrNotFound，且不做任何修改
+func (db *ClientDB) UpdateTags(ids []string, add, remove []string) error {
+	return db.updateAll(ids, func(c *Client) {
+		tags := slices.DeleteFunc(slices.Clone(c.Tags), func(tag string) bool {
+			return slices.Contains(remove, tag)
+		})
+		c.Tags = normalizeTags(append(tags, add...))
+	})
+}
+
+// updateAll 对多个客户端执行同一修改，任一客户端不存在时返回 ErrNotFound
+func (db *ClientDB) updateAll(ids []string, update func(c *Client)) error {
+	var err error
+	db.do(func(st *clientState) {
+		for _, id := range ids {
+			if st.clients[id] == nil {
+				err = ErrNotFound
+				return
+			}
+		}
+		for _, id := range ids {
+			update(st.clients[id])
+			st.touch(id)
+		}
+	})
+	return err
+}
+
+// Import 按导入的记录新建或更新客户端，所有修改在同一批中完成并保存；dryRun 时只计算差异
+// 记录需已规范化，ID 为空或不存在时新建客户端；徽章标识与其他客户端重复时返回错误，且不做任何修改
+func (db *ClientDB) Import(records []ClientRecord, dryRun bool) (ImportResult, error) {
+	result := ImportResult{DryRun: dryRun, Diffs: []ClientDiff{}}
+	var err error
+	db.do(func(st *clientState) {
+		// 先在副本上修改，检查通过后再替换
+		clients := make(map[string]*Client, len(st.clients))
+		maxOrder := 0
+		for id, c := range st.clients {
+			client := *c
+			clients[id] = &client
+			maxOrder = max(maxOrder, c.DisplayOrder)
+		}
+
+		var changed []string
+		for i := range records {
+			r := &records[i]
+			c, exists := clients[r.ID]
+			if !exists {
+				id := r.ID
+				for id == "" || clients[id] != nil {
+					id = newClientID()
+				}
+				maxOrder++
+				c = &Client{ID: id, DisplayOrder: maxOrder}
+				clients[id] = c
+			}
+			changes := applyRecord(c, r)
+			switch {
+			case !exists:
+				result.Created++
+				result.Diffs = append(result.Diffs, ClientDiff{ID: c.ID, Name: c.Name, Action: "create", Changes: changes})
+			case len(changes) > 0:
+				result.Updated++
+				result.Diffs = append(result.Diffs, ClientDiff{ID: c.ID, Name: c.Name, Action: "update", Changes: changes})
+			default:
+				result.Unchanged++
+				continue
+			}
+			changed = append(changed, c.ID)
+		}
+
+		badges := make(map[string]string)
+		for id, c := range clients {
+			if c.BadgeID == "" {
+				continue
+			}
+			if other, ok := badges[c.BadgeID]; ok {
+				err = fmt.Errorf("客户端 %s 和 %s 的徽章标识重复", min(id, other), max(id, other))
+				return
+			}
+			badges[c.BadgeID] = id
+		}
+		if dryRun {
+			return
+		}
+		for _, id := range changed {
+			st.clients[id] = clients[id]
+			st.touch(id)
+		}
+	})
+	return result, err
+}
+
+// Reorder 批量修改客户端的显示顺序，忽略不存在的客户端
+func (db *ClientDB) Reorder(orders map[string]int) {
+	db.do(func(st *clientState) {
+		for id, order := range orders {
+			if c, ok := st.clients[id]; ok {
+				c.DisplayOrder = order
+				st.touch(id)
+			}
+		}
+	})
+}
+
+// Connect 登记客户端的新连接并记录其声明的标签，返回被替换的旧连接，客户端不存在时返回 ErrNotFound
+func (db *ClientDB) Connect(id string, conn *websocket.Conn, labels map[string]string) (*websocket.Conn, error) {
+	var old *websocket.Conn
+	err := ErrNotFound
+	db.do(func(st *clientState) {
+		c, ok := st.clients[id]
+		if !ok {
+			return
+		}
+		old = st.conns[id]
+		st.conns[id] = conn
+		c.Labels = labels
+		c.Connected = true
+		c.LastSeen = time.Now()
+		st.touch(id)
+		err = nil
+	})
+	return old, err
+}
+
+// Disconnect 注销客户端连接并将其指标归零
+// 如果该连接已被同一客户端的新连接替换，则不改变客户端状态
+func (db *ClientDB) Disconnect(id string, conn *websocket.Conn) {
+	db.do(func(st *clientState) {
+		if st.conns[id] != conn {
+			return
+		}
+		delete(st.conns, id)
+		if c, ok := st.clients[id]; ok {
+			c.Connected = false
+			// 将断开连接的客户端指标数据归零
+			Metrics{}.apply(c)
+			c.Samples = nil
+			st.touch(id)
+		}
+	})
+}
+
+// UpdateMetrics 记录客户端上报的指标并返回更新后的客户端，指标本身不持久化
+// 修改后不立即发布快照，由下一次 Snapshot 发布；客户端不存在时返回 false
+func (db *ClientDB) UpdateMetrics(id string, m Metrics) (Client, bool) {
+	var updated Client
+	var found bool
+	db.send(clientEvent{
+		apply: func(st *clientState) {
+			c, ok := st.clients[id]
+			if !ok {
+				return
+			}
+			m.apply(c)
+			c.LastSeen = time.Now()
+			c.Connected = true
+			updated, found = *c, true
+		},
+		done: make(chan struct{}),
+	})
+	return updated, found
+}
+
+// Conns 返回当前所有客户端连接
+func (db *ClientDB) Conns() []*websocket.Conn {
+	var conns []*websocket.Conn
+	db.do(func(st *clientState) {
+		for _, conn := range st.conns {
+			conns = append(conns, conn)
+		}
+	})
+	return conns
+}
+
+// Close 保存所有客户端的最终状态并停止状态协程，重复调用是安全的
+func (db *ClientDB) Close() {
+	db.closed.Do(func() {
+		db.do(func(st *clientState) {
+			for id := range st.clients {
+				st.touch(id)
+			}
+		})
+		close(db.stop)
+	})
+	<-db.exited
+}
+
+// newClientID 生成客户端ID
+// 使用时间戳后8位加上两个字母，总长度为10位
+func newClientID() string {
+	timestamp := time.Now().UnixNano()
+	chars := "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	c1 := chars[timestamp%26]
+	c2 := chars[(timestamp/26)%26]
+	return fmt.Sprintf("%c%c%08d", c1, c2, timestamp%100000000)
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestClientDBConcurrentLoad(t *testing.T) {
+	server, ts := newTestServer(t)
+	admin := loginClient(t, ts)
+
+	const agents = 8
+	ids := make([]string, agents)
+	for i := range ids {
+		resp, err := postJSON(admin, ts.URL+"/api/clients/add", map[string]string{"name": fmt.Sprintf("agent-%d", i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result map[string]string
+		json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		ids[i] = result["id"]
+	}
+
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws?id="
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+
+	// 模拟客户端持续上报指标
+	for i, id := range ids {
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			conn, _, err := websocket.DefaultDialer.Dial(wsURL+id, nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer conn.Close()
+			for n := 0; ; n++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				m := Metrics{CPU: float64(i + 1), Memory: float64(n % 100)}
+				if err := conn.WriteJSON(m); err != nil {
+					t.Error(err)
+					return
+				}
+				time.Sleep(time.Millisecond)
+			}
+		}(i, id)
+	}
+
+	// 匿名和已登录用户同时读取，管理员同时修改
+	anonymous := &http.Client{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if i%2 == 0 {
+					for _, c := range getClients(t, anonymous, ts.URL) {
+						if c.ID != "" {
+							t.Error("未登录用户不应看到客户端ID")
+							return
+						}
+					}
+				} else {
+					for _, c := range getClients(t, admin, ts.URL) {
+						if c.ID == "" {
+							t.Error("已登录用户应当看到客户端ID")
+							return
+						}
+					}
+				}
+			}
+		}(i)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for n := 0; ; n++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			id := ids[n%len(ids)]
+			resp, err := postJSON(admin, ts.URL+"/api/clients/rename", map[string]string{"id": id, "name": fmt.Sprintf("renamed-%d", n)})
+			if err == nil {
+				resp.Body.Close()
+			}
+			orders := make(map[string]int)
+			for i, id := range ids {
+				orders[id] = (i + n) % len(ids)
+			}
+			resp, err = postJSON(admin, ts.URL+"/api/clients/reorder", map[string]any{"orders": orders})
+			if err == nil {
+				resp.Body.Close()
+			}
+		}
+	}()
+
+	time.Sleep(time.Second)
+
+	// 所有客户端都应在线并带有最新指标
+	for _, c := range getClients(t, admin, ts.URL) {
+		if !c.Connected || c.CPU == 0 {
+			t.Errorf("客户端 %s 状态异常: connected=%v cpu=%v", c.ID, c.Connected, c.CPU)
+		}
+	}
+
+	close(stop)
+	wg.Wait()
+
+	// 客户端断开后应立即标记为离线
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		online := 0
+		for _, c := range server.clients.Snapshot().List() {
+			if c.Connected {
+				online++
+			}
+		}
+		if online == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("断开后仍有 %d 个客户端在线", online)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// 修改应已持久化
+	saved, err := server.store.ListClients()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != agents {
+		t.Fatalf("数据库中有 %d 个客户端，期望 %d", len(saved), agents)
+	}
+}
+
+func TestClientSnapshotIsImmutable(t *testing.T) {
+	st, err := openBoltStore(filepath.Join(t.TempDir(), dbFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	db := newClientDB(st, nil)
+	defer db.Close()
+
+	c := db.Add("a")
+	before := db.Snapshot()
+	list := before.List()
+	list[0].ID = ""
+	list[0].Name = "changed"
+
+	if got, _ := before.Get(c.ID); got.Name != "a" {
+		t.Fatalf("修改 List 返回值影响了快照: %q", got.Name)
+	}
+	if err := db.Rename(c.ID, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := before.Get(c.ID); got.Name != "a" {
+		t.Fatalf("旧快照被修改: %q", got.Name)
+	}
+	if got, _ := db.Snapshot().Get(c.ID); got.Name != "b" {
+		t.Fatalf("新快照未包含修改: %q", got.Name)
+	}
+	if err := db.Rename("missing", "x"); err != ErrNotFound {
+		t.Fatalf("重命名不存在的客户端应返回 ErrNotFound，实际为 %v", err)
+	}
+}
+
+func TestClientSnapshotPublishedOnRead(t *testing.T) {
+	st, err := openBoltStore(filepath.Join(t.TempDir(), dbFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	db := newClientDB(st, nil)
+	defer db.Close()
+
+	a, b, c := db.Add("a"), db.Add("b"), db.Add("c")
+	published := db.Snapshot()
+
+	// 上报指标只标记快照过期，多帧之间不重新发布
+	for i := range 10 {
+		if _, ok := db.UpdateMetrics(b.ID, Metrics{CPU: float64(i)}); !ok {
+			t.Fatal("客户端不存在")
+		}
+	}
+	if db.snap.Load() != published {
+		t.Fatal("上报指标后立即发布了快照")
+	}
+	snap := db.Snapshot()
+	if got, _ := snap.Get(b.ID); snap == published || got.CPU != 9 || !got.Connected {
+		t.Fatalf("读取时发布的快照为 %+v", got)
+	}
+	if db.Snapshot() != snap {
+		t.Fatal("没有新的修改时不应重新发布")
+	}
+	if _, ok := db.UpdateMetrics("missing", Metrics{}); ok {
+		t.Fatal("不存在的客户端应返回 false")
+	}
+
+	// 显示顺序改变后重新排序
+	order := func() string {
+		var ids []string
+		for _, client := range db.Snapshot().List() {
+			ids = append(ids, client.Name)
+		}
+		return strings.Join(ids, ",")
+	}
+	if got := order(); got != "a,b,c" {
+		t.Fatalf("显示顺序为 %s", got)
+	}
+	db.Reorder(map[string]int{a.ID: 3, c.ID: 1})
+	if got := order(); got != "c,b,a" {
+		t.Fatalf("调整后的显示顺序为 %s", got)
+	}
+	db.Delete(b.ID)
+	db.Add("d")
+	if got := order(); got != "c,a,d" {
+		t.Fatalf("删除和添加后的显示顺序为 %s", got)
+	}
+}
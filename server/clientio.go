@@ -0,0 +1,280 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxImportSize 导入文件的最大字节数
+const maxImportSize = 10 << 20
+
+// importIDRe 导入时指定的客户端ID的格式，ID 会出现在 URL 和数据库的键中
+var importIDRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// csvColumns 导出的 CSV 文件的列，导入时按表头识别，缺少的列保持原值
+var csvColumns = []string{"id", "name", "displayOrder", "group", "tags", "badgeId", "secretHash", "enrolledBy"}
+
+// ClientRecord 表示导入导出的一个客户端，字段与 Client 的 JSON 名称一致，
+// 因此旧版本的 clients.json 可以直接导入
+type ClientRecord struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	DisplayOrder int      `json:"displayOrder"`
+	Group        string   `json:"group,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+	BadgeID      string   `json:"badgeId,omitempty"`
+	SecretHash   string   `json:"secretHash,omitempty"` // 迁移后自助注册的客户端可以继续使用原来的密钥
+	EnrolledBy   string   `json:"enrolledBy,omitempty"`
+
+	// columns CSV 文件中存在的列，为空表示所有列都存在
+	columns map[string]bool
+}
+
+// FieldChange 表示导入时客户端一个字段的变化
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// ClientDiff 表示导入对一个客户端的修改
+type ClientDiff struct {
+	ID      string        `json:"id"`
+	Name    string        `json:"name"`
+	Action  string        `json:"action"` // create 或 update
+	Changes []FieldChange `json:"changes,omitempty"`
+}
+
+// ImportResult 表示导入或试运行的结果
+type ImportResult struct {
+	DryRun    bool         `json:"dryRun"`
+	Created   int          `json:"created"`
+	Updated   int          `json:"updated"`
+	Unchanged int          `json:"unchanged"`
+	Diffs     []ClientDiff `json:"diffs"` // 新建和修改的客户端，按文件中的顺序排列
+}
+
+// newClientRecord 返回客户端用于导出的记录
+func newClientRecord(c *Client) ClientRecord {
+	return ClientRecord{
+		ID:           c.ID,
+		Name:         c.Name,
+		DisplayOrder: c.DisplayOrder,
+		Group:        c.Group,
+		Tags:         c.Tags,
+		BadgeID:      c.BadgeID,
+		SecretHash:   c.SecretHash,
+		EnrolledBy:   c.EnrolledBy,
+	}
+}
+
+// has 判断导入的记录是否包含某一列
+func (r *ClientRecord) has(column string) bool {
+	return r.columns == nil || r.columns[column]
+}
+
+// writeClientsJSON 以 clients.json 的格式导出客户端：以ID为键的对象
+func writeClientsJSON(w io.Writer, records []ClientRecord) error {
+	byID := make(map[string]ClientRecord, len(records))
+	for _, r := range records {
+		byID[r.ID] = r
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(byID)
+}
+
+// writeClientsCSV 以 CSV 格式导出客户端，多个标签以逗号分隔
+func writeClientsCSV(w io.Writer, records []ClientRecord) error {
+	cw := csv.NewWriter(w)
+	cw.Write(csvColumns)
+	for _, r := range records {
+		cw.Write([]string{
+			r.ID, r.Name, strconv.Itoa(r.DisplayOrder), r.Group, strings.Join(r.Tags, ","),
+			r.BadgeID, r.SecretHash, r.EnrolledBy,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// readClientsJSON 读取 JSON 格式的客户端，支持以ID为键的对象（clients.json）或记录的数组
+func readClientsJSON(r io.Reader) ([]ClientRecord, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var list []ClientRecord
+	if err := json.Unmarshal(data, &list); err == nil {
+		return list, nil
+	}
+	var byID map[string]ClientRecord
+	if err := json.Unmarshal(data, &byID); err != nil {
+		return nil, fmt.Errorf("JSON 格式无效: %w", err)
+	}
+	// 对象没有顺序，按显示顺序和ID排列，使新建客户端的顺序稳定
+	for id, record := range byID {
+		record.ID = id
+		list = append(list, record)
+	}
+	slices.SortFunc(list, func(a, b ClientRecord) int {
+		if a.DisplayOrder != b.DisplayOrder {
+			return a.DisplayOrder - b.DisplayOrder
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return list, nil
+}
+
+// readClientsCSV 读取 CSV 格式的客户端，第一行为表头，必须包含 id 和 name 列
+func readClientsCSV(r io.Reader) ([]ClientRecord, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("CSV 格式无效: %w", err)
+	}
+	columns := make(map[string]bool, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		if !slices.Contains(csvColumns, name) {
+			return nil, fmt.Errorf("CSV 表头中的列 %q 无效", name)
+		}
+		header[i] = name
+		columns[name] = true
+	}
+	if !columns["id"] || !columns["name"] {
+		return nil, errors.New("CSV 表头必须包含 id 和 name 列")
+	}
+
+	var list []ClientRecord
+	for line := 2; ; line++ {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return list, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("CSV 格式无效: %w", err)
+		}
+		record := ClientRecord{columns: columns}
+		for i, value := range row {
+			if i >= len(header) {
+				return nil, fmt.Errorf("第 %d 行的列数多于表头", line)
+			}
+			value = strings.TrimSpace(value)
+			switch header[i] {
+			case "id":
+				record.ID = value
+			case "name":
+				record.Name = value
+			case "displayOrder":
+				if value == "" {
+					continue
+				}
+				if record.DisplayOrder, err = strconv.Atoi(value); err != nil {
+					return nil, fmt.Errorf("第 %d 行的显示顺序 %q 无效", line, value)
+				}
+			case "group":
+				record.Group = value
+			case "tags":
+				if value != "" {
+					record.Tags = strings.Split(value, ",")
+				}
+			case "badgeId":
+				record.BadgeID = value
+			case "secretHash":
+				record.SecretHash = value
+			case "enrolledBy":
+				record.EnrolledBy = value
+			}
+		}
+		list = append(list, record)
+	}
+}
+
+// normalizeImport 检查并规范化导入的记录，ID 为空表示新建客户端
+func normalizeImport(records []ClientRecord) error {
+	seen := make(map[string]bool, len(records))
+	for i := range records {
+		r := &records[i]
+		where := fmt.Sprintf("第 %d 个客户端", i+1)
+		if r.ID != "" {
+			if !importIDRe.MatchString(r.ID) {
+				return fmt.Errorf("%s的ID %q 无效", where, r.ID)
+			}
+			if seen[r.ID] {
+				return fmt.Errorf("客户端ID %s 重复", r.ID)
+			}
+			seen[r.ID] = true
+		}
+		r.Name = strings.TrimSpace(r.Name)
+		if r.Name == "" && r.has("name") {
+			return fmt.Errorf("%s的名称为空", where)
+		}
+		if r.DisplayOrder < 0 {
+			return fmt.Errorf("%s的显示顺序不能为负数", where)
+		}
+		r.Group = normalizeGroup(r.Group)
+		r.Tags = normalizeTags(r.Tags)
+		if err := validateTags(r.Tags); err != nil {
+			return fmt.Errorf("%s: %w", where, err)
+		}
+	}
+	return nil
+}
+
+// applyRecord 把导入的记录写入客户端并返回变化的字段
+// 名称、分组和标签总是替换；显示顺序为 0 时保持不变；徽章标识、密钥和注册令牌为空时保持不变，不能通过导入清除
+func applyRecord(c *Client, r *ClientRecord) []FieldChange {
+	var changes []FieldChange
+	set := func(field string, dst *string, value string) {
+		if *dst != value {
+			changes = append(changes, FieldChange{Field: field, Old: *dst, New: value})
+			*dst = value
+		}
+	}
+	if r.has("name") {
+		set("name", &c.Name, r.Name)
+	}
+	if r.DisplayOrder != 0 && c.DisplayOrder != r.DisplayOrder {
+		changes = append(changes, FieldChange{Field: "displayOrder", Old: strconv.Itoa(c.DisplayOrder), New: strconv.Itoa(r.DisplayOrder)})
+		c.DisplayOrder = r.DisplayOrder
+	}
+	if r.has("group") {
+		set("group", &c.Group, r.Group)
+	}
+	if r.has("tags") && !slices.Equal(c.Tags, r.Tags) {
+		changes = append(changes, FieldChange{Field: "tags", Old: strings.Join(c.Tags, ","), New: strings.Join(r.Tags, ",")})
+		c.Tags = r.Tags
+	}
+	if r.BadgeID != "" {
+		set("badgeId", &c.BadgeID, r.BadgeID)
+	}
+	if r.SecretHash != "" && c.SecretHash != r.SecretHash {
+		// 不在差异中显示密钥的哈希值
+		changes = append(changes, FieldChange{Field: "secretHash", Old: mask(c.SecretHash), New: mask(r.SecretHash)})
+		c.SecretHash = r.SecretHash
+	}
+	if r.EnrolledBy != "" {
+		set("enrolledBy", &c.EnrolledBy, r.EnrolledBy)
+	}
+	return changes
+}
+
+// mask 用于在导入差异中隐藏敏感的值，只显示是否设置
+func mask(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "******"
+}
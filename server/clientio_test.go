@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestClientRecordFormats(t *testing.T) {
+	records := []ClientRecord{
+		{ID: "A1", Name: "web, 1", DisplayOrder: 1, Group: "prod/web", Tags: []string{"a", "env=prod"}},
+		{ID: "B2", Name: "db", DisplayOrder: 2, BadgeID: "b", SecretHash: "h", EnrolledBy: "asg"},
+	}
+	var buf bytes.Buffer
+	if err := writeClientsCSV(&buf, records); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readClientsCSV(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range got {
+		got[i].columns = nil
+	}
+	if len(got) != 2 || got[0].Name != "web, 1" || !slices.Equal(got[0].Tags, records[0].Tags) || got[1].SecretHash != "h" {
+		t.Fatalf("CSV 读回 %+v", got)
+	}
+
+	// 旧版本的 clients.json 以ID为键，包含运行时的字段
+	legacy := `{"B2": {"name": "db", "displayOrder": 2, "cpu": 3}, "A1": {"name": "web", "displayOrder": 1}}`
+	got, err = readClientsJSON(strings.NewReader(legacy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].ID != "A1" || got[1].ID != "B2" || got[1].Name != "db" {
+		t.Fatalf("clients.json 读取为 %+v", got)
+	}
+
+	if _, err := readClientsCSV(strings.NewReader("id,cpu\n")); err == nil {
+		t.Error("未知的列应返回错误")
+	}
+	if _, err := readClientsCSV(strings.NewReader("group\nprod\n")); err == nil {
+		t.Error("缺少 id 和 name 列应返回错误")
+	}
+	for _, bad := range [][]ClientRecord{
+		{{ID: "a/b", Name: "x"}},
+		{{ID: "a", Name: "x"}, {ID: "a", Name: "y"}},
+		{{Name: " "}},
+		{{Name: "x", Tags: []string{"a,b"}}},
+	} {
+		if err := normalizeImport(bad); err == nil {
+			t.Errorf("%+v 应返回错误", bad)
+		}
+	}
+}
+
+// importClients 以 POST 请求导入客户端并返回响应
+func importClients(t *testing.T, c *http.Client, url, body string, wantStatus int) ImportResult {
+	t.Helper()
+	resp, err := c.Post(url, "text/plain", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != wantStatus {
+		msg, _ := io.ReadAll(resp.Body)
+		t.Fatalf("POST %s 返回 %s: %s", url, resp.Status, msg)
+	}
+	var result ImportResult
+	if wantStatus == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return result
+}
+
+func TestImportExportClients(t *testing.T) {
+	server, ts := newTestServer(t)
+	admin := loginClient(t, ts)
+	web := addClient(t, admin, ts, "web")
+	db := addClient(t, admin, ts, "db")
+	mustPost(t, admin, ts.URL+"/api/clients/tags", map[string]any{"ids": []string{web}, "add": []string{"team-a"}}, http.StatusOK)
+
+	resp, err := admin.Get(ts.URL + "/api/clients/export?format=csv&tag=team-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exported, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if want := "id,name,displayOrder,group,tags,badgeId,secretHash,enrolledBy\n" + web + ",web,1,,team-a,,,\n"; string(exported) != want {
+		t.Fatalf("导出的 CSV 为:\n%s", exported)
+	}
+
+	// 只有名称和分组列：修改 web，新建一个客户端，db 不在文件中保持不变
+	csv := "id,name,group\n" + web + ",web-1,prod\n,cache,prod\n"
+	result := importClients(t, admin, ts.URL+"/api/clients/import?format=csv&dryRun=1", csv, http.StatusOK)
+	if !result.DryRun || result.Created != 1 || result.Updated != 1 || len(result.Diffs) != 2 {
+		t.Fatalf("试运行结果为 %+v", result)
+	}
+	if d := result.Diffs[0]; d.ID != web || d.Action != "update" || len(d.Changes) != 2 || d.Changes[0] != (FieldChange{"name", "web", "web-1"}) {
+		t.Fatalf("修改的差异为 %+v", d)
+	}
+	if server.clients.Snapshot().Len() != 2 {
+		t.Fatal("试运行不应修改客户端")
+	}
+
+	result = importClients(t, admin, ts.URL+"/api/clients/import?format=csv", csv, http.StatusOK)
+	if result.DryRun || result.Created != 1 || result.Updated != 1 {
+		t.Fatalf("导入结果为 %+v", result)
+	}
+	clients := getClients(t, admin, ts.URL)
+	if len(clients) != 3 || clients[0].Name != "web-1" || clients[0].Group != "prod" ||
+		!slices.Equal(clients[0].Tags, []string{"team-a"}) || clients[2].Name != "cache" || clients[2].Group != "prod" {
+		t.Fatalf("导入后的客户端为 %+v", clients)
+	}
+	result = importClients(t, admin, ts.URL+"/api/clients/import?format=csv", csv, http.StatusOK)
+	if result.Created != 1 || result.Unchanged != 1 {
+		t.Fatalf("再次导入的结果为 %+v", result)
+	}
+
+	// 任一记录无效时不做任何修改
+	before := server.clients.Snapshot().List()
+	importClients(t, admin, ts.URL+"/api/clients/import", `[{"id": "`+db+`", "name": "x", "badgeId": "b"}, {"name": "y", "badgeId": "b"}]`, http.StatusBadRequest)
+	importClients(t, admin, ts.URL+"/api/clients/import", `{"`+db+`": {"name": ""}}`, http.StatusBadRequest)
+	if after := server.clients.Snapshot().List(); !slices.EqualFunc(before, after, func(a, b Client) bool { return a.Name == b.Name }) {
+		t.Fatal("导入失败时不应修改客户端")
+	}
+
+	// 迁移到另一个服务器后，自助注册的客户端可以继续使用原来的密钥连接
+	server.clients.AddEnrolled("asg-1", "prod", hashSecret("s3cret"), "asg")
+	resp, err = admin.Get(ts.URL + "/api/clients/export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exported, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	other, ots := newTestServer(t)
+	otherAdmin := loginClient(t, ots)
+	result = importClients(t, otherAdmin, ots.URL+"/api/clients/import", string(exported), http.StatusOK)
+	if result.Created != 5 {
+		t.Fatalf("迁移结果为 %+v", result)
+	}
+	var enrolled Client
+	for _, c := range other.clients.Snapshot().List() {
+		if c.Name == "asg-1" {
+			enrolled = c
+		}
+	}
+	if _, ok := server.clients.Snapshot().Get(enrolled.ID); !ok || enrolled.EnrolledBy != "asg" || !enrolled.checkSecret("s3cret") {
+		t.Fatalf("迁移后的客户端为 %+v", enrolled)
+	}
+
+	resp, err = http.Get(ts.URL + "/api/clients/export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("匿名访问状态码为 %d", resp.StatusCode)
+	}
+}
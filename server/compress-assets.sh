@@ -0,0 +1,10 @@
+#!/bin/sh
+# 为可压缩的网页资源预先生成 .br 和 .gz，由 go generate 在编译前调用，go:embed 会把它们一并编译进程序
+# 需要 brotli 和 gzip 命令；生成的文件不提交到仓库，修改资源后需要重新生成或删除
+set -e
+cd "$(dirname "$0")"
+find assets templates -type f \( -name '*.js' -o -name '*.css' -o -name '*.html' -o -name '*.json' -o -name '*.svg' \) |
+while read -r f; do
+	brotli --force --best --output="$f.br" "$f"
+	gzip --force --best --keep --no-name "$f"
+done
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule 是解析后的 cron 表达式，每个字段用位集合表示允许的取值
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// 日和周都有限制时满足其一即可；任一字段以 * 开头（包括 */n）时两者都要满足，与标准 cron 一致
+	domAny, dowAny bool
+}
+
+// cronField 描述 cron 表达式中一个字段的取值范围
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = [5]cronField{
+	{"分钟", 0, 59},
+	{"小时", 0, 23},
+	{"日", 1, 31},
+	{"月", 1, 12},
+	{"周", 0, 7}, // 0 和 7 都表示周日
+}
+
+// parseCron 解析 5 个字段的 cron 表达式：分 时 日 月 周
+// 每个字段支持 *、数字、范围 a-b、列表 a,b 和步长 */n、a-b/n
+func parseCron(expr string) (*cronSchedule, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("cron 表达式 %q 应包含 5 个字段：分 时 日 月 周", expr)
+	}
+	var bits [5]uint64
+	for i, part := range parts {
+		b, err := parseCronField(part, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("cron 表达式 %q 无效: %w", expr, err)
+		}
+		bits[i] = b
+	}
+	// 周日统一记为 0
+	if bits[4]&(1<<7) != 0 {
+		bits[4] = bits[4]&^(1<<7) | 1
+	}
+	return &cronSchedule{
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    bits[4],
+		domAny: strings.HasPrefix(parts[2], "*"),
+		dowAny: strings.HasPrefix(parts[4], "*"),
+	}, nil
+}
+
+// parseCronField 解析一个字段，返回允许取值的位集合
+func parseCronField(s string, f cronField) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(s, ",") {
+		rng, stepStr, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("%s字段的步长 %q 无效", f.name, stepStr)
+			}
+			step = n
+		}
+
+		lo, hi := f.min, f.max
+		if rng != "*" {
+			loStr, hiStr, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(loStr); err != nil {
+				return 0, fmt.Errorf("%s字段的值 %q 无效", f.name, loStr)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(hiStr); err != nil {
+					return 0, fmt.Errorf("%s字段的值 %q 无效", f.name, hiStr)
+				}
+			} else if hasStep {
+				// a/n 表示从 a 开始到最大值
+				hi = f.max
+			}
+			if lo < f.min || hi > f.max || lo > hi {
+				return 0, fmt.Errorf("%s字段的范围 %q 无效，取值应在 %d-%d 之间", f.name, rng, f.min, f.max)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// matches 判断某一分钟是否满足表达式，使用 t 所在的时区
+func (c *cronSchedule) matches(t time.Time) bool {
+	if c.minute&(1<<t.Minute()) == 0 || c.hour&(1<<t.Hour()) == 0 || c.month&(1<<int(t.Month())) == 0 {
+		return false
+	}
+	domOK := c.dom&(1<<t.Day()) != 0
+	dowOK := c.dow&(1<<int(t.Weekday())) != 0
+	if c.domAny || c.dowAny {
+		return domOK && dowOK
+	}
+	return domOK || dowOK
+}
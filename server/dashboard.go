@@ -0,0 +1,455 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 终端控制序列
+const (
+	ansiClear  = "\x1b[H\x1b[2J"
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiDim    = "\x1b[2m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+	ansiRed    = "\x1b[31m"
+)
+
+// dashboardSort 仪表盘的一种排序方式
+type dashboardSort struct {
+	key, label string
+	less       func(a, b *Client) bool
+}
+
+// dashboardSorts 仪表盘支持的排序方式，与网页的卡片一样默认按显示顺序
+var dashboardSorts = []dashboardSort{
+	{"order", "显示顺序", func(a, b *Client) bool { return a.DisplayOrder < b.DisplayOrder }},
+	{"name", "名称", func(a, b *Client) bool { return a.Name < b.Name }},
+	{"cpu", "CPU", func(a, b *Client) bool { return a.CPU > b.CPU }},
+	{"mem", "内存", func(a, b *Client) bool { return a.Memory > b.Memory }},
+	{"disk", "磁盘", func(a, b *Client) bool { return a.DiskUsage > b.DiskUsage }},
+	{"net", "网络", func(a, b *Client) bool { return a.UploadSpeed+a.DownloadSpeed > b.UploadSpeed+b.DownloadSpeed }},
+}
+
+// dashboardMetrics 详情页显示历史的指标，percent 为 true 的按 0-100 绘制，其余按最大值缩放
+var dashboardMetrics = []struct {
+	name, label string
+	percent     bool
+}{
+	{"cpu", "CPU", true},
+	{"memory", "内存", true},
+	{"diskUsage", "磁盘", true},
+	{"diskReadSpeed", "读取", false},
+	{"diskWriteSpeed", "写入", false},
+	{"uploadSpeed", "上传", false},
+	{"downloadSpeed", "下载", false},
+}
+
+// dashboard 终端仪表盘的状态，列表页显示所有客户端，详情页显示一个客户端的历史指标
+type dashboard struct {
+	query  url.Values // 传给服务端的筛选参数
+	sortBy string
+	filter string // 名称、分组或标签中包含的文本，不区分大小写
+	span   time.Duration
+	width  int
+	color  bool
+
+	clients []Client
+	detail  string // 详情页的客户端 ID，为空时显示列表页
+	client  Client
+	history map[string][]MetricPoint
+	message string // 上一条命令的提示
+	err     error  // 上一次读取数据的错误
+}
+
+// adminTop 在终端中显示实时刷新的仪表盘，输入命令后回车执行
+func adminTop(ctx context.Context, c *adminClient, args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("top", flag.ContinueOnError)
+	interval := fs.Duration("interval", 2*time.Second, "刷新间隔")
+	width := fs.Int("width", terminalWidth(), "终端宽度，默认读取环境变量 COLUMNS")
+	noColor := fs.Bool("no-color", os.Getenv("NO_COLOR") != "", "不使用颜色")
+	query := filterFlags(fs)
+	if err := parseArgs(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if c.offline {
+		return errors.New("仪表盘需要使用 -url 连接运行中的服务端")
+	}
+	if *interval <= 0 {
+		return errors.New("刷新间隔必须大于 0")
+	}
+
+	d := &dashboard{query: query(), sortBy: "order", span: time.Hour, width: *width, color: !*noColor}
+
+	// 标准输入关闭后不再接受命令，仪表盘继续刷新
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		d.refresh(ctx, c)
+		if ctx.Err() != nil {
+			return nil
+		}
+		var buf bytes.Buffer
+		buf.WriteString(ansiClear)
+		d.render(&buf, time.Now())
+		if _, err := out.Write(buf.Bytes()); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case line := <-lines:
+			if d.handle(line) {
+				return nil
+			}
+		}
+	}
+}
+
+// terminalWidth 返回环境变量 COLUMNS 中的终端宽度，没有设置时为 120
+func terminalWidth() int {
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	return 120
+}
+
+// refresh 读取当前页面需要的数据，出错时保留上一次的数据
+func (d *dashboard) refresh(ctx context.Context, c *adminClient) {
+	d.err = nil
+	if d.detail == "" {
+		var clients []Client
+		if err := c.call(ctx, http.MethodGet, apiV1Prefix+"/clients?"+d.query.Encode(), nil, &clients); err != nil {
+			d.err = err
+			return
+		}
+		d.clients = clients
+		return
+	}
+
+	var client Client
+	if err := c.call(ctx, http.MethodGet, apiV1Prefix+"/clients/"+url.PathEscape(d.detail), nil, &client); err != nil {
+		d.err = err
+		return
+	}
+	history := make(map[string][]MetricPoint)
+	for _, m := range dashboardMetrics {
+		query := url.Values{"id": {d.detail}, "metric": {m.name}, "range": {d.span.String()}}
+		var points []MetricPoint
+		if err := c.call(ctx, http.MethodGet, "/api/clients/history?"+query.Encode(), nil, &points); err != nil {
+			d.err = err
+			return
+		}
+		history[m.name] = points
+	}
+	d.client, d.history = client, history
+}
+
+// handle 执行一条命令，返回 true 表示退出
+func (d *dashboard) handle(line string) bool {
+	line = strings.TrimSpace(line)
+	d.message = ""
+	switch {
+	case line == "":
+		// 立即刷新
+	case line == "q":
+		return true
+	case line == "b":
+		d.detail = ""
+	case strings.HasPrefix(line, "/"):
+		d.filter = strings.TrimSpace(line[1:])
+	case strings.HasPrefix(line, "s "), line == "s":
+		key := strings.TrimSpace(strings.TrimPrefix(line, "s"))
+		if _, ok := findSort(key); !ok {
+			d.message = "排序方式 " + key + " 无效，可选 order、name、cpu、mem、disk、net"
+			break
+		}
+		d.sortBy = key
+	case strings.HasPrefix(line, "r "):
+		span, err := time.ParseDuration(strings.TrimSpace(line[2:]))
+		if err != nil || span <= 0 || span > historyRetention {
+			d.message = "时间范围无效，如 1h、24h，最长 " + historyRetention.String()
+			break
+		}
+		d.span = span
+	default:
+		n, err := strconv.Atoi(line)
+		visible := d.visible()
+		if err != nil || d.detail != "" || n < 1 || n > len(visible) {
+			d.message = "未知的命令 " + line
+			break
+		}
+		d.detail = visible[n-1].ID
+		d.client, d.history = Client{}, nil
+	}
+	return false
+}
+
+// findSort 按名称查找排序方式
+func findSort(key string) (dashboardSort, bool) {
+	i := slices.IndexFunc(dashboardSorts, func(s dashboardSort) bool { return s.key == key })
+	if i < 0 {
+		return dashboardSort{}, false
+	}
+	return dashboardSorts[i], true
+}
+
+// visible 返回列表页按筛选和排序后的客户端，按指标排序时离线的客户端排在最后
+func (d *dashboard) visible() []Client {
+	filter := strings.ToLower(d.filter)
+	clients := slices.DeleteFunc(slices.Clone(d.clients), func(c Client) bool {
+		if filter == "" {
+			return false
+		}
+		text := strings.ToLower(c.Name + " " + c.Group + " " + strings.Join(c.Tags, " "))
+		return !strings.Contains(text, filter)
+	})
+	s, ok := findSort(d.sortBy)
+	if !ok {
+		return clients
+	}
+	byMetric := s.key != "order" && s.key != "name"
+	slices.SortStableFunc(clients, func(a, b Client) int {
+		if byMetric && a.Connected != b.Connected {
+			if a.Connected {
+				return -1
+			}
+			return 1
+		}
+		switch {
+		case s.less(&a, &b):
+			return -1
+		case s.less(&b, &a):
+			return 1
+		}
+		return 0
+	})
+	return clients
+}
+
+// paint 在启用颜色时为文本加上控制序列
+func (d *dashboard) paint(code, s string) string {
+	if !d.color || code == "" {
+		return s
+	}
+	return code + s + ansiReset
+}
+
+// ansiUsageColor 与网页的进度条和徽章的颜色一致：低于 50% 为绿色，低于 80% 为黄色，否则为红色
+func ansiUsageColor(value float64) string {
+	switch {
+	case value < 50:
+		return ansiGreen
+	case value < 80:
+		return ansiYellow
+	}
+	return ansiRed
+}
+
+// render 输出当前页面
+func (d *dashboard) render(w io.Writer, now time.Time) {
+	if d.detail == "" {
+		d.renderList(w, now)
+	} else {
+		d.renderDetail(w, now)
+	}
+	if d.err != nil {
+		fmt.Fprintln(w, d.paint(ansiRed, "读取数据出错: "+d.err.Error()))
+	}
+	if d.message != "" {
+		fmt.Fprintln(w, d.paint(ansiYellow, d.message))
+	}
+}
+
+// renderList 输出客户端列表
+func (d *dashboard) renderList(w io.Writer, now time.Time) {
+	clients := d.visible()
+	online := 0
+	for _, c := range d.clients {
+		if c.Connected {
+			online++
+		}
+	}
+	s, _ := findSort(d.sortBy)
+	header := fmt.Sprintf("Gonitor  %d 个客户端，%d 个在线  排序: %s", len(d.clients), online, s.label)
+	if d.filter != "" {
+		header += "  筛选: " + d.filter
+	}
+	fmt.Fprintf(w, "%s  %s\n\n", d.paint(ansiBold, header), now.Format("15:04:05"))
+
+	barWidth := 10
+	if d.width < 110 {
+		barWidth = 5
+	}
+	nameWidth := 16
+	usageWidth := barWidth + 8
+	fmt.Fprintf(w, "%s %s %s %s %s %s %s %s\n", padRight("#", 3), padRight("名称", nameWidth), padRight("分组", 12),
+		padRight("CPU", usageWidth), padRight("内存", usageWidth), padRight("磁盘", usageWidth), padRight("上传", 11), "下载")
+	for i, c := range clients {
+		row := fmt.Sprintf("%s %s %s ", padRight(strconv.Itoa(i+1), 3), padRight(c.Name, nameWidth), padRight(orDash(c.Group), 12))
+		if !c.Connected {
+			fmt.Fprintln(w, d.paint(ansiDim, row+"离线，最后在线 "+formatLastSeen(c.LastSeen, now)))
+			continue
+		}
+		for _, v := range []float64{c.CPU, c.Memory, c.DiskUsage} {
+			row += d.bar(v, barWidth) + " "
+		}
+		row += padRight(formatRate(c.UploadSpeed), 11) + " " + formatRate(c.DownloadSpeed)
+		fmt.Fprintln(w, row)
+	}
+	if len(clients) == 0 {
+		fmt.Fprintln(w, "没有客户端")
+	}
+	fmt.Fprintf(w, "\n输入命令后回车：序号 查看历史  s order|name|cpu|mem|disk|net 排序  /文本 筛选  q 退出\n")
+}
+
+// bar 返回使用率的进度条和百分比，宽度为 width+8
+func (d *dashboard) bar(value float64, width int) string {
+	filled := int(min(max(value, 0), 100)/100*float64(width) + 0.5)
+	bar := d.paint(ansiUsageColor(value), strings.Repeat("█", filled)) + strings.Repeat("░", width-filled)
+	return bar + fmt.Sprintf(" %6.1f%%", value)
+}
+
+// formatLastSeen 返回最后在线时间距现在多久
+func formatLastSeen(t time.Time, now time.Time) string {
+	if t.IsZero() {
+		return "从未连接"
+	}
+	return now.Sub(t).Truncate(time.Second).String() + "前"
+}
+
+// renderDetail 输出一个客户端的历史指标
+func (d *dashboard) renderDetail(w io.Writer, now time.Time) {
+	c := d.client
+	status := d.paint(ansiRed, "离线")
+	if c.Connected {
+		status = d.paint(ansiGreen, "在线")
+	}
+	fmt.Fprintf(w, "%s  %s  最近 %s  %s\n", d.paint(ansiBold, c.Name+" ("+d.detail+")"), status, formatSpan(d.span), now.Format("15:04:05"))
+	if c.Group != "" || len(c.Tags) > 0 {
+		fmt.Fprintf(w, "分组: %s  标签: %s\n", orDash(c.Group), orDash(strings.Join(c.Tags, ",")))
+	}
+	fmt.Fprintln(w)
+
+	chartWidth := max(10, d.width-48)
+	for _, m := range dashboardMetrics {
+		points := d.history[m.name]
+		if len(points) == 0 {
+			fmt.Fprintf(w, "%s %s\n", padRight(m.label, 5), d.paint(ansiDim, "暂无数据"))
+			continue
+		}
+		var sum, peak float64
+		for _, p := range points {
+			sum += p.Value
+			peak = max(peak, p.Value)
+		}
+		format := formatRate
+		scale := peak
+		if m.percent {
+			format = func(v float64) string { return fmt.Sprintf("%.1f%%", v) }
+			scale = 100
+		}
+		last := points[len(points)-1].Value
+		fmt.Fprintf(w, "%s %s  当前 %s  平均 %s  最高 %s\n", padRight(m.label, 5), sparkline(points, chartWidth, scale),
+			format(last), format(sum/float64(len(points))), format(peak))
+	}
+	fmt.Fprintf(w, "\n输入命令后回车：r 1h|6h|24h 时间范围  b 返回  q 退出\n")
+}
+
+// formatSpan 格式化时间范围，去掉末尾为 0 的分钟和秒，如 1h0m0s 显示为 1h
+func formatSpan(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
+
+// sparkline 把历史数据按时间顺序均分到 width 列，每列取平均值，以 0 到 scale 的高度绘制
+func sparkline(points []MetricPoint, width int, scale float64) string {
+	const levels = "▁▂▃▄▅▆▇█"
+	width = min(width, len(points))
+	var b strings.Builder
+	for i := range width {
+		bucket := points[i*len(points)/width : (i+1)*len(points)/width]
+		var sum float64
+		for _, p := range bucket {
+			sum += p.Value
+		}
+		level := 0
+		if scale > 0 {
+			level = int(sum / float64(len(bucket)) / scale * 7.99)
+		}
+		level = min(max(level, 0), 7)
+		b.WriteString(string([]rune(levels)[level]))
+	}
+	return b.String()
+}
+
+// padRight 按显示宽度在右侧补空格，中文等宽字符占两列，超出宽度时截断
+func padRight(s string, width int) string {
+	w := 0
+	for i, r := range s {
+		rw := runeWidth(r)
+		if w+rw > width {
+			s = s[:i]
+			// 截断时最后一列改为省略号
+			for displayWidth(s) > width-1 {
+				_, size := utf8.DecodeLastRuneInString(s)
+				s = s[:len(s)-size]
+			}
+			return padRight(s+"…", width)
+		}
+		w += rw
+	}
+	return s + strings.Repeat(" ", width-w)
+}
+
+// displayWidth 返回字符串在终端中的显示宽度
+func displayWidth(s string) int {
+	w := 0
+	for _, r := range s {
+		w += runeWidth(r)
+	}
+	return w
+}
+
+// runeWidth 粗略判断字符的显示宽度，中日韩文字和全角符号占两列
+func runeWidth(r rune) int {
+	if r >= 0x1100 && (r <= 0x115f || (r >= 0x2e80 && r <= 0xa4cf) || (r >= 0xac00 && r <= 0xd7a3) ||
+		(r >= 0xf900 && r <= 0xfaff) || (r >= 0xfe30 && r <= 0xfe4f) || (r >= 0xff00 && r <= 0xff60) || (r >= 0xffe0 && r <= 0xffe6)) {
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDashboardCommands(t *testing.T) {
+	now := time.Now()
+	d := &dashboard{sortBy: "order", span: time.Hour, width: 120, clients: []Client{
+		{ID: "a", Name: "web-1", Group: "prod/web", DisplayOrder: 1, Connected: true, CPU: 20, Memory: 90, UploadSpeed: 2048},
+		{ID: "b", Name: "数据库服务器", Group: "prod/db", DisplayOrder: 2, Connected: true, CPU: 75, Memory: 10},
+		{ID: "c", Name: "backup", Tags: []string{"web"}, DisplayOrder: 3, LastSeen: now.Add(-time.Minute)},
+	}}
+	ids := func() string {
+		var s []string
+		for _, c := range d.visible() {
+			s = append(s, c.ID)
+		}
+		return strings.Join(s, ",")
+	}
+
+	if ids() != "a,b,c" {
+		t.Fatalf("默认按显示顺序，实际为 %s", ids())
+	}
+	// 按指标排序时离线的客户端排在最后
+	d.handle("s cpu")
+	if ids() != "b,a,c" {
+		t.Fatalf("按 CPU 排序为 %s", ids())
+	}
+	d.handle("s name")
+	if ids() != "c,a,b" {
+		t.Fatalf("按名称排序为 %s", ids())
+	}
+	d.handle("s size")
+	if d.sortBy != "name" || d.message == "" {
+		t.Fatalf("无效的排序方式应保持原来的排序并提示，排序为 %s", d.sortBy)
+	}
+
+	// 筛选名称、分组和标签
+	d.handle("/WEB")
+	if ids() != "c,a" {
+		t.Fatalf("筛选 web 的结果为 %s", ids())
+	}
+	var out strings.Builder
+	d.render(&out, now)
+	screen := out.String()
+	for _, want := range []string{"3 个客户端，2 个在线", "筛选: WEB", "web-1", "20.0%", "2.0 MB/s", "离线，最后在线 1m0s前"} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("列表页中缺少 %q:\n%s", want, screen)
+		}
+	}
+	if strings.Contains(screen, "\x1b[") {
+		t.Errorf("不使用颜色时不应输出控制序列:\n%s", screen)
+	}
+
+	// 序号对应当前显示的顺序
+	d.handle("2")
+	if d.detail != "a" {
+		t.Fatalf("打开的客户端为 %q", d.detail)
+	}
+	d.handle("3")
+	if d.detail != "a" || d.message == "" {
+		t.Fatal("详情页中序号命令应提示未知的命令")
+	}
+	d.handle("r 24h")
+	if d.span != 24*time.Hour || formatSpan(d.span) != "24h" {
+		t.Fatalf("时间范围为 %v", d.span)
+	}
+	d.handle("r forever")
+	if d.span != 24*time.Hour || d.message == "" {
+		t.Fatal("无效的时间范围应提示")
+	}
+	d.handle("b")
+	d.handle("/")
+	if d.detail != "" || ids() != "c,a,b" {
+		t.Fatalf("返回列表页后为 %q, %s", d.detail, ids())
+	}
+	if !d.handle("q") {
+		t.Fatal("q 应退出")
+	}
+}
+
+func TestDashboardText(t *testing.T) {
+	for _, tc := range []struct {
+		s     string
+		width int
+		want  string
+	}{
+		{"web", 5, "web  "},
+		{"数据库", 8, "数据库  "},
+		{"数据库服务器", 8, "数据库… "},
+		{"web-server-01", 8, "web-ser…"},
+	} {
+		if got := padRight(tc.s, tc.width); got != tc.want {
+			t.Errorf("padRight(%q, %d) = %q，期望 %q", tc.s, tc.width, got, tc.want)
+		}
+	}
+
+	points := make([]MetricPoint, 8)
+	for i := range points {
+		points[i].Value = float64(i * 100 / 7)
+	}
+	if got := sparkline(points, 4, 100); got != "▁▃▆█" {
+		t.Errorf("sparkline = %q", got)
+	}
+	if got := sparkline(points[:2], 10, 0); got != "▁▁" {
+		t.Errorf("数据点少于宽度时 sparkline = %q", got)
+	}
+	if got := formatSpan(90 * time.Minute); got != "1h30m" {
+		t.Errorf("formatSpan = %q", got)
+	}
+}
+
+func TestDashboardOverAPI(t *testing.T) {
+	server, ts := newTestServer(t)
+	browser := loginClient(t, ts)
+	id := addClient(t, browser, ts, "web")
+	addClient(t, browser, ts, "db")
+	server.clients.UpdateMetrics(id, Metrics{CPU: 42})
+	base := time.Now().Truncate(historyResolution).Add(-2 * historyResolution)
+	server.history.Record(id, Metrics{CPU: 10}, base)
+	server.history.Record(id, Metrics{CPU: 30}, base.Add(historyResolution))
+	server.history.Flush(time.Now(), false)
+	t.Setenv(passwordEnv, "admin")
+
+	in, commands := io.Pipe()
+	r, w := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- admin(context.Background(), []string{"-url", ts.URL, "top", "-interval", "1h", "-no-color"}, in, w)
+		w.Close()
+	}()
+	screens := bufio.NewScanner(r)
+	// waitLine 读取输出直到出现包含 want 的行
+	waitLine := func(want string) {
+		t.Helper()
+		for screens.Scan() {
+			if strings.Contains(screens.Text(), want) {
+				return
+			}
+		}
+		t.Fatalf("输出中没有 %q", want)
+	}
+
+	waitLine("42.0%")
+	commands.Write([]byte("s cpu\n"))
+	waitLine("排序: CPU")
+	commands.Write([]byte("1\n"))
+	waitLine("web (" + id + ")")
+	waitLine("当前 30.0%  平均 20.0%  最高 30.0%")
+	commands.Write([]byte("q\n"))
+	commands.Close()
+	go io.Copy(io.Discard, r)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+// secretHeader 自助注册的客户端连接时携带密钥使用的请求头
+const secretHeader = "X-Gonitor-Secret"
+
+// maxClientName 客户端注册时提交的名称的最大长度（字符数）
+const maxClientName = 64
+
+var (
+	errEnrollTokenExpired = errors.New("注册令牌已过期")
+	errEnrollTokenUsedUp  = errors.New("注册令牌的使用次数已用完")
+)
+
+// EnrollTokenStatus 在注册令牌的基础上附带当前是否可用
+type EnrollTokenStatus struct {
+	EnrollToken
+	Valid bool `json:"valid"`
+}
+
+// usable 判断令牌在 now 时是否还可以注册客户端
+func (t *EnrollToken) usable(now time.Time) error {
+	if !t.ExpiresAt.IsZero() && !now.Before(t.ExpiresAt) {
+		return errEnrollTokenExpired
+	}
+	if t.MaxUses > 0 && t.Uses >= t.MaxUses {
+		return errEnrollTokenUsedUp
+	}
+	return nil
+}
+
+// hashSecret 返回客户端密钥的哈希值，数据库中只保存哈希值
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// checkSecret 判断客户端连接时携带的密钥是否正确，没有密钥的客户端（手动添加）总是通过
+func (c *Client) checkSecret(secret string) bool {
+	if c.SecretHash == "" {
+		return true
+	}
+	return subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(c.SecretHash)) == 1
+}
+
+// normalizeClientName 去掉客户端提交的名称首尾的空白并截断过长的部分，为空时使用默认名称
+func normalizeClientName(name string) string {
+	name = strings.TrimSpace(name)
+	if runes := []rune(name); len(runes) > maxClientName {
+		name = string(runes[:maxClientName])
+	}
+	if name == "" {
+		name = "未命名客户端"
+	}
+	return name
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestEnrollTokenUsable(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		token EnrollToken
+		want  error
+	}{
+		{EnrollToken{}, nil},
+		{EnrollToken{MaxUses: 2, Uses: 1, ExpiresAt: now.Add(time.Hour)}, nil},
+		{EnrollToken{MaxUses: 1, Uses: 1}, errEnrollTokenUsedUp},
+		{EnrollToken{ExpiresAt: now}, errEnrollTokenExpired},
+	}
+	for _, tt := range tests {
+		if err := tt.token.usable(now); err != tt.want {
+			t.Errorf("%+v 返回 %v，期望 %v", tt.token, err, tt.want)
+		}
+	}
+
+	if got := normalizeClientName("  " + strings.Repeat("名", 100) + " "); got != strings.Repeat("名", maxClientName) {
+		t.Errorf("名称截断为 %q", got)
+	}
+	if got := normalizeClientName(" "); got == "" {
+		t.Error("空名称应使用默认名称")
+	}
+}
+
+func TestEnroll(t *testing.T) {
+	server, ts := newTestServer(t)
+	admin := loginClient(t, ts)
+	anon := newCookieClient()
+
+	mustPost(t, admin, ts.URL+"/api/enroll-tokens/create", map[string]any{"name": "x", "expiresAt": time.Now().Add(-time.Minute)}, http.StatusBadRequest)
+	mustPost(t, anon, ts.URL+"/api/enroll-tokens/create", map[string]any{"name": "x"}, http.StatusUnauthorized)
+	created := mustPost(t, admin, ts.URL+"/api/enroll-tokens/create", map[string]any{
+		"name": "autoscaling", "group": " prod / web ", "maxUses": 1, "expiresAt": time.Now().Add(time.Hour),
+	}, http.StatusOK)
+	token := created["token"]
+	if token == "" || created["id"] == "" {
+		t.Fatalf("创建注册令牌返回 %v", created)
+	}
+
+	mustPost(t, anon, ts.URL+"/api/enroll", map[string]string{"token": "wrong", "name": "web-1"}, http.StatusForbidden)
+	enrolled := mustPost(t, anon, ts.URL+"/api/enroll", map[string]string{"token": token, "name": "web-1"}, http.StatusOK)
+	id, secret := enrolled["id"], enrolled["secret"]
+	c, ok := server.clients.Snapshot().Get(id)
+	if !ok || c.Name != "web-1" || c.Group != "prod/web" || c.EnrolledBy != "autoscaling" || c.SecretHash != hashSecret(secret) {
+		t.Fatalf("注册的客户端为 %+v", c)
+	}
+	// 单次使用的令牌不能再次注册
+	mustPost(t, anon, ts.URL+"/api/enroll", map[string]string{"token": token, "name": "web-2"}, http.StatusForbidden)
+
+	var tokens []EnrollTokenStatus
+	mustGet(t, admin, ts.URL+"/api/enroll-tokens", &tokens)
+	if len(tokens) != 1 || tokens[0].Uses != 1 || tokens[0].Valid {
+		t.Fatalf("注册令牌为 %+v", tokens)
+	}
+	if clients := getClients(t, admin, ts.URL); len(clients) != 1 || clients[0].SecretHash != "" {
+		t.Fatalf("客户端列表不应包含密钥的哈希值: %+v", clients)
+	}
+
+	// 连接时必须携带正确的密钥
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws?id=" + id
+	for _, wrong := range []string{"", "wrong"} {
+		header := http.Header{}
+		header.Set(secretHeader, wrong)
+		_, resp, err := websocket.DefaultDialer.Dial(url, header)
+		if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("密钥为 %q 时应返回 401，实际为 %v", wrong, resp)
+		}
+	}
+	header := http.Header{}
+	header.Set(secretHeader, secret)
+	conn, _, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	waitFor(t, "客户端上线", func() bool {
+		c, _ := server.clients.Snapshot().Get(id)
+		return c.Connected
+	})
+
+	// 删除令牌不影响已注册的客户端
+	mustPost(t, admin, ts.URL+"/api/enroll-tokens/delete", map[string]string{"id": tokens[0].ID}, http.StatusOK)
+	// 已删除或不存在的令牌返回 404
+	mustPost(t, admin, ts.URL+"/api/enroll-tokens/delete", map[string]string{"id": tokens[0].ID}, http.StatusNotFound)
+	mustGet(t, admin, ts.URL+"/api/enroll-tokens", &tokens)
+	if len(tokens) != 0 {
+		t.Fatalf("删除后的注册令牌为 %+v", tokens)
+	}
+	if _, ok := server.clients.Snapshot().Get(id); !ok {
+		t.Fatal("删除令牌后客户端不应被删除")
+	}
+}
@@ -2,4 +2,10 @@ module server
 
 go 1.24.1
 
-require github.com/gorilla/websocket v1.5.3
+require (
+	github.com/gorilla/websocket v1.5.3
+	go.etcd.io/bbolt v1.4.3
+	golang.org/x/term v0.28.0
+)
+
+require golang.org/x/sys v0.29.0 // indirect
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// GroupStats 表示一个分组的汇总，包含所有下级分组中的客户端
+type GroupStats struct {
+	Path      string  `json:"path"`
+	Clients   int     `json:"clients"`
+	Online    int     `json:"online"`
+	AvgCPU    float64 `json:"avgCpu"`    // 在线客户端的平均值，没有在线客户端时为 0
+	AvgMemory float64 `json:"avgMemory"` // 同上
+	AvgDisk   float64 `json:"avgDisk"`   // 同上
+}
+
+// normalizeGroup 规范化分组路径，去掉每一级首尾的空白和空的层级
+func normalizeGroup(group string) string {
+	var parts []string
+	for _, part := range strings.Split(group, "/") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// normalizeTags 去掉标签首尾的空白、空标签和重复的标签，并按名称排序，返回新的切片
+func normalizeTags(tags []string) []string {
+	var result []string
+	for _, tag := range tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			result = append(result, tag)
+		}
+	}
+	slices.Sort(result)
+	return slices.Compact(result)
+}
+
+// validateTags 检查标签中是否有不允许的字符，逗号用于在界面和筛选参数中分隔多个标签
+func validateTags(tags []string) error {
+	for _, tag := range tags {
+		if strings.Contains(tag, ",") {
+			return fmt.Errorf("标签 %q 不能包含逗号", tag)
+		}
+	}
+	return nil
+}
+
+// inGroup 判断分组路径 path 是否为 group 本身或其下级分组，group 为空时总是成立
+func inGroup(path, group string) bool {
+	return group == "" || path == group || strings.HasPrefix(path, group+"/")
+}
+
+// groupStats 按分组汇总客户端，每个客户端计入其分组和所有上级分组，结果按路径排序
+func groupStats(clients []Client) []GroupStats {
+	stats := make(map[string]*GroupStats)
+	for _, c := range clients {
+		if c.Group == "" {
+			continue
+		}
+		parts := strings.Split(c.Group, "/")
+		for i := range parts {
+			path := strings.Join(parts[:i+1], "/")
+			g := stats[path]
+			if g == nil {
+				g = &GroupStats{Path: path}
+				stats[path] = g
+			}
+			g.Clients++
+			if c.Connected {
+				g.Online++
+				g.AvgCPU += c.CPU
+				g.AvgMemory += c.Memory
+				g.AvgDisk += c.DiskUsage
+			}
+		}
+	}
+
+	list := make([]GroupStats, 0, len(stats))
+	for _, g := range stats {
+		if g.Online > 0 {
+			n := float64(g.Online)
+			g.AvgCPU, g.AvgMemory, g.AvgDisk = g.AvgCPU/n, g.AvgMemory/n, g.AvgDisk/n
+		}
+		list = append(list, *g)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+	return list
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestNormalizeGroupAndTags(t *testing.T) {
+	if got := normalizeGroup(" prod / /web/ "); got != "prod/web" {
+		t.Errorf("分组规范化为 %q", got)
+	}
+	if got := normalizeTags([]string{"b", " a ", "", "b"}); !slices.Equal(got, []string{"a", "b"}) {
+		t.Errorf("标签规范化为 %q", got)
+	}
+	if !inGroup("prod/web", "prod") || inGroup("production", "prod") || !inGroup("prod", "") {
+		t.Error("分组层级判断不正确")
+	}
+
+	m := Maintenance{Groups: []string{"prod"}}
+	if !m.covers("x", "prod/web") || m.covers("x", "dev") || m.covers("x", "") {
+		t.Error("按分组的维护窗口作用范围不正确")
+	}
+}
+
+func TestClientGroupsAndTags(t *testing.T) {
+	server, ts := newTestServer(t)
+	admin := loginClient(t, ts)
+	web := addClient(t, admin, ts, "web")
+	db := addClient(t, admin, ts, "db")
+	dev := addClient(t, admin, ts, "dev")
+
+	mustPost(t, admin, ts.URL+"/api/clients/group", map[string]any{"ids": []string{web, "missing"}, "group": "prod"}, http.StatusNotFound)
+	if c, _ := server.clients.Snapshot().Get(web); c.Group != "" {
+		t.Fatalf("部分客户端不存在时不应修改任何客户端: %+v", c)
+	}
+
+	mustPost(t, admin, ts.URL+"/api/clients/group", map[string]any{"ids": []string{web}, "group": "prod/web"}, http.StatusOK)
+	mustPost(t, admin, ts.URL+"/api/clients/group", map[string]any{"ids": []string{db}, "group": " prod/ db "}, http.StatusOK)
+	mustPost(t, admin, ts.URL+"/api/clients/group", map[string]any{"ids": []string{dev}, "group": "dev"}, http.StatusOK)
+	mustPost(t, admin, ts.URL+"/api/clients/tags", map[string]any{"ids": []string{web, db}, "add": []string{"team-a", "backup"}}, http.StatusOK)
+	mustPost(t, admin, ts.URL+"/api/clients/tags", map[string]any{"ids": []string{web}, "remove": []string{"backup"}}, http.StatusOK)
+	mustPost(t, admin, ts.URL+"/api/clients/tags", map[string]any{"ids": []string{web}, "add": []string{"a,b"}}, http.StatusBadRequest)
+
+	names := func(query string) []string {
+		var clients []Client
+		mustGet(t, admin, ts.URL+"/api/clients"+query, &clients)
+		var list []string
+		for _, c := range clients {
+			list = append(list, c.Name)
+		}
+		slices.Sort(list)
+		return list
+	}
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"db", "dev", "web"}},
+		{"?group=prod", []string{"db", "web"}},
+		{"?group=prod/web", []string{"web"}},
+		{"?group=pro", nil},
+		{"?tag=team-a", []string{"db", "web"}},
+		{"?tag=team-a&tag=backup", []string{"db"}},
+		{"?tag=team-a,backup", []string{"db"}},
+		{"?group=dev&tag=team-a", nil},
+	}
+	for _, tt := range tests {
+		if got := names(tt.query); !slices.Equal(got, tt.want) {
+			t.Errorf("%s 返回 %v，期望 %v", tt.query, got, tt.want)
+		}
+	}
+
+	// 汇总包含上级分组，只统计在线客户端的平均值
+	conn := dialAgent(t, ts, web)
+	defer conn.Close()
+	if err := conn.WriteJSON(Metrics{CPU: 40, Memory: 60}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "指标更新", func() bool {
+		c, _ := server.clients.Snapshot().Get(web)
+		return c.CPU == 40
+	})
+	var stats []GroupStats
+	mustGet(t, admin, ts.URL+"/api/groups", &stats)
+	paths := make([]string, len(stats))
+	for i, g := range stats {
+		paths[i] = g.Path
+	}
+	if !slices.Equal(paths, []string{"dev", "prod", "prod/db", "prod/web"}) {
+		t.Fatalf("分组为 %v", paths)
+	}
+	if prod := stats[1]; prod.Clients != 2 || prod.Online != 1 || prod.AvgCPU != 40 || prod.AvgMemory != 60 {
+		t.Fatalf("prod 分组汇总为 %+v", prod)
+	}
+	mustGet(t, admin, ts.URL+"/api/groups?tag=backup", &stats)
+	if len(stats) != 2 || stats[0].Path != "prod" || stats[0].Clients != 1 {
+		t.Fatalf("按标签筛选的分组汇总为 %+v", stats)
+	}
+
+	resp, err := http.Get(ts.URL + "/api/groups")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("匿名访问状态码为 %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,1481 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// handleIndex 处理主页请求
+func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	s.web.serve(w, r, "templates/index.html", "no-cache")
+}
+
+// handleLogin 处理登录请求
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var credentials struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&credentials); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 来源 IP 或用户名连续失败过多时直接拒绝，不检查密码；每次锁定只保存第一次被拒绝的记录，避免频繁写数据库
+	ip := s.clientIP(r)
+	keys := loginKeys(ip, credentials.Username)
+	if wait, first := s.logins.check(keys, time.Now()); wait > 0 {
+		if first {
+			s.recordAuth(r, ip, credentials.Username, authLocked)
+		}
+		tooManyAttempts(w, wait)
+		return
+	}
+
+	user, err := s.store.GetUser(credentials.Username)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		log.Printf("读取用户数据出错: %v", err)
+		http.Error(w, "服务器内部错误", http.StatusInternalServerError)
+		return
+	}
+
+	// 用户不存在时也计算一次哈希，响应时间不暴露用户是否存在
+	if !checkPassword(credentials.Password, user.PasswordHash) || err != nil {
+		s.recordAuth(r, ip, credentials.Username, authFailure)
+		if lockout := s.logins.fail(keys, time.Now()); lockout > 0 {
+			log.Printf("来自 %s 的用户 %s 登录失败次数过多，锁定 %v", ip, credentials.Username, lockout)
+		}
+		http.Error(w, "用户名或密码不正确", http.StatusUnauthorized)
+		return
+	}
+	s.logins.succeed(keys)
+	s.recordAuth(r, ip, user.Username, authSuccess)
+
+	// 生成随机会话令牌，数据库中只保存其哈希值
+	session := newToken()
+	now := time.Now()
+	if err := s.store.SaveSession(session, Session{
+		Username:  user.Username,
+		CreatedAt: now,
+		ExpiresAt: now.Add(sessionTTL),
+	}); err != nil {
+		log.Printf("保存会话出错: %v", err)
+		http.Error(w, "服务器内部错误", http.StatusInternalServerError)
+		return
+	}
+	cookie := &http.Cookie{
+		Name:     "session",
+		Value:    session,
+		Path:     "/",
+		HttpOnly: false,
+		MaxAge:   int(sessionTTL / time.Second), // 7天
+		SameSite: http.SameSiteLaxMode,
+	}
+	http.SetCookie(w, cookie)
+
+	// log.Printf("用户 %s 登录成功，设置会话: %s，过期时间：%d秒", credentials.Username, session, cookie.MaxAge)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// handleLogout 处理登出请求
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie("session"); err == nil && cookie.Value != "" {
+		if err := s.store.DeleteSession(cookie.Value); err != nil {
+			log.Printf("删除会话出错: %v", err)
+		}
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session",
+		Value:    "",
+		Path:     "/",
+		HttpOnly: true,
+		MaxAge:   -1,
+	})
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// handleChangePassword 处理修改密码请求
+func (s *Server) handleChangePassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		// log.Printf("修改密码失败: 方法不允许: %s", r.Method)
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 检查是否已登录
+	session, ok := s.currentSession(r)
+	if !ok {
+		// log.Printf("修改密码失败: 未授权访问")
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	// 解析请求
+	var credentials struct {
+		Username    string `json:"username"`
+		OldPassword string `json:"oldPassword"`
+		NewPassword string `json:"newPassword"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&credentials); err != nil {
+		// log.Printf("修改密码失败: 解析请求失败: %v", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"status": "error",
+			"error":  "无效的请求格式",
+		})
+		return
+	}
+
+	// log.Printf("收到修改密码请求: 用户名='%s'", credentials.Username)
+
+	user, err := s.store.GetUser(session.Username)
+	if err != nil {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	// 验证原密码
+	if !checkPassword(credentials.OldPassword, user.PasswordHash) {
+		// log.Printf("修改密码失败: 原密码不正确")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{
+			"status": "error",
+			"error":  "原密码不正确",
+		})
+		return
+	}
+
+	// 更新用户信息，修改用户名时旧会话会失效，需要为当前请求重新签发会话
+	oldUsername := user.Username
+	user.Username = credentials.Username
+	user.PasswordHash = hashPassword(credentials.NewPassword)
+	if err := s.store.RenameUser(oldUsername, user); err != nil {
+		// log.Printf("修改密码失败: 保存用户数据失败: %v", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"status": "error",
+			"error":  "保存用户数据失败",
+		})
+		return
+	}
+	if oldUsername != user.Username {
+		cookie, _ := r.Cookie("session")
+		session.Username = user.Username
+		if err := s.store.SaveSession(cookie.Value, session); err != nil {
+			log.Printf("保存会话出错: %v", err)
+		}
+	}
+
+	// log.Printf("密码修改成功: 用户=%s", credentials.Username)
+
+	// 返回成功响应
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "success",
+		"message": "设置已保存",
+	})
+}
+
+// handleGetClients 获取所有客户端信息
+// 参数 group 只返回该分组及其下级分组中的客户端，tag 和 label 只返回带有所有指定标签的客户端
+func (s *Server) handleGetClients(w http.ResponseWriter, r *http.Request) {
+	query, err := parseClientQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 快照中的客户端已按DisplayOrder排序，返回的是副本，可以直接修改
+	clientList := s.clients.Snapshot().List()
+
+	// 未登录时不返回任何客户端，公开的内容通过状态页提供
+	if !s.checkAuth(r) {
+		clientList = []Client{}
+	}
+
+	clientList = slices.DeleteFunc(clientList, func(c Client) bool {
+		return !query.matches(&c)
+	})
+	// 不返回客户端密钥的哈希值
+	for i := range clientList {
+		clientList[i].SecretHash = ""
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(clientList)
+}
+
+// handleAddClient 添加新客户端，已被 POST /api/v1/clients 取代
+func (s *Server) handleAddClient(w http.ResponseWriter, r *http.Request) {
+	deprecated(w, "/clients")
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 检查是否已登录
+	if !s.checkAuth(r) {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	var clientInfo struct {
+		Name string `json:"name"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&clientInfo); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	newClient, err := s.createClient(clientPatch{Name: &clientInfo.Name})
+	if err != nil {
+		writeTextError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "success",
+		"id":     newClient.ID,
+	})
+}
+
+// handleDeleteClient 删除客户端，已被 DELETE /api/v1/clients/{id} 取代
+func (s *Server) handleDeleteClient(w http.ResponseWriter, r *http.Request) {
+	deprecated(w, "/clients/{id}")
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 检查是否已登录
+	if !s.checkAuth(r) {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	var clientInfo struct {
+		ID string `json:"id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&clientInfo); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.clients.Delete(clientInfo.ID)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// handleReorderClients 重新排序客户端
+func (s *Server) handleReorderClients(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 检查用户是否已登录
+	if !s.checkAuth(r) {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	var orderInfo struct {
+		Orders map[string]int `json:"orders"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&orderInfo); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.clients.Reorder(orderInfo.Orders)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// handleRenameClient 重命名客户端，已被 PATCH /api/v1/clients/{id} 取代
+func (s *Server) handleRenameClient(w http.ResponseWriter, r *http.Request) {
+	deprecated(w, "/clients/{id}")
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 检查用户是否已登录
+	if !s.checkAuth(r) {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	var renameInfo struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&renameInfo); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 验证输入
+	if renameInfo.ID == "" {
+		http.Error(w, "客户端ID不能为空", http.StatusBadRequest)
+		return
+	}
+
+	// 更新客户端名称
+	if _, err := s.updateClient(renameInfo.ID, clientPatch{Name: &renameInfo.Name}); err != nil {
+		writeTextError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "success",
+	})
+}
+
+// handleSetClientGroup 批量设置客户端的分组，group 为空表示取消分组
+func (s *Server) handleSetClientGroup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.checkAuth(r) {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	var info struct {
+		IDs   []string `json:"ids"`
+		Group string   `json:"group"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&info); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(info.IDs) == 0 {
+		http.Error(w, "客户端ID不能为空", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.clients.SetGroup(info.IDs, normalizeGroup(info.Group)); err != nil {
+		http.Error(w, "客户端不存在", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// handleUpdateClientTags 批量为客户端添加和移除标签
+func (s *Server) handleUpdateClientTags(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.checkAuth(r) {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	var info struct {
+		IDs    []string `json:"ids"`
+		Add    []string `json:"add"`
+		Remove []string `json:"remove"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&info); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(info.IDs) == 0 {
+		http.Error(w, "客户端ID不能为空", http.StatusBadRequest)
+		return
+	}
+	add, remove := normalizeTags(info.Add), normalizeTags(info.Remove)
+	if err := validateTags(add); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.clients.UpdateTags(info.IDs, add, remove); err != nil {
+		http.Error(w, "客户端不存在", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// handleGetGroups 返回每个分组的客户端数量、在线数量和平均使用率，参数 tag 和 label 与 /api/clients 相同
+func (s *Server) handleGetGroups(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAuth(r) {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	query, err := parseClientQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query.group = ""
+	clientList := slices.DeleteFunc(s.clients.Snapshot().List(), func(c Client) bool {
+		return !query.matches(&c)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(groupStats(clientList))
+}
+
+// handleExportClients 导出客户端，format 为 json（默认，与 clients.json 格式相同）或 csv，支持与 /api/clients 相同的筛选参数
+func (s *Server) handleExportClients(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAuth(r) {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	query, err := parseClientQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 密钥的哈希只导出给已登录的用户和有 admin 权限的令牌
+	withSecrets := s.authorizeAdmin(r) == nil
+	var records []ClientRecord
+	for _, c := range s.clients.Snapshot().List() {
+		if query.matches(&c) {
+			record := newClientRecord(&c)
+			if !withSecrets {
+				record.SecretHash = ""
+			}
+			records = append(records, record)
+		}
+	}
+
+	var write func(io.Writer, []ClientRecord) error
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="gonitor-clients.json"`)
+		write = writeClientsJSON
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="gonitor-clients.csv"`)
+		write = writeClientsCSV
+	default:
+		http.Error(w, "不支持的格式", http.StatusBadRequest)
+		return
+	}
+	if err := write(w, records); err != nil {
+		log.Printf("导出客户端出错: %v", err)
+	}
+}
+
+// handleImportClients 导入客户端，请求体为导出的文件内容，format 为 json（默认）或 csv
+// dryRun=1 时只返回将要进行的修改，不做任何修改
+func (s *Server) handleImportClients(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.checkAuth(r) {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxImportSize)
+	var records []ClientRecord
+	var err error
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "json":
+		records, err = readClientsJSON(body)
+	case "csv":
+		records, err = readClientsCSV(body)
+	default:
+		http.Error(w, "不支持的格式", http.StatusBadRequest)
+		return
+	}
+	if err == nil {
+		err = normalizeImport(records)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 持有密钥的哈希即可冒充自助注册的客户端，与导出相同，只有已登录的用户和有 admin 权限的令牌可以导入
+	if slices.ContainsFunc(records, func(rec ClientRecord) bool { return rec.SecretHash != "" }) && s.authorizeAdmin(r) != nil {
+		http.Error(w, "导入客户端密钥的哈希需要 admin 权限的令牌", http.StatusForbidden)
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+	result, err := s.clients.Import(records, dryRun)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !dryRun {
+		log.Printf("导入客户端：新建 %d 个，修改 %d 个", result.Created, result.Updated)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// handleSetClientPublic 设置客户端是否公开徽章，已被 PATCH /api/v1/clients/{id} 取代
+func (s *Server) handleSetClientPublic(w http.ResponseWriter, r *http.Request) {
+	deprecated(w, "/clients/{id}")
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.checkAuth(r) {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	var info struct {
+		ID     string `json:"id"`
+		Public bool   `json:"public"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&info); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c, err := s.updateClient(info.ID, clientPatch{Public: &info.Public})
+	if err != nil {
+		writeTextError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "success",
+		"badgeId": c.BadgeID,
+	})
+}
+
+// handleBadge 返回客户端的 SVG 徽章，路径为 /badge/<徽章标识>.svg
+// 参数 metric 为 status（默认）、cpu、memory、disk 或 uptime，uptime 可以用 period 指定 24h、7d 或 30d（默认）
+func (s *Server) handleBadge(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/badge/")
+	badgeID, ok := strings.CutSuffix(name, ".svg")
+	if !ok || strings.Contains(badgeID, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	c, ok := s.clients.Snapshot().ByBadgeID(badgeID)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	label, value, color, err := s.badgeContent(c, query.Get("metric"), query.Get("period"), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if v := query.Get("label"); v != "" {
+		label = v
+	}
+	svg := renderBadge(label, value, color)
+
+	h := w.Header()
+	h.Set("Content-Type", "image/svg+xml; charset=utf-8")
+	h.Set("Cache-Control", fmt.Sprintf("public, max-age=%d, s-maxage=%d", int(badgeMaxAge/time.Second), int(badgeMaxAge/time.Second)))
+	// SVG 可以包含脚本，禁止执行任何内容
+	h.Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	h.Set("X-Content-Type-Options", "nosniff")
+	etag := badgeETag(svg)
+	h.Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Write(svg)
+}
+
+// handleClientSeries 返回客户端有历史数据的所有指标
+func (s *Server) handleClientSeries(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAuth(r) {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	clientID := r.URL.Query().Get("id")
+	if _, ok := s.clients.Snapshot().Get(clientID); !ok {
+		http.Error(w, "客户端不存在", http.StatusNotFound)
+		return
+	}
+
+	series, err := s.store.ListMetricSeries(clientID)
+	if err != nil {
+		log.Printf("读取历史指标出错: %v", err)
+		http.Error(w, "服务器内部错误", http.StatusInternalServerError)
+		return
+	}
+	if series == nil {
+		series = []string{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(series)
+}
+
+// handleClientHistory 返回客户端某个指标的历史数据
+// 参数 range 为时间范围，如 1h、24h，默认 1h，最长为历史数据的保留时间
+func (s *Server) handleClientHistory(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAuth(r) {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	clientID := query.Get("id")
+	if _, ok := s.clients.Snapshot().Get(clientID); !ok {
+		http.Error(w, "客户端不存在", http.StatusNotFound)
+		return
+	}
+	metric := query.Get("metric")
+	if metric == "" {
+		http.Error(w, "缺少指标名称", http.StatusBadRequest)
+		return
+	}
+	span := time.Hour
+	if v := query.Get("range"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			http.Error(w, "无效的时间范围", http.StatusBadRequest)
+			return
+		}
+		span = min(d, historyRetention)
+	}
+
+	points, err := s.store.ListMetricPoints(clientID, metric, time.Now().Add(-span))
+	if err != nil {
+		log.Printf("读取历史指标出错: %v", err)
+		http.Error(w, "服务器内部错误", http.StatusInternalServerError)
+		return
+	}
+	if points == nil {
+		points = []MetricPoint{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(points)
+}
+
+// handleClientChecks 返回客户端执行的每个探测的最新结果和可用率
+func (s *Server) handleClientChecks(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAuth(r) {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	clientID := r.URL.Query().Get("id")
+	client, ok := s.clients.Snapshot().Get(clientID)
+	if !ok {
+		http.Error(w, "客户端不存在", http.StatusNotFound)
+		return
+	}
+
+	now := time.Now()
+	statuses := make([]CheckStatus, 0, len(client.Checks))
+	for _, latest := range client.Checks {
+		status, err := s.checkStatus(clientCheckID(clientID, latest.Name), latest, now)
+		if err != nil {
+			log.Print(err)
+			http.Error(w, "服务器内部错误", http.StatusInternalServerError)
+			return
+		}
+		statuses = append(statuses, status)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statuses)
+}
+
+// handleCheckResults 返回某个探测的历史结果
+// 参数 range 为时间范围，如 1h、24h，默认 24h，最长为历史数据的保留时间
+func (s *Server) handleCheckResults(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAuth(r) {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	checkID := query.Get("id")
+	if checkID == "" {
+		http.Error(w, "缺少探测ID", http.StatusBadRequest)
+		return
+	}
+	span := 24 * time.Hour
+	if v := query.Get("range"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			http.Error(w, "无效的时间范围", http.StatusBadRequest)
+			return
+		}
+		span = min(d, historyRetention)
+	}
+
+	results, err := s.store.ListCheckResults(checkID, time.Now().Add(-span))
+	if err != nil {
+		log.Printf("读取探测结果出错: %v", err)
+		http.Error(w, "服务器内部错误", http.StatusInternalServerError)
+		return
+	}
+	if results == nil {
+		results = []CheckResult{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// handleGetMonitors 获取所有服务端探测及其最新结果
+func (s *Server) handleGetMonitors(w http.ResponseWriter, r *http.Request) {
+	monitors := s.monitors.List()
+
+	// 与客户端一样，未登录时不返回任何内容
+	if !s.checkAuth(r) {
+		monitors = []MonitorStatus{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(monitors)
+}
+
+// handleSaveMonitor 添加或修改服务端探测，ID 为空时添加
+// 已被 POST /api/v1/monitors 和 PUT /api/v1/monitors/{id} 取代
+func (s *Server) handleSaveMonitor(w http.ResponseWriter, r *http.Request) {
+	deprecated(w, "/monitors")
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.checkAuth(r) {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	var monitor Monitor
+	if err := json.NewDecoder(r.Body).Decode(&monitor); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	saved, err := s.saveMonitor(monitor)
+	if err != nil {
+		writeTextError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "success",
+		"id":     saved.ID,
+	})
+}
+
+// handleDeleteMonitor 删除服务端探测及其探测结果，已被 DELETE /api/v1/monitors/{id} 取代
+func (s *Server) handleDeleteMonitor(w http.ResponseWriter, r *http.Request) {
+	deprecated(w, "/monitors/{id}")
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.checkAuth(r) {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	var info struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&info); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.monitors.Remove(info.ID)
+	if err := s.store.DeleteMonitor(info.ID); err != nil {
+		log.Printf("删除服务端探测出错: %v", err)
+		http.Error(w, "服务器内部错误", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// handleMonitorStatus 返回服务端探测的最新结果和可用率
+func (s *Server) handleMonitorStatus(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAuth(r) {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	monitor, ok := s.monitors.Get(r.URL.Query().Get("id"))
+	if !ok {
+		http.Error(w, "服务端探测不存在", http.StatusNotFound)
+		return
+	}
+	latest := CheckResult{Name: monitor.Name, Type: monitor.Type, Target: monitor.Target}
+	if monitor.Latest != nil {
+		latest = *monitor.Latest
+	}
+	status, err := s.checkStatus(monitor.ID, latest, time.Now())
+	if err != nil {
+		log.Print(err)
+		http.Error(w, "服务器内部错误", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// handleGetAlertRules 返回所有告警规则，已被 GET /api/v1/alert-rules 取代
+func (s *Server) handleGetAlertRules(w http.ResponseWriter, r *http.Request) {
+	deprecated(w, "/alert-rules")
+	if !s.checkAuth(r) {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	rules, err := s.listAlertRules()
+	if err != nil {
+		writeTextError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rules)
+}
+
+// handleSaveAlertRule 添加或修改告警规则，ID 为空时添加
+// 已被 POST /api/v1/alert-rules 和 PUT /api/v1/alert-rules/{id} 取代
+func (s *Server) handleSaveAlertRule(w http.ResponseWriter, r *http.Request) {
+	deprecated(w, "/alert-rules")
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.checkAuth(r) {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	var rule AlertRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rule, err := s.saveAlertRule(rule)
+	if err != nil {
+		writeTextError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "success",
+		"id":     rule.ID,
+	})
+}
+
+// handleDeleteAlertRule 删除告警规则，已被 DELETE /api/v1/alert-rules/{id} 取代
+func (s *Server) handleDeleteAlertRule(w http.ResponseWriter, r *http.Request) {
+	deprecated(w, "/alert-rules/{id}")
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.checkAuth(r) {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	var info struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&info); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.DeleteAlertRule(info.ID); err != nil {
+		log.Printf("删除告警规则出错: %v", err)
+		http.Error(w, "服务器内部错误", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// handleGetAlertEvents 按时间倒序返回最近的告警事件，参数 limit 默认 100
+func (s *Server) handleGetAlertEvents(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAuth(r) {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "无效的数量", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	events, err := s.store.ListAlertEvents(limit)
+	if err != nil {
+		log.Printf("读取告警事件出错: %v", err)
+		http.Error(w, "服务器内部错误", http.StatusInternalServerError)
+		return
+	}
+	if events == nil {
+		events = []AlertEvent{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
+
+// handleGetMaintenances 返回所有维护窗口及其当前是否生效
+func (s *Server) handleGetMaintenances(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAuth(r) {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	maintenances, err := s.store.ListMaintenances()
+	if err != nil {
+		log.Printf("读取维护窗口出错: %v", err)
+		http.Error(w, "服务器内部错误", http.StatusInternalServerError)
+		return
+	}
+	sort.Slice(maintenances, func(i, j int) bool { return maintenances[i].CreatedAt.Before(maintenances[j].CreatedAt) })
+
+	now := time.Now()
+	list := make([]MaintenanceStatus, 0, len(maintenances))
+	for _, m := range maintenances {
+		list = append(list, MaintenanceStatus{Maintenance: m, Active: m.active(now)})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// handleSaveMaintenance 添加或修改维护窗口，ID 为空时添加
+func (s *Server) handleSaveMaintenance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.checkAuth(r) {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	var m Maintenance
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := normalizeMaintenance(&m); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if m.ID == "" {
+		m.ID = newID()
+		m.CreatedAt = time.Now()
+	} else {
+		maintenances, err := s.store.ListMaintenances()
+		if err != nil {
+			log.Printf("读取维护窗口出错: %v", err)
+			http.Error(w, "服务器内部错误", http.StatusInternalServerError)
+			return
+		}
+		i := slices.IndexFunc(maintenances, func(old Maintenance) bool { return old.ID == m.ID })
+		if i < 0 {
+			http.Error(w, "维护窗口不存在", http.StatusNotFound)
+			return
+		}
+		m.CreatedAt = maintenances[i].CreatedAt
+	}
+
+	if err := s.store.SaveMaintenance(m); err != nil {
+		log.Printf("保存维护窗口出错: %v", err)
+		http.Error(w, "服务器内部错误", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "success",
+		"id":     m.ID,
+	})
+}
+
+// handleDeleteMaintenance 删除维护窗口
+func (s *Server) handleDeleteMaintenance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.checkAuth(r) {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	var info struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&info); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.DeleteMaintenance(info.ID); err != nil {
+		log.Printf("删除维护窗口出错: %v", err)
+		http.Error(w, "服务器内部错误", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// handleGetIncidents 按创建时间倒序返回所有故障事件
+func (s *Server) handleGetIncidents(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAuth(r) {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	incidents, err := s.store.ListIncidents()
+	if err != nil {
+		log.Printf("读取故障事件出错: %v", err)
+		http.Error(w, "服务器内部错误", http.StatusInternalServerError)
+		return
+	}
+	if incidents == nil {
+		incidents = []Incident{}
+	}
+	sort.Slice(incidents, func(i, j int) bool { return incidents[i].CreatedAt.After(incidents[j].CreatedAt) })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(incidents)
+}
+
+// handleCreateIncident 创建故障事件，同时记录第一条进展
+func (s *Server) handleCreateIncident(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.checkAuth(r) {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	var info struct {
+		Title   string `json:"title"`
+		Status  string `json:"status"`
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&info); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if info.Title == "" {
+		http.Error(w, "标题不能为空", http.StatusBadRequest)
+		return
+	}
+	if info.Status == "" {
+		info.Status = "investigating"
+	}
+
+	now := time.Now()
+	incident := Incident{ID: newID(), Title: info.Title, CreatedAt: now}
+	if err := addIncidentUpdate(&incident, info.Status, info.Message, now); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.SaveIncident(incident); err != nil {
+		log.Printf("保存故障事件出错: %v", err)
+		http.Error(w, "服务器内部错误", http.StatusInternalServerError)
+		return
+	}
+	s.statusCache.reset()
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "success",
+		"id":     incident.ID,
+	})
+}
+
+// handleUpdateIncident 为故障事件添加一条进展，状态为 resolved 时事件结束
+func (s *Server) handleUpdateIncident(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.checkAuth(r) {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	var info struct {
+		ID      string `json:"id"`
+		Status  string `json:"status"`
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&info); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	incident, err := s.store.GetIncident(info.ID)
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, "故障事件不存在", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("读取故障事件出错: %v", err)
+		http.Error(w, "服务器内部错误", http.StatusInternalServerError)
+		return
+	}
+	if err := addIncidentUpdate(&incident, info.Status, info.Message, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.SaveIncident(incident); err != nil {
+		log.Printf("保存故障事件出错: %v", err)
+		http.Error(w, "服务器内部错误", http.StatusInternalServerError)
+		return
+	}
+	s.statusCache.reset()
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// handleDeleteIncident 删除故障事件
+func (s *Server) handleDeleteIncident(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.checkAuth(r) {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	var info struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&info); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.DeleteIncident(info.ID); err != nil {
+		log.Printf("删除故障事件出错: %v", err)
+		http.Error(w, "服务器内部错误", http.StatusInternalServerError)
+		return
+	}
+	s.statusCache.reset()
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// handleGetStatusPage 返回状态页配置
+func (s *Server) handleGetStatusPage(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAuth(r) {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	page, err := s.store.GetStatusPage()
+	if err != nil {
+		log.Printf("读取状态页配置出错: %v", err)
+		http.Error(w, "服务器内部错误", http.StatusInternalServerError)
+		return
+	}
+	if page.Items == nil {
+		page.Items = []StatusPageItem{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// handleSaveStatusPage 保存状态页配置
+func (s *Server) handleSaveStatusPage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.checkAuth(r) {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	var page StatusPage
+	if err := json.NewDecoder(r.Body).Decode(&page); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := normalizeStatusPage(&page); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.SaveStatusPage(page); err != nil {
+		log.Printf("保存状态页配置出错: %v", err)
+		http.Error(w, "服务器内部错误", http.StatusInternalServerError)
+		return
+	}
+	s.statusCache.reset()
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// handleStatusPage 处理公开状态页请求
+func (s *Server) handleStatusPage(w http.ResponseWriter, r *http.Request) {
+	s.web.serve(w, r, "templates/status.html", "no-cache")
+}
+
+// handleStatusData 返回公开状态页的数据，状态页未启用时返回 404
+func (s *Server) handleStatusData(w http.ResponseWriter, r *http.Request) {
+	status, err := s.publicStatus(time.Now())
+	if err != nil {
+		log.Print(err)
+		http.Error(w, "服务器内部错误", http.StatusInternalServerError)
+		return
+	}
+	if status == nil {
+		http.Error(w, "状态页未启用", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(statusPageTTL/time.Second)))
+	json.NewEncoder(w).Encode(status)
+}
+
+// handleGetEnrollTokens 按创建时间倒序返回所有注册令牌，不包含令牌原文
+func (s *Server) handleGetEnrollTokens(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAuth(r) {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	tokens, err := s.store.ListEnrollTokens()
+	if err != nil {
+		log.Printf("读取注册令牌出错: %v", err)
+		http.Error(w, "服务器内部错误", http.StatusInternalServerError)
+		return
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].CreatedAt.After(tokens[j].CreatedAt) })
+
+	now := time.Now()
+	statuses := make([]EnrollTokenStatus, len(tokens))
+	for i, t := range tokens {
+		statuses[i] = EnrollTokenStatus{EnrollToken: t, Valid: t.usable(now) == nil}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statuses)
+}
+
+// handleCreateEnrollToken 创建注册令牌，令牌原文只在创建时返回一次
+func (s *Server) handleCreateEnrollToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.checkAuth(r) {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	var info struct {
+		Name      string    `json:"name"`
+		Group     string    `json:"group"`
+		MaxUses   int       `json:"maxUses"`
+		ExpiresAt time.Time `json:"expiresAt"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&info); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	switch {
+	case strings.TrimSpace(info.Name) == "":
+		http.Error(w, "名称不能为空", http.StatusBadRequest)
+		return
+	case info.MaxUses < 0:
+		http.Error(w, "使用次数不能为负数", http.StatusBadRequest)
+		return
+	case !info.ExpiresAt.IsZero() && !info.ExpiresAt.After(now):
+		http.Error(w, "过期时间必须晚于当前时间", http.StatusBadRequest)
+		return
+	}
+
+	token := newToken()
+	t := EnrollToken{
+		ID:        newID(),
+		Name:      strings.TrimSpace(info.Name),
+		Group:     normalizeGroup(info.Group),
+		MaxUses:   info.MaxUses,
+		ExpiresAt: info.ExpiresAt,
+		CreatedAt: now,
+	}
+	if err := s.store.SaveEnrollToken(token, t); err != nil {
+		log.Printf("保存注册令牌出错: %v", err)
+		http.Error(w, "服务器内部错误", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "success",
+		"id":     t.ID,
+		"token":  token,
+	})
+}
+
+// handleDeleteEnrollToken 删除注册令牌，已注册的客户端不受影响
+func (s *Server) handleDeleteEnrollToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.checkAuth(r) {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	var info struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&info); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := s.store.DeleteEnrollToken(info.ID)
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, "注册令牌不存在", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("删除注册令牌出错: %v", err)
+		http.Error(w, "服务器内部错误", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// handleEnroll 客户端使用注册令牌自助注册，返回分配的客户端ID和密钥，不需要登录
+func (s *Server) handleEnroll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var info struct {
+		Token string `json:"token"`
+		Name  string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&info); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	t, err := s.store.UseEnrollToken(info.Token, time.Now())
+	switch {
+	case errors.Is(err, ErrNotFound):
+		http.Error(w, "注册令牌无效", http.StatusForbidden)
+		return
+	case errors.Is(err, errEnrollTokenExpired), errors.Is(err, errEnrollTokenUsedUp):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case err != nil:
+		log.Printf("使用注册令牌出错: %v", err)
+		http.Error(w, "服务器内部错误", http.StatusInternalServerError)
+		return
+	}
+
+	secret := newToken()
+	client := s.clients.AddEnrolled(normalizeClientName(info.Name), t.Group, hashSecret(secret), t.Name)
+	log.Printf("客户端 %s (%s) 使用注册令牌 %s 完成注册", client.Name, client.ID, t.Name)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "success",
+		"id":     client.ID,
+		"secret": secret,
+	})
+}
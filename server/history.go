@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// historyResolution 历史指标的时间精度，同一分钟内上报的值取平均
+	historyResolution = time.Minute
+	// historyRetention 历史指标的保留时间
+	historyRetention = 7 * 24 * time.Hour
+)
+
+// average 累计同一分钟内的指标值
+type average struct {
+	sum float64
+	n   int
+}
+
+// metricHistory 将客户端上报的指标按分钟取平均后写入存储，用于绘制历史曲线
+type metricHistory struct {
+	store Store
+
+	mu      sync.Mutex
+	minute  time.Time                      // 正在累计的分钟
+	pending map[string]map[string]*average // 客户端ID -> 指标 -> 累计值
+}
+
+// newMetricHistory 创建历史指标记录器
+func newMetricHistory(store Store) *metricHistory {
+	return &metricHistory{
+		store:   store,
+		pending: make(map[string]map[string]*average),
+	}
+}
+
+// Record 记录客户端在 now 时刻上报的一帧指标
+func (h *metricHistory) Record(clientID string, m Metrics, now time.Time) {
+	minute := now.Truncate(historyResolution)
+
+	h.mu.Lock()
+	// 进入新的一分钟时先取出上一分钟的数据，在锁外写入存储
+	var done map[string]map[string]*average
+	var doneMinute time.Time
+	if minute.After(h.minute) {
+		done, doneMinute = h.swap(minute)
+	}
+	series := h.pending[clientID]
+	if series == nil {
+		series = make(map[string]*average)
+		h.pending[clientID] = series
+	}
+	for name, v := range m.values() {
+		a := series[name]
+		if a == nil {
+			a = &average{}
+			series[name] = a
+		}
+		a.sum += v
+		a.n++
+	}
+	h.mu.Unlock()
+
+	h.save(doneMinute, done)
+}
+
+// Flush 保存已经结束的分钟的数据；all 为 true 时连同当前分钟一起保存
+func (h *metricHistory) Flush(now time.Time, all bool) {
+	h.mu.Lock()
+	var done map[string]map[string]*average
+	var doneMinute time.Time
+	if all || now.Truncate(historyResolution).After(h.minute) {
+		done, doneMinute = h.swap(now.Truncate(historyResolution))
+	}
+	h.mu.Unlock()
+
+	h.save(doneMinute, done)
+}
+
+// swap 开始累计新的一分钟并返回之前累计的数据，调用方需持有锁
+func (h *metricHistory) swap(minute time.Time) (map[string]map[string]*average, time.Time) {
+	done, doneMinute := h.pending, h.minute
+	h.pending = make(map[string]map[string]*average)
+	h.minute = minute
+	return done, doneMinute
+}
+
+// save 将累计的数据取平均后写入存储
+func (h *metricHistory) save(minute time.Time, pending map[string]map[string]*average) {
+	if len(pending) == 0 {
+		return
+	}
+	points := make(map[string]map[string]float64, len(pending))
+	for clientID, series := range pending {
+		values := make(map[string]float64, len(series))
+		for name, a := range series {
+			values[name] = a.sum / float64(a.n)
+		}
+		points[clientID] = values
+	}
+	if err := h.store.SaveMetricPoints(minute, points); err != nil {
+		log.Printf("保存历史指标出错: %v", err)
+	}
+}
+
+// maintainHistory 定期保存已结束的分钟的指标和客户端在线记录，并删除超过保留时间的历史指标和探测结果
+func (s *Server) maintainHistory(ctx context.Context) {
+	ticker := time.NewTicker(historyResolution)
+	defer ticker.Stop()
+	lastPurge := time.Time{}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			// 没有客户端上报时也要及时保存上一分钟的数据
+			s.history.Flush(now, false)
+			s.recordClientUptime(now)
+			if now.Sub(lastPurge) >= time.Hour {
+				if _, err := s.store.DeleteMetricsBefore(now.Add(-historyRetention)); err != nil {
+					log.Printf("清理历史指标出错: %v", err)
+				}
+				if _, err := s.store.DeleteCheckResultsBefore(now.Add(-historyRetention)); err != nil {
+					log.Printf("清理探测结果出错: %v", err)
+				}
+				lastPurge = now
+			}
+		}
+	}
+}
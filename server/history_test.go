@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestMetricHistoryAveragesPerMinute(t *testing.T) {
+	server, ts := newTestServer(t)
+	admin := loginClient(t, ts)
+	id := addClient(t, admin, ts, "a")
+
+	// 两分钟之前的数据，第一分钟两帧取平均
+	base := time.Now().Truncate(historyResolution).Add(-2 * historyResolution)
+	samples := []Sample{{Name: "queue_depth", Labels: map[string]string{"queue": "mail"}, Value: 10}}
+	server.history.Record(id, Metrics{CPU: 10, Samples: samples}, base)
+	server.history.Record(id, Metrics{CPU: 30, Samples: samples}, base.Add(30*time.Second))
+	server.history.Record(id, Metrics{CPU: 50}, base.Add(historyResolution))
+	server.history.Flush(time.Now(), false)
+
+	var series []string
+	mustGet(t, admin, ts.URL+"/api/clients/series?id="+id, &series)
+	want := map[string]bool{"cpu": true, `queue_depth{queue="mail"}`: true}
+	for name := range want {
+		found := false
+		for _, s := range series {
+			found = found || s == name
+		}
+		if !found {
+			t.Fatalf("指标列表 %v 中缺少 %s", series, name)
+		}
+	}
+
+	var points []MetricPoint
+	mustGet(t, admin, ts.URL+"/api/clients/history?id="+id+"&metric=cpu&range=1h", &points)
+	if len(points) != 2 || points[0].Value != 20 || points[1].Value != 50 {
+		t.Fatalf("历史数据为 %+v，期望 20 和 50 两个点", points)
+	}
+	if !points[0].Time.Equal(base) {
+		t.Fatalf("数据点时间为 %v，期望 %v", points[0].Time, base)
+	}
+
+	// 未登录不能读取历史数据
+	resp, err := http.Get(ts.URL + "/api/clients/history?id=" + id + "&metric=cpu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("未登录请求返回 %s", resp.Status)
+	}
+
+	// 删除客户端时历史数据一并删除
+	mustPost(t, admin, ts.URL+"/api/clients/delete", map[string]string{"id": id}, http.StatusOK)
+	if series, _ := server.store.ListMetricSeries(id); len(series) != 0 {
+		t.Fatalf("删除客户端后仍有历史指标 %v", series)
+	}
+}
+
+func TestDeleteMetricsBefore(t *testing.T) {
+	server, _ := newTestServer(t)
+	st := server.store
+
+	base := time.Unix(1700000000, 0)
+	for i := 0; i < 10; i++ {
+		points := map[string]map[string]float64{"a": {"cpu": float64(i), "memory": float64(i)}}
+		if err := st.SaveMetricPoints(base.Add(time.Duration(i)*time.Minute), points); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := st.DeleteMetricsBefore(base.Add(5 * time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 10 {
+		t.Fatalf("删除了 %d 个数据点，期望 10", n)
+	}
+	points, err := st.ListMetricPoints("a", "cpu", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 5 || points[0].Value != 5 {
+		t.Fatalf("剩余数据点 %+v", points)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// labelsHeader 客户端在 WebSocket 握手请求中声明标签使用的请求头，值为 URL 查询字符串格式，如 env=prod&region=eu
+const labelsHeader = "X-Gonitor-Labels"
+
+const (
+	maxAgentLabels = 32  // 客户端最多声明的标签数量
+	maxLabelValue  = 128 // 标签值的最大长度
+)
+
+// labelNameRe 标签名称的格式，与 Prometheus 一致
+var labelNameRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// reservedLabels 由服务端生成的标签名称，客户端和管理员都不能设置
+var reservedLabels = map[string]bool{"id": true, "name": true, "group": true}
+
+// validLabelName 判断标签名称是否可以由客户端或管理员设置
+func validLabelName(name string) bool {
+	return labelNameRe.MatchString(name) && !reservedLabels[name]
+}
+
+// parseAgentLabels 解析客户端声明的标签，忽略无效的标签并返回原因
+func parseAgentLabels(header string) (map[string]string, error) {
+	if header == "" {
+		return nil, nil
+	}
+	values, err := url.ParseQuery(header)
+	if err != nil {
+		return nil, fmt.Errorf("标签格式无效: %w", err)
+	}
+	labels := make(map[string]string, len(values))
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(values)) {
+		value := values[name][len(values[name])-1]
+		switch {
+		case !validLabelName(name):
+			errs = append(errs, fmt.Errorf("标签名称 %q 无效或为保留名称", name))
+		case value == "" || len(value) > maxLabelValue:
+			errs = append(errs, fmt.Errorf("标签 %s 的值为空或超过 %d 字节", name, maxLabelValue))
+		case len(labels) >= maxAgentLabels:
+			errs = append(errs, fmt.Errorf("标签超过 %d 个，忽略 %s", maxAgentLabels, name))
+		default:
+			labels[name] = value
+		}
+	}
+	if len(labels) == 0 {
+		labels = nil
+	}
+	return labels, errors.Join(errs...)
+}
+
+// tagLabel 将管理员设置的 key=value 形式的标签解析为标签名称和值
+func tagLabel(tag string) (name, value string, ok bool) {
+	name, value, ok = strings.Cut(tag, "=")
+	if !ok || value == "" || !validLabelName(name) {
+		return "", "", false
+	}
+	return name, value, true
+}
+
+// allLabels 返回客户端的全部标签：客户端声明的标签，被管理员设置的 key=value 同名标签覆盖
+func (c *Client) allLabels() map[string]string {
+	labels := maps.Clone(c.Labels)
+	for _, tag := range c.Tags {
+		if name, value, ok := tagLabel(tag); ok {
+			if labels == nil {
+				labels = make(map[string]string)
+			}
+			labels[name] = value
+		}
+	}
+	return labels
+}
+
+// labelsMatch 判断 labels 是否包含 selector 中的所有标签
+func labelsMatch(labels, selector map[string]string) bool {
+	for name, value := range selector {
+		if v, ok := labels[name]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// validateLabelSelector 检查标签选择器中的名称是否有效
+func validateLabelSelector(selector map[string]string) error {
+	for name := range selector {
+		if !validLabelName(name) {
+			return fmt.Errorf("标签名称 %q 无效", name)
+		}
+	}
+	return nil
+}
+
+// clientQuery 表示客户端的筛选条件
+type clientQuery struct {
+	group  string
+	tags   []string
+	labels map[string]string
+}
+
+// parseClientQuery 读取请求中的筛选参数：group 为分组，tag 和 label 可以重复或以逗号分隔，label 的格式为 key=value
+func parseClientQuery(r *http.Request) (clientQuery, error) {
+	query := r.URL.Query()
+	q := clientQuery{group: normalizeGroup(query.Get("group"))}
+	var tags []string
+	for _, v := range query["tag"] {
+		tags = append(tags, strings.Split(v, ",")...)
+	}
+	q.tags = normalizeTags(tags)
+	for _, v := range query["label"] {
+		for _, item := range strings.Split(v, ",") {
+			name, value, ok := strings.Cut(item, "=")
+			if !ok || !labelNameRe.MatchString(name) {
+				return clientQuery{}, fmt.Errorf("标签筛选 %q 无效，格式应为 key=value", item)
+			}
+			if q.labels == nil {
+				q.labels = make(map[string]string)
+			}
+			q.labels[name] = value
+		}
+	}
+	return q, nil
+}
+
+// matches 判断客户端是否满足筛选条件
+func (q clientQuery) matches(c *Client) bool {
+	if q.group != "" && !inGroup(c.Group, q.group) {
+		return false
+	}
+	for _, tag := range q.tags {
+		if !slices.Contains(c.Tags, tag) {
+			return false
+		}
+	}
+	return len(q.labels) == 0 || labelsMatch(c.allLabels(), q.labels)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"io"
+	"maps"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestParseAgentLabels(t *testing.T) {
+	labels, err := parseAgentLabels("env=prod&region=eu&id=x&bad-name=1&empty=")
+	if want := map[string]string{"env": "prod", "region": "eu"}; !maps.Equal(labels, want) {
+		t.Fatalf("标签为 %v", labels)
+	}
+	if err == nil {
+		t.Fatal("无效的标签应返回错误")
+	}
+	if labels, err := parseAgentLabels(""); labels != nil || err != nil {
+		t.Fatalf("没有标签时返回 %v, %v", labels, err)
+	}
+}
+
+func TestLabelPrecedence(t *testing.T) {
+	c := Client{
+		Labels: map[string]string{"env": "staging", "role": "web"},
+		Tags:   []string{"env=prod", "backup", "name=x", "team="},
+	}
+	want := map[string]string{"env": "prod", "role": "web"}
+	if got := c.allLabels(); !maps.Equal(got, want) {
+		t.Fatalf("合并后的标签为 %v，期望 %v", got, want)
+	}
+	if c.Labels["env"] != "staging" {
+		t.Fatal("合并标签不应修改客户端声明的标签")
+	}
+}
+
+// dialAgentWithLabels 模拟声明了标签的客户端连接服务器
+func dialAgentWithLabels(t *testing.T, url, id, labels string) *websocket.Conn {
+	t.Helper()
+	header := http.Header{}
+	header.Set(labelsHeader, labels)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(url, "http")+"/ws?id="+id, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestAgentLabels(t *testing.T) {
+	server, ts := newTestServer(t)
+	admin := loginClient(t, ts)
+	web := addClient(t, admin, ts, "web")
+	db := addClient(t, admin, ts, "db")
+
+	conn := dialAgentWithLabels(t, ts.URL, web, "env=staging&role=web")
+	dialAgentWithLabels(t, ts.URL, db, "env=prod&role=db")
+	waitFor(t, "客户端上线", func() bool {
+		a, _ := server.clients.Snapshot().Get(web)
+		b, _ := server.clients.Snapshot().Get(db)
+		return a.Connected && b.Connected
+	})
+	if err := conn.WriteJSON(Metrics{CPU: 95}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "指标更新", func() bool {
+		c, _ := server.clients.Snapshot().Get(web)
+		return c.CPU == 95
+	})
+
+	// 管理员设置的 key=value 标签优先
+	mustPost(t, admin, ts.URL+"/api/clients/tags", map[string]any{"ids": []string{web}, "add": []string{"env=prod"}}, http.StatusOK)
+
+	var clients []Client
+	mustGet(t, admin, ts.URL+"/api/clients?label=env=prod", &clients)
+	if len(clients) != 2 {
+		t.Fatalf("env=prod 的客户端为 %+v", clients)
+	}
+	mustGet(t, admin, ts.URL+"/api/clients?label=env=prod,role=web", &clients)
+	if len(clients) != 1 || clients[0].ID != web || clients[0].Labels["env"] != "staging" {
+		t.Fatalf("env=prod,role=web 的客户端为 %+v", clients)
+	}
+	resp, err := admin.Get(ts.URL + "/api/clients?label=env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("无效的标签筛选返回 %s", resp.Status)
+	}
+
+	// 告警规则只作用于匹配标签的客户端
+	mustPost(t, admin, ts.URL+"/api/alerts/rules/save", AlertRule{Name: "x", Metric: "cpu", Operator: ">", Threshold: 1, Labels: map[string]string{"id": "x"}}, http.StatusBadRequest)
+	mustPost(t, admin, ts.URL+"/api/alerts/rules/save", AlertRule{
+		Name: "CPU 过高", Metric: "cpu", Operator: ">=", Threshold: 0, Enabled: true,
+		Labels: map[string]string{"role": "web"},
+	}, http.StatusOK)
+	server.checkAlerts(time.Now())
+	events, _ := server.store.ListAlertEvents(0)
+	if len(events) != 1 || events[0].ClientID != web {
+		t.Fatalf("告警事件为 %+v", events)
+	}
+
+	// Prometheus 文本格式包含合并后的标签，离线客户端只有 up 指标
+	resp, err = admin.Get(ts.URL + "/metrics?label=role=web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	want := `gonitor_client_cpu_percent{id="` + web + `",name="web",env="prod",role="web"} 95`
+	if !strings.Contains(string(body), want) || strings.Contains(string(body), `name="db"`) {
+		t.Fatalf("导出的指标为:\n%s", body)
+	}
+
+	// 重新连接时替换声明的标签
+	conn.Close()
+	dialAgentWithLabels(t, ts.URL, web, "role=api")
+	waitFor(t, "标签更新", func() bool {
+		c, _ := server.clients.Snapshot().Get(web)
+		return c.Connected && maps.Equal(c.Labels, map[string]string{"role": "api"})
+	})
+
+	resp, err = http.Get(ts.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("匿名访问状态码为 %d", resp.StatusCode)
+	}
+}
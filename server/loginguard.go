@@ -0,0 +1,304 @@
+package main
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 登录结果
+const (
+	authSuccess = "success"
+	authFailure = "failure"
+	authLocked  = "locked"
+)
+
+const (
+	loginLockoutBase = 30 * time.Second // 第一次锁定的时长，之后每次失败翻倍
+	loginFailureTTL  = 24 * time.Hour   // 最后一次失败超过该时间后失败次数清零
+	maxAuthEvents    = 1000             // 保存的登录记录条数
+	maxLoginEntries  = 10000            // 内存中最多保存的失败记录数量，超过时删除最久没有失败的记录
+)
+
+// loginPolicy 一类登录限制：同一来源 IP 或同一用户名
+type loginPolicy struct {
+	kind       string // ip 或 user
+	free       int    // 允许连续失败的次数，达到后开始锁定
+	maxLockout time.Duration
+}
+
+// loginPolicies 用户名的锁定对所有来源生效，上限较短，避免攻击者长期锁住管理员
+var loginPolicies = []loginPolicy{
+	{"ip", 5, time.Hour},
+	{"user", 10, 15 * time.Minute},
+}
+
+// loginAttempts 一个 IP 或用户名的连续失败
+type loginAttempts struct {
+	key         loginKey
+	failures    int
+	last        time.Time
+	lockedUntil time.Time
+	reported    bool // 本次锁定期间是否已保存过被拒绝的登录记录
+}
+
+// LoginLockout 表示一个正在被锁定的 IP 或用户名
+type LoginLockout struct {
+	Kind        string    `json:"kind"` // ip 或 user
+	Value       string    `json:"value"`
+	Failures    int       `json:"failures"`
+	LockedUntil time.Time `json:"lockedUntil"`
+}
+
+// loginLimiter 记录登录失败并按指数增长的时长锁定，只保存在内存中，重启后清零
+// 记录按最后一次失败的时间排序，数量超过 limit 时删除最久没有失败的记录；
+// 锁定最长一小时，正在锁定的记录总是比已过期的记录新，只有大量不同来源同时失败时才会被提前删除
+type loginLimiter struct {
+	mu       sync.Mutex
+	attempts map[loginKey]*list.Element // 值为 *loginAttempts
+	order    *list.List                 // 最近失败的在前
+	limit    int
+}
+
+// loginKey 标识一个 IP 或用户名
+type loginKey struct {
+	policy *loginPolicy
+	value  string
+}
+
+func newLoginLimiter() *loginLimiter {
+	return &loginLimiter{
+		attempts: make(map[loginKey]*list.Element),
+		order:    list.New(),
+		limit:    maxLoginEntries,
+	}
+}
+
+// loginKeys 返回一次登录需要检查的 IP 和用户名
+func loginKeys(ip, username string) []loginKey {
+	return []loginKey{{&loginPolicies[0], ip}, {&loginPolicies[1], username}}
+}
+
+// check 返回还需要等待多久才能再次尝试，为 0 表示可以尝试；
+// first 表示这是本次锁定后第一次被拒绝的尝试，锁定期间重复的尝试不需要逐条保存
+func (l *loginLimiter) check(keys []loginKey, now time.Time) (wait time.Duration, first bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, key := range keys {
+		e, ok := l.attempts[key]
+		if !ok {
+			continue
+		}
+		if a := e.Value.(*loginAttempts); a.lockedUntil.After(now) {
+			wait = max(wait, a.lockedUntil.Sub(now))
+			if !a.reported {
+				a.reported = true
+				first = true
+			}
+		}
+	}
+	return wait, first
+}
+
+// fail 记录一次失败，返回因此锁定的时长，没有锁定时为 0
+func (l *loginLimiter) fail(keys []loginKey, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var lockout time.Duration
+	for _, key := range keys {
+		var a *loginAttempts
+		if e, ok := l.attempts[key]; ok {
+			l.order.MoveToFront(e)
+			a = e.Value.(*loginAttempts)
+			if now.Sub(a.last) > loginFailureTTL {
+				*a = loginAttempts{key: key}
+			}
+		} else {
+			a = &loginAttempts{key: key}
+			l.attempts[key] = l.order.PushFront(a)
+		}
+		a.failures++
+		a.last = now
+		if n := a.failures - key.policy.free; n >= 0 {
+			d := key.policy.maxLockout
+			if n < 32 {
+				d = min(loginLockoutBase<<n, d)
+			}
+			a.lockedUntil = now.Add(d)
+			a.reported = false
+			lockout = max(lockout, d)
+		}
+	}
+	l.prune(now)
+	return lockout
+}
+
+// succeed 登录成功后清除 IP 和用户名的失败记录
+func (l *loginLimiter) succeed(keys []loginKey) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, key := range keys {
+		l.remove(key)
+	}
+}
+
+// remove 删除 IP 或用户名的失败记录，返回是否存在，调用方需持有锁
+func (l *loginLimiter) remove(key loginKey) bool {
+	e, ok := l.attempts[key]
+	if ok {
+		l.order.Remove(e)
+		delete(l.attempts, key)
+	}
+	return ok
+}
+
+// prune 从最久没有失败的记录开始，删除已解除锁定且超过 loginFailureTTL 没有失败的记录，
+// 以及超出 limit 的记录，调用方需持有锁
+func (l *loginLimiter) prune(now time.Time) {
+	for e := l.order.Back(); e != nil; e = l.order.Back() {
+		a := e.Value.(*loginAttempts)
+		expired := now.After(a.lockedUntil) && now.Sub(a.last) > loginFailureTTL
+		if !expired && l.order.Len() <= l.limit {
+			return
+		}
+		l.remove(a.key)
+	}
+}
+
+// lockouts 返回正在锁定的 IP 和用户名，解除时间晚的在前
+func (l *loginLimiter) lockouts(now time.Time) []LoginLockout {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lockouts := []LoginLockout{}
+	for e := l.order.Front(); e != nil; e = e.Next() {
+		if a := e.Value.(*loginAttempts); a.lockedUntil.After(now) {
+			lockouts = append(lockouts, LoginLockout{Kind: a.key.policy.kind, Value: a.key.value, Failures: a.failures, LockedUntil: a.lockedUntil})
+		}
+	}
+	sort.Slice(lockouts, func(i, j int) bool { return lockouts[i].LockedUntil.After(lockouts[j].LockedUntil) })
+	return lockouts
+}
+
+// unlock 清除 IP 或用户名的失败记录，返回是否存在
+func (l *loginLimiter) unlock(kind, value string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := range loginPolicies {
+		if loginPolicies[i].kind != kind {
+			continue
+		}
+		if l.remove(loginKey{&loginPolicies[i], value}) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP 返回请求的来源 IP；设置了 trustProxy 时使用 X-Forwarded-For 中的最后一个地址，即最近的反向代理看到的来源
+func (s *Server) clientIP(r *http.Request) string {
+	if s.trustProxy {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			hops := strings.Split(forwarded[len(forwarded)-1], ",")
+			if ip := strings.TrimSpace(hops[len(hops)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// recordAuth 保存一条登录记录，保存失败只记录日志，不影响登录
+func (s *Server) recordAuth(r *http.Request, ip, username, result string) {
+	event := AuthEvent{
+		Time:      time.Now(),
+		Username:  username,
+		IP:        ip,
+		UserAgent: r.UserAgent(),
+		Result:    result,
+	}
+	if err := s.store.AppendAuthEvent(event, maxAuthEvents); err != nil {
+		log.Printf("保存登录记录出错: %v", err)
+	}
+}
+
+// tooManyAttempts 回复 429，Retry-After 为需要等待的秒数
+func tooManyAttempts(w http.ResponseWriter, wait time.Duration) {
+	seconds := int((wait + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	http.Error(w, fmt.Sprintf("登录失败次数过多，请 %d 秒后再试", seconds), http.StatusTooManyRequests)
+}
+
+// handleGetAuthEvents 返回最近的登录记录和正在锁定的 IP 和用户名，参数 limit 为记录条数，默认 100
+func (s *Server) handleGetAuthEvents(w http.ResponseWriter, r *http.Request) {
+	if err := s.authorizeAdmin(r); err != nil {
+		writeTextError(w, err)
+		return
+	}
+
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "无效的条数", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxAuthEvents)
+	}
+	events, err := s.store.ListAuthEvents(limit)
+	if err != nil {
+		log.Printf("读取登录记录出错: %v", err)
+		http.Error(w, "服务器内部错误", http.StatusInternalServerError)
+		return
+	}
+	if events == nil {
+		events = []AuthEvent{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"events":   events,
+		"lockouts": s.logins.lockouts(time.Now()),
+	})
+}
+
+// handleUnlockLogin 提前解除 IP 或用户名的锁定
+func (s *Server) handleUnlockLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := s.authorizeAdmin(r); err != nil {
+		writeTextError(w, err)
+		return
+	}
+
+	var info struct {
+		Kind  string `json:"kind"`
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&info); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !s.logins.unlock(info.Kind, info.Value) {
+		http.Error(w, "没有该锁定", http.StatusNotFound)
+		return
+	}
+	log.Printf("已解除 %s %s 的登录锁定", info.Kind, info.Value)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoginLimiter(t *testing.T) {
+	l := newLoginLimiter()
+	now := time.Now()
+	keys := loginKeys("10.0.0.1", "admin")
+
+	// IP 连续失败 5 次后锁定 30 秒，之后每次失败翻倍
+	for i := range 4 {
+		if lockout := l.fail(keys, now); lockout != 0 {
+			t.Fatalf("第 %d 次失败锁定了 %v", i+1, lockout)
+		}
+	}
+	if lockout := l.fail(keys, now); lockout != loginLockoutBase {
+		t.Fatalf("第 5 次失败锁定 %v", lockout)
+	}
+	if wait, first := l.check(keys, now.Add(10*time.Second)); wait != 20*time.Second || !first {
+		t.Fatalf("需要等待 %v，first=%v", wait, first)
+	}
+	// 同一次锁定期间再次被拒绝不是第一次
+	if wait, first := l.check(keys, now.Add(11*time.Second)); wait != 19*time.Second || first {
+		t.Fatalf("再次检查需要等待 %v，first=%v", wait, first)
+	}
+	// 用户名的锁定对其他 IP 同样生效
+	now = now.Add(loginLockoutBase)
+	other := loginKeys("10.0.0.2", "admin")
+	if wait, _ := l.check(other, now); wait != 0 {
+		t.Fatalf("其他 IP 需要等待 %v", wait)
+	}
+	if lockout := l.fail(keys, now); lockout != 2*loginLockoutBase {
+		t.Fatalf("第 6 次失败锁定 %v", lockout)
+	}
+	if _, first := l.check(keys, now); !first {
+		t.Fatal("重新锁定后第一次被拒绝的尝试应当保存记录")
+	}
+	for range 3 {
+		l.fail(other, now)
+	}
+	if lockout := l.fail(other, now); lockout != loginLockoutBase {
+		t.Fatalf("用户名第 10 次失败锁定 %v", lockout)
+	}
+	if lockouts := l.lockouts(now); len(lockouts) != 2 || lockouts[0].Kind != "ip" || lockouts[0].Value != "10.0.0.1" || lockouts[1].Kind != "user" {
+		t.Fatalf("锁定列表为 %+v", lockouts)
+	}
+
+	// 锁定时长有上限
+	for range 30 {
+		l.fail(keys, now)
+	}
+	if wait, _ := l.check(loginKeys("10.0.0.1", "root"), now); wait != time.Hour {
+		t.Fatalf("IP 的锁定为 %v", wait)
+	}
+
+	// 长时间没有失败后重新计数
+	later := now.Add(loginFailureTTL + 2*time.Hour)
+	if lockout := l.fail(keys, later); lockout != 0 {
+		t.Fatalf("失败记录过期后锁定了 %v", lockout)
+	}
+
+	if !l.unlock("ip", "10.0.0.1") || l.unlock("ip", "10.0.0.1") || l.unlock("host", "admin") {
+		t.Fatal("解除锁定的结果不正确")
+	}
+	l.succeed(keys)
+	if wait, _ := l.check(keys, later); wait != 0 {
+		t.Fatalf("登录成功后仍需等待 %v", wait)
+	}
+}
+
+func TestLoginLimiterCap(t *testing.T) {
+	l := newLoginLimiter()
+	l.limit = 3
+	now := time.Now()
+	ipKey := func(ip string) []loginKey { return []loginKey{{&loginPolicies[0], ip}} }
+
+	// 超过上限时删除最久没有失败的记录
+	for i := range 5 {
+		l.fail(ipKey(fmt.Sprintf("10.0.0.%d", i)), now.Add(time.Duration(i)*time.Second))
+	}
+	if len(l.attempts) != 3 || l.order.Len() != 3 {
+		t.Fatalf("保存了 %d 条记录", len(l.attempts))
+	}
+	for i, kept := range []bool{false, false, true, true, true} {
+		if _, ok := l.attempts[ipKey(fmt.Sprintf("10.0.0.%d", i))[0]]; ok != kept {
+			t.Fatalf("10.0.0.%d 的记录存在: %v", i, ok)
+		}
+	}
+
+	// 再次失败的记录移到最前面，不会被删除
+	now = now.Add(5 * time.Second)
+	l.fail(ipKey("10.0.0.2"), now)
+	l.fail(ipKey("10.0.0.5"), now)
+	if _, ok := l.attempts[ipKey("10.0.0.2")[0]]; !ok {
+		t.Fatal("最近失败的记录被删除")
+	}
+	if _, ok := l.attempts[ipKey("10.0.0.3")[0]]; ok {
+		t.Fatal("最久没有失败的记录没有被删除")
+	}
+
+	// 过期的记录在下次失败时删除，不需要达到上限
+	l.fail(ipKey("10.0.0.6"), now.Add(loginFailureTTL+time.Second))
+	if len(l.attempts) != 1 || l.order.Len() != 1 {
+		t.Fatalf("过期后保存了 %d 条记录", len(l.attempts))
+	}
+}
+
+func TestLoginLockoutAndAudit(t *testing.T) {
+	server, ts := newTestServer(t)
+	server.trustProxy = true
+
+	login := func(ip, password string) *http.Response {
+		t.Helper()
+		body, _ := json.Marshal(map[string]string{"username": "admin", "password": password})
+		req, _ := http.NewRequest("POST", ts.URL+"/api/login", bytes.NewReader(body))
+		req.Header.Set("X-Forwarded-For", "203.0.113.9, "+ip)
+		req.Header.Set("User-Agent", "test-agent/1.0")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	for range 5 {
+		if resp := login("198.51.100.1", "wrong"); resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("密码错误时返回 %s", resp.Status)
+		}
+	}
+	// 锁定期间正确的密码也被拒绝，重复的尝试只保存一条登录记录
+	for range 3 {
+		resp := login("198.51.100.1", "admin")
+		if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "30" {
+			t.Fatalf("锁定时返回 %s，Retry-After 为 %q", resp.Status, resp.Header.Get("Retry-After"))
+		}
+	}
+	// 其他 IP 不受影响，登录成功后用户名的失败次数清零
+	if resp := login("198.51.100.2", "admin"); resp.StatusCode != http.StatusOK {
+		t.Fatalf("其他 IP 登录返回 %s", resp.Status)
+	}
+
+	browser := loginClient(t, ts)
+	var result struct {
+		Events   []AuthEvent    `json:"events"`
+		Lockouts []LoginLockout `json:"lockouts"`
+	}
+	mustGet(t, browser, ts.URL+"/api/auth/events", &result)
+	if len(result.Events) != 8 || len(result.Lockouts) != 1 || result.Lockouts[0].Value != "198.51.100.1" {
+		t.Fatalf("登录记录为 %+v，锁定为 %+v", result.Events, result.Lockouts)
+	}
+	if e := result.Events[1]; e.Result != authSuccess || e.IP != "198.51.100.2" || e.UserAgent != "test-agent/1.0" {
+		t.Fatalf("成功的登录记录为 %+v", e)
+	}
+	if e := result.Events[2]; e.Result != authLocked || e.IP != "198.51.100.1" {
+		t.Fatalf("被锁定的登录记录为 %+v", e)
+	}
+	if e := result.Events[3]; e.Result != authFailure || e.Username != "admin" {
+		t.Fatalf("失败的登录记录为 %+v", e)
+	}
+	mustGet(t, browser, ts.URL+"/api/auth/events?limit=2", &result)
+	if len(result.Events) != 2 {
+		t.Fatalf("limit=2 返回 %d 条", len(result.Events))
+	}
+
+	mustPost(t, browser, ts.URL+"/api/auth/unlock", map[string]string{"kind": "ip", "value": "198.51.100.1"}, http.StatusOK)
+	if resp := login("198.51.100.1", "admin"); resp.StatusCode != http.StatusOK {
+		t.Fatalf("解除锁定后登录返回 %s", resp.Status)
+	}
+	mustPost(t, browser, ts.URL+"/api/auth/unlock", map[string]string{"kind": "ip", "value": "198.51.100.1"}, http.StatusNotFound)
+
+	resp, err := newCookieClient().Get(ts.URL + "/api/auth/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("匿名访问返回 %s", resp.Status)
+	}
+}
+
+func TestAuthEventsRetention(t *testing.T) {
+	st, err := openBoltStore(filepath.Join(t.TempDir(), dbFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		if err := st.AppendAuthEvent(AuthEvent{Username: name}, 3); err != nil {
+			t.Fatal(err)
+		}
+	}
+	events, err := st.ListAuthEvents(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(events)
+	if len(events) != 3 || events[0].Username != "e" || events[2].Username != "c" {
+		t.Fatalf("保留的登录记录为 %s", data)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// maxMaintenanceDuration 周期性维护每次最长持续的分钟数
+const maxMaintenanceDuration = 7 * 24 * 60
+
+// incidentRetention 已解决的故障事件在公开状态页上保留的时间
+const incidentRetention = 7 * 24 * time.Hour
+
+// incidentStatuses 故障事件的状态，resolved 表示已解决
+var incidentStatuses = map[string]bool{
+	"investigating": true,
+	"identified":    true,
+	"monitoring":    true,
+	"resolved":      true,
+}
+
+// MaintenanceStatus 表示维护窗口及其当前是否生效
+type MaintenanceStatus struct {
+	Maintenance
+	Active bool `json:"active"`
+}
+
+// normalizeMaintenance 校验维护窗口，一次性和周期性只能选择一种
+func normalizeMaintenance(m *Maintenance) error {
+	if m.Title == "" {
+		return errors.New("标题不能为空")
+	}
+	if m.Cron != "" {
+		if !m.StartsAt.IsZero() || !m.EndsAt.IsZero() {
+			return errors.New("周期性维护不能同时设置开始和结束时间")
+		}
+		if _, err := parseCron(m.Cron); err != nil {
+			return err
+		}
+		if m.Duration <= 0 || m.Duration > maxMaintenanceDuration {
+			return fmt.Errorf("持续时间应在 1-%d 分钟之间", maxMaintenanceDuration)
+		}
+	} else {
+		if m.StartsAt.IsZero() || m.EndsAt.IsZero() {
+			return errors.New("一次性维护需要设置开始和结束时间")
+		}
+		if !m.EndsAt.After(m.StartsAt) {
+			return errors.New("结束时间应晚于开始时间")
+		}
+		m.Duration = 0
+	}
+	m.Targets = slices.Compact(slices.Sorted(slices.Values(m.Targets)))
+	if m.Targets == nil {
+		m.Targets = []string{}
+	}
+	groups := []string{}
+	for _, group := range m.Groups {
+		if group = normalizeGroup(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+	slices.Sort(groups)
+	m.Groups = slices.Compact(groups)
+	return nil
+}
+
+// active 判断维护窗口在 now 时是否生效
+// 周期性维护按服务器本地时区，检查最近 Duration 分钟内是否有满足表达式的开始时间
+func (m Maintenance) active(now time.Time) bool {
+	if m.Cron == "" {
+		return !now.Before(m.StartsAt) && now.Before(m.EndsAt)
+	}
+	sched, err := parseCron(m.Cron)
+	if err != nil {
+		return false
+	}
+	t := now.Local().Truncate(time.Minute)
+	for range m.Duration {
+		if sched.matches(t) {
+			return true
+		}
+		t = t.Add(-time.Minute)
+	}
+	return false
+}
+
+// covers 判断维护窗口是否作用于指定的客户端或站点监控，group 为客户端的分组，站点监控为空
+func (m Maintenance) covers(id, group string) bool {
+	if len(m.Targets) == 0 && len(m.Groups) == 0 {
+		return true
+	}
+	if slices.Contains(m.Targets, id) {
+		return true
+	}
+	return group != "" && slices.ContainsFunc(m.Groups, func(g string) bool { return inGroup(group, g) })
+}
+
+// activeMaintenances 返回当前生效的维护窗口
+func (s *Server) activeMaintenances(now time.Time) ([]Maintenance, error) {
+	maintenances, err := s.store.ListMaintenances()
+	if err != nil {
+		return nil, fmt.Errorf("读取维护窗口出错: %w", err)
+	}
+	var active []Maintenance
+	for _, m := range maintenances {
+		if m.active(now) {
+			active = append(active, m)
+		}
+	}
+	return active, nil
+}
+
+// addIncidentUpdate 为故障事件添加一条进展并更新状态
+func addIncidentUpdate(incident *Incident, status, message string, now time.Time) error {
+	if !incidentStatuses[status] {
+		return fmt.Errorf("状态 %q 无效，可选 investigating、identified、monitoring、resolved", status)
+	}
+	if message == "" {
+		return errors.New("进展说明不能为空")
+	}
+	incident.Status = status
+	incident.Updates = append(incident.Updates, IncidentUpdate{Status: status, Message: message, Time: now})
+	if status == "resolved" {
+		incident.ResolvedAt = now
+	} else {
+		incident.ResolvedAt = time.Time{}
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("%q 应解析失败", expr)
+		}
+	}
+
+	// 2026-03-01 是周日
+	at := func(s string) time.Time {
+		v, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tests := []struct {
+		expr string
+		time string
+		want bool
+	}{
+		{"0 3 * * 0", "2026-03-01 03:00", true},
+		{"0 3 * * 7", "2026-03-01 03:00", true},
+		{"0 3 * * 0", "2026-03-02 03:00", false},
+		{"0 3 * * 0", "2026-03-01 03:01", false},
+		{"*/15 * * * *", "2026-03-02 10:45", true},
+		{"*/15 * * * *", "2026-03-02 10:50", false},
+		{"30 1-5/2 * * *", "2026-03-02 03:30", true},
+		{"30 1-5/2 * * *", "2026-03-02 04:30", false},
+		{"0 0 1,15 * *", "2026-03-15 00:00", true},
+		// 日和周都有限制时满足其一即可
+		{"0 0 15 * 1", "2026-03-02 00:00", true},
+		{"0 0 15 * 1", "2026-03-03 00:00", false},
+		// 以 * 开头的字段（如 */2）不算限制，此时日和周都要满足
+		{"0 3 */2 * 1", "2026-03-09 03:00", true},
+		{"0 3 */2 * 1", "2026-03-02 03:00", false},
+		{"0 3 */2 * 1", "2026-03-03 03:00", false},
+		{"0 3 1 * */2", "2026-03-01 03:00", true},
+		{"0 3 1 * */2", "2026-03-03 03:00", false},
+	}
+	for _, tt := range tests {
+		sched, err := parseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.expr, err)
+		}
+		if got := sched.matches(at(tt.time)); got != tt.want {
+			t.Errorf("%q 在 %s 的匹配结果为 %v，期望 %v", tt.expr, tt.time, got, tt.want)
+		}
+	}
+}
+
+func TestMaintenanceActive(t *testing.T) {
+	now := time.Date(2026, 3, 2, 3, 30, 0, 0, time.Local)
+	once := Maintenance{StartsAt: now.Add(-time.Hour), EndsAt: now.Add(time.Hour)}
+	if !once.active(now) || once.active(now.Add(time.Hour)) || once.active(now.Add(-2*time.Hour)) {
+		t.Error("一次性维护的生效时间不正确")
+	}
+
+	// 每天 3:00 开始，持续 60 分钟
+	daily := Maintenance{Cron: "0 3 * * *", Duration: 60}
+	if !daily.active(now) || !daily.active(now.Add(-30*time.Minute)) {
+		t.Error("周期性维护应在 3:00-4:00 生效")
+	}
+	if daily.active(now.Add(30*time.Minute)) || daily.active(now.Add(-31*time.Minute)) {
+		t.Error("周期性维护不应在 3:00-4:00 之外生效")
+	}
+
+	if err := normalizeMaintenance(&Maintenance{Title: "x", Cron: "0 3 * * *"}); err == nil {
+		t.Error("周期性维护缺少持续时间时应校验失败")
+	}
+	if err := normalizeMaintenance(&Maintenance{Title: "x", StartsAt: now, EndsAt: now}); err == nil {
+		t.Error("结束时间不晚于开始时间时应校验失败")
+	}
+}
+
+func TestAlertEngineSuppressed(t *testing.T) {
+	e := newAlertEngine()
+	rule := AlertRule{ID: "r", Name: "离线", Metric: "connected", Operator: "<", Threshold: 1, Duration: 30, Enabled: true}
+	target := func(suppressed bool) []alertTarget {
+		return []alertTarget{{ID: "c", Name: "web", Values: map[string]float64{"connected": 0}, Suppressed: suppressed}}
+	}
+
+	now := time.Now()
+	e.evaluate([]AlertRule{rule}, target(false), now)
+	if events := e.evaluate([]AlertRule{rule}, target(true), now.Add(time.Minute)); len(events) != 0 {
+		t.Fatalf("维护期间不应触发: %+v", events)
+	}
+	// 维护结束后重新计时
+	if events := e.evaluate([]AlertRule{rule}, target(false), now.Add(2*time.Minute)); len(events) != 0 {
+		t.Fatalf("维护刚结束不应立即触发: %+v", events)
+	}
+	events := e.evaluate([]AlertRule{rule}, target(false), now.Add(3*time.Minute))
+	if len(events) != 1 || events[0].State != "firing" {
+		t.Fatalf("告警事件为 %+v", events)
+	}
+	// 已触发的告警在维护期间保持，不产生恢复事件
+	if events := e.evaluate([]AlertRule{rule}, target(true), now.Add(4*time.Minute)); len(events) != 0 {
+		t.Fatalf("维护期间不应产生事件: %+v", events)
+	}
+}
+
+func TestMaintenanceAPI(t *testing.T) {
+	server, ts := newTestServer(t)
+	admin := loginClient(t, ts)
+	a := addClient(t, admin, ts, "a")
+	b := addClient(t, admin, ts, "b")
+	mustPost(t, admin, ts.URL+"/api/alerts/rules/save", AlertRule{Name: "离线", Metric: "connected", Operator: "<", Threshold: 1, Enabled: true}, http.StatusOK)
+
+	mustPost(t, admin, ts.URL+"/api/maintenances/save", Maintenance{Title: "x", Cron: "bad", Duration: 10}, http.StatusBadRequest)
+	mustPost(t, admin, ts.URL+"/api/maintenances/save", Maintenance{ID: "missing", Title: "x", Cron: "0 3 * * *", Duration: 10}, http.StatusNotFound)
+
+	now := time.Now()
+	m := Maintenance{Title: "补丁重启", Targets: []string{a}, StartsAt: now.Add(-time.Minute), EndsAt: now.Add(time.Hour)}
+	m.ID = mustPost(t, admin, ts.URL+"/api/maintenances/save", m, http.StatusOK)["id"]
+
+	var list []MaintenanceStatus
+	mustGet(t, admin, ts.URL+"/api/maintenances", &list)
+	if len(list) != 1 || list[0].ID != m.ID || !list[0].Active || list[0].CreatedAt.IsZero() {
+		t.Fatalf("维护窗口为 %+v", list)
+	}
+	resp, err := http.Get(ts.URL + "/api/maintenances")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("匿名访问状态码为 %d", resp.StatusCode)
+	}
+
+	// 只有不在维护中的客户端产生告警
+	server.checkAlerts(now)
+	events, _ := server.store.ListAlertEvents(0)
+	if len(events) != 1 || events[0].ClientID != b {
+		t.Fatalf("告警事件为 %+v", events)
+	}
+
+	mustPost(t, admin, ts.URL+"/api/maintenances/delete", map[string]string{"id": m.ID}, http.StatusOK)
+	server.checkAlerts(now.Add(time.Second))
+	events, _ = server.store.ListAlertEvents(0)
+	if len(events) != 2 || events[0].ClientID != a {
+		t.Fatalf("删除维护窗口后告警事件为 %+v", events)
+	}
+}
+
+func TestIncidents(t *testing.T) {
+	server, ts := newTestServer(t)
+	admin := loginClient(t, ts)
+	anonymous := newCookieClient()
+	mustPost(t, admin, ts.URL+"/api/status-page/save", StatusPage{Enabled: true}, http.StatusOK)
+
+	mustPost(t, anonymous, ts.URL+"/api/incidents/create", map[string]string{"title": "x", "message": "y"}, http.StatusUnauthorized)
+	mustPost(t, admin, ts.URL+"/api/incidents/create", map[string]string{"title": "x", "status": "unknown", "message": "y"}, http.StatusBadRequest)
+	mustPost(t, admin, ts.URL+"/api/incidents/update", map[string]string{"id": "missing", "status": "resolved", "message": "y"}, http.StatusNotFound)
+
+	id := mustPost(t, admin, ts.URL+"/api/incidents/create", map[string]string{"title": "API 响应缓慢", "message": "正在排查"}, http.StatusOK)["id"]
+	var status publicStatus
+	mustGet(t, anonymous, ts.URL+"/status/data", &status)
+	if len(status.Incidents) != 1 || status.Incidents[0].Status != "investigating" || status.Incidents[0].Updates[0].Message != "正在排查" {
+		t.Fatalf("状态页故障事件为 %+v", status.Incidents)
+	}
+
+	mustPost(t, admin, ts.URL+"/api/incidents/update", map[string]string{"id": id, "status": "resolved", "message": "已修复"}, http.StatusOK)
+	var incidents []Incident
+	mustGet(t, admin, ts.URL+"/api/incidents", &incidents)
+	if len(incidents) != 1 || incidents[0].Status != "resolved" || len(incidents[0].Updates) != 2 || incidents[0].ResolvedAt.IsZero() {
+		t.Fatalf("故障事件为 %+v", incidents)
+	}
+	mustGet(t, anonymous, ts.URL+"/status/data", &status)
+	if len(status.Incidents) != 1 || status.Incidents[0].Status != "resolved" {
+		t.Fatalf("状态页故障事件为 %+v", status.Incidents)
+	}
+
+	// 解决较久的故障事件不再显示
+	server.statusCache.reset()
+	old, err := server.publicStatus(time.Now().Add(incidentRetention + time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(old.Incidents) != 0 {
+		t.Fatalf("过期的故障事件仍然显示 %+v", old.Incidents)
+	}
+
+	mustPost(t, admin, ts.URL+"/api/incidents/delete", map[string]string{"id": id}, http.StatusOK)
+	mustGet(t, anonymous, ts.URL+"/status/data", &status)
+	if len(status.Incidents) != 0 {
+		t.Fatalf("删除后状态页仍有故障事件 %+v", status.Incidents)
+	}
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MonitorStatus 表示服务端探测及其最新结果
+type MonitorStatus struct {
+	Monitor
+	Latest *CheckResult `json:"latest"` // 尚未完成第一次探测时为 null
+}
+
+// normalizeMonitor 校验服务端探测的配置并补全默认值
+func normalizeMonitor(m *Monitor) error {
+	if m.Name == "" || m.Target == "" {
+		return errors.New("名称和目标不能为空")
+	}
+	switch m.Type {
+	case "http":
+		if u, err := url.Parse(m.Target); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("地址 %q 无效", m.Target)
+		}
+		if m.Method == "" {
+			m.Method = http.MethodGet
+		}
+		if _, err := regexp.Compile(m.BodyMatch); err != nil {
+			return fmt.Errorf("bodyMatch 无效: %w", err)
+		}
+	case "tcp":
+		if _, _, err := net.SplitHostPort(m.Target); err != nil {
+			return fmt.Errorf("地址 %q 无效，应为 host:port", m.Target)
+		}
+	case "dns":
+		if _, _, err := net.SplitHostPort(m.Target); err == nil {
+			return fmt.Errorf("域名 %q 无效，不能包含端口", m.Target)
+		}
+	default:
+		return fmt.Errorf("类型 %q 无效，可选 http、tcp、dns", m.Type)
+	}
+	if m.Type != "http" {
+		m.Method, m.ExpectStatus, m.BodyMatch, m.MinCertDays = "", nil, "", 0
+	}
+	if m.Interval <= 0 {
+		m.Interval = 60
+	}
+	m.Interval = max(m.Interval, 5)
+	if m.Timeout <= 0 {
+		m.Timeout = 10
+	}
+	m.Timeout = min(m.Timeout, m.Interval)
+	return nil
+}
+
+// saveMonitor 校验并保存服务端探测，返回其配置和最新结果
+// ID 为空时添加并排在最后，否则修改已有的探测，创建时间和显示顺序保持不变
+func (s *Server) saveMonitor(m Monitor) (MonitorStatus, error) {
+	if err := normalizeMonitor(&m); err != nil {
+		return MonitorStatus{}, errInvalid(err.Error())
+	}
+	if m.ID == "" {
+		m.ID = newID()
+		m.CreatedAt = time.Now()
+		m.DisplayOrder = 0
+		for _, other := range s.monitors.List() {
+			m.DisplayOrder = max(m.DisplayOrder, other.DisplayOrder+1)
+		}
+	} else {
+		old, ok := s.monitors.Get(m.ID)
+		if !ok {
+			return MonitorStatus{}, errNotFound("服务端探测不存在")
+		}
+		m.CreatedAt = old.CreatedAt
+		m.DisplayOrder = old.DisplayOrder
+	}
+
+	if err := s.store.SaveMonitor(m); err != nil {
+		return MonitorStatus{}, fmt.Errorf("保存服务端探测出错: %w", err)
+	}
+	s.monitors.Set(m)
+	status, _ := s.monitors.Get(m.ID)
+	return status, nil
+}
+
+// monitorTask 表示一个正在运行的服务端探测
+type monitorTask struct {
+	monitor   Monitor
+	bodyMatch *regexp.Regexp
+	cancel    context.CancelFunc // 启动之前为 nil
+	done      chan struct{}      // 协程退出后关闭，启动之前为 nil
+}
+
+// monitorRunner 为每个服务端探测运行一个协程，保存探测结果并记录每个探测的最新结果
+type monitorRunner struct {
+	store    Store
+	client   *http.Client
+	resolver *net.Resolver
+
+	mu     sync.Mutex
+	ctx    context.Context // Start 之前为 nil，此时只记录配置不启动探测
+	tasks  map[string]*monitorTask
+	latest map[string]CheckResult
+	wg     sync.WaitGroup
+}
+
+// newMonitorRunner 使用已保存的服务端探测创建运行器，调用 Start 后才开始探测
+func newMonitorRunner(store Store, monitors []Monitor) *monitorRunner {
+	r := &monitorRunner{
+		store: store,
+		client: &http.Client{
+			// 每次探测都重新建立连接，延迟中包含连接建立的时间
+			Transport: &http.Transport{DisableKeepAlives: true, Proxy: http.ProxyFromEnvironment},
+		},
+		resolver: net.DefaultResolver,
+		tasks:    make(map[string]*monitorTask, len(monitors)),
+		latest:   make(map[string]CheckResult),
+	}
+	for _, m := range monitors {
+		r.tasks[m.ID] = newMonitorTask(m)
+	}
+	return r
+}
+
+// newMonitorTask 创建探测任务，配置在保存前已校验过，正则编译失败时忽略 bodyMatch
+func newMonitorTask(m Monitor) *monitorTask {
+	t := &monitorTask{monitor: m}
+	if m.BodyMatch != "" {
+		if re, err := regexp.Compile(m.BodyMatch); err == nil {
+			t.bodyMatch = re
+		} else {
+			log.Printf("服务端探测 %s 的 bodyMatch 无效: %v", m.Name, err)
+		}
+	}
+	return t
+}
+
+// Start 启动所有探测，ctx 取消后全部停止
+func (r *monitorRunner) Start(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ctx = ctx
+	for _, t := range r.tasks {
+		r.start(t)
+	}
+}
+
+// start 启动单个探测的协程，调用方需持有锁
+func (r *monitorRunner) start(t *monitorTask) {
+	if r.ctx == nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(r.ctx)
+	t.cancel = cancel
+	t.done = make(chan struct{})
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer close(t.done)
+		r.loop(ctx, t)
+	}()
+}
+
+// Set 新增或替换一个探测，已在运行的旧配置会先停止
+func (r *monitorRunner) Set(m Monitor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if old, ok := r.tasks[m.ID]; ok && old.cancel != nil {
+		old.cancel()
+	}
+	t := newMonitorTask(m)
+	r.tasks[m.ID] = t
+	r.start(t)
+}
+
+// Remove 停止并移除探测，等待正在进行的探测保存完结果后返回，
+// 之后删除探测结果不会被迟到的结果重新写入
+func (r *monitorRunner) Remove(id string) {
+	r.mu.Lock()
+	t, ok := r.tasks[id]
+	if ok && t.cancel != nil {
+		t.cancel()
+	}
+	delete(r.tasks, id)
+	delete(r.latest, id)
+	r.mu.Unlock()
+
+	// 保存结果时需要获取锁，因此在释放锁之后等待
+	if ok && t.done != nil {
+		<-t.done
+	}
+}
+
+// Get 返回探测的配置和最新结果
+func (r *monitorRunner) Get(id string) (MonitorStatus, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.tasks[id]
+	if !ok {
+		return MonitorStatus{}, false
+	}
+	return r.status(t), true
+}
+
+// List 按显示顺序返回所有探测的配置和最新结果
+func (r *monitorRunner) List() []MonitorStatus {
+	r.mu.Lock()
+	list := make([]MonitorStatus, 0, len(r.tasks))
+	for _, t := range r.tasks {
+		list = append(list, r.status(t))
+	}
+	r.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.DisplayOrder != b.DisplayOrder {
+			return a.DisplayOrder < b.DisplayOrder
+		}
+		return a.ID < b.ID
+	})
+	return list
+}
+
+// status 返回探测的配置和最新结果，调用方需持有锁
+func (r *monitorRunner) status(t *monitorTask) MonitorStatus {
+	status := MonitorStatus{Monitor: t.monitor}
+	status.ExpectStatus = slices.Clone(status.ExpectStatus)
+	if latest, ok := r.latest[t.monitor.ID]; ok {
+		status.Latest = &latest
+	}
+	return status
+}
+
+// Wait 等待所有探测协程退出
+func (r *monitorRunner) Wait() {
+	r.wg.Wait()
+}
+
+// loop 定时执行单个探测
+func (r *monitorRunner) loop(ctx context.Context, t *monitorTask) {
+	m := t.monitor
+	ticker := time.NewTicker(time.Duration(m.Interval) * time.Second)
+	defer ticker.Stop()
+
+	wasUp := true
+	for {
+		result := r.run(ctx, t)
+		// 探测被停止时结果没有意义
+		if ctx.Err() != nil {
+			return
+		}
+		if result.Up != wasUp {
+			if result.Up {
+				log.Printf("服务端探测 %s 已恢复", m.Name)
+			} else {
+				log.Printf("服务端探测 %s 失败: %s", m.Name, result.Message)
+			}
+			wasUp = result.Up
+		}
+		r.record(t, result)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// record 保存探测结果，探测在此期间被替换或移除时只保存不更新最新结果
+func (r *monitorRunner) record(t *monitorTask, result CheckResult) {
+	if err := r.store.AppendCheckResults(t.monitor.ID, result); err != nil {
+		log.Printf("保存探测结果出错: %v", err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.tasks[t.monitor.ID] == t {
+		r.latest[t.monitor.ID] = result
+	}
+}
+
+// run 执行一次探测，panic 作为失败结果返回
+func (r *monitorRunner) run(ctx context.Context, t *monitorTask) (result CheckResult) {
+	m := t.monitor
+	result = CheckResult{Name: m.Name, Type: m.Type, Target: m.Target, Time: time.Now()}
+	defer func() {
+		if p := recover(); p != nil {
+			result.Up = false
+			result.Message = fmt.Sprintf("panic: %v", p)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(m.Timeout)*time.Second)
+	defer cancel()
+	start := time.Now()
+	var err error
+	switch m.Type {
+	case "http":
+		err = r.probeHTTP(ctx, t, &result)
+	case "tcp":
+		err = probeTCP(ctx, m.Target)
+	case "dns":
+		err = r.probeDNS(ctx, m.Target)
+	}
+	result.Latency = float64(time.Since(start).Microseconds()) / 1000
+	result.Up = err == nil
+	if err != nil {
+		result.Message = err.Error()
+	}
+	return result
+}
+
+// probeHTTP 发送 HTTP 请求并检查状态码、响应内容和证书有效期
+func (r *monitorRunner) probeHTTP(ctx context.Context, t *monitorTask, result *CheckResult) error {
+	m := t.monitor
+	req, err := http.NewRequestWithContext(ctx, m.Method, m.Target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "gonitor-server")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	result.StatusCode = resp.StatusCode
+
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		result.CertExpiresAt = resp.TLS.PeerCertificates[0].NotAfter
+	}
+
+	if len(m.ExpectStatus) > 0 {
+		if !slices.Contains(m.ExpectStatus, resp.StatusCode) {
+			return fmt.Errorf("状态码 %d 不在期望的 %v 中", resp.StatusCode, m.ExpectStatus)
+		}
+	} else if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("状态码 %d", resp.StatusCode)
+	}
+
+	if t.bodyMatch != nil {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		if err != nil {
+			return fmt.Errorf("读取响应失败: %w", err)
+		}
+		if !t.bodyMatch.Match(body) {
+			return fmt.Errorf("响应内容不匹配 %s", m.BodyMatch)
+		}
+	}
+
+	if m.MinCertDays > 0 && !result.CertExpiresAt.IsZero() {
+		if left := time.Until(result.CertExpiresAt); left < time.Duration(m.MinCertDays)*24*time.Hour {
+			return fmt.Errorf("证书将在 %s 过期", result.CertExpiresAt.Format(time.DateOnly))
+		}
+	}
+	return nil
+}
+
+// probeTCP 检查端口能否建立连接
+func probeTCP(ctx context.Context, target string) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", target)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// probeDNS 检查域名能否解析出至少一个地址
+func (r *monitorRunner) probeDNS(ctx context.Context, host string) error {
+	addrs, err := r.resolver.LookupHost(ctx, host)
+	if err != nil {
+		return err
+	}
+	if len(addrs) == 0 {
+		return fmt.Errorf("%s 没有解析记录", host)
+	}
+	return nil
+}
+
+// monitorValues 返回服务端探测最新结果对应的告警指标
+func monitorValues(result CheckResult, now time.Time) map[string]float64 {
+	values := map[string]float64{"up": 0, "latency": result.Latency}
+	if result.Up {
+		values["up"] = 1
+	}
+	if !result.CertExpiresAt.IsZero() {
+		values["cert_days"] = result.CertExpiresAt.Sub(now).Hours() / 24
+	}
+	return values
+}
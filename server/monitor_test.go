@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// startMonitors 启动服务端探测，测试结束时先于服务端关闭
+func startMonitors(t *testing.T, server *Server) {
+	ctx, cancel := context.WithCancel(context.Background())
+	server.monitors.Start(ctx)
+	t.Cleanup(func() {
+		cancel()
+		server.monitors.Wait()
+	})
+}
+
+// closedPort 返回一个没有监听的本地地址
+func closedPort(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+func TestServerMonitors(t *testing.T) {
+	server, ts := newTestServer(t)
+	startMonitors(t, server)
+	admin := loginClient(t, ts)
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("status: ok"))
+	}))
+	defer backend.Close()
+
+	save := func(m Monitor) string {
+		t.Helper()
+		return mustPost(t, admin, ts.URL+"/api/monitors/save", m, http.StatusOK)["id"]
+	}
+	web := Monitor{Name: "web", Type: "http", Target: backend.URL, BodyMatch: "ok"}
+	web.ID = save(web)
+	db := save(Monitor{Name: "db", Type: "tcp", Target: closedPort(t)})
+	dns := save(Monitor{Name: "dns", Type: "dns", Target: "localhost"})
+
+	latest := func(id string) *CheckResult {
+		m, _ := server.monitors.Get(id)
+		return m.Latest
+	}
+	waitFor(t, "第一次探测", func() bool {
+		return latest(web.ID) != nil && latest(db) != nil && latest(dns) != nil
+	})
+	if !latest(web.ID).Up || latest(web.ID).StatusCode != http.StatusOK {
+		t.Fatalf("http 探测结果为 %+v", latest(web.ID))
+	}
+	if latest(db).Up {
+		t.Fatalf("连接未监听的端口应失败: %+v", latest(db))
+	}
+	if !latest(dns).Up {
+		t.Fatalf("dns 探测结果为 %+v", latest(dns))
+	}
+
+	// 登录后返回完整配置，未登录时不返回任何内容
+	var monitors []MonitorStatus
+	mustGet(t, admin, ts.URL+"/api/monitors", &monitors)
+	if len(monitors) != 3 || monitors[0].ID != web.ID || monitors[0].Target != backend.URL || monitors[0].Latest == nil {
+		t.Fatalf("服务端探测列表为 %+v", monitors)
+	}
+	mustGet(t, newCookieClient(), ts.URL+"/api/monitors", &monitors)
+	if len(monitors) != 0 {
+		t.Fatalf("未登录时返回了 %+v", monitors)
+	}
+
+	var status CheckStatus
+	mustGet(t, admin, ts.URL+"/api/monitors/status?id="+web.ID, &status)
+	if status.Uptime24h == nil || *status.Uptime24h != 100 {
+		t.Fatalf("可用率为 %+v", status)
+	}
+
+	// 修改后按新配置重新探测
+	web.Target = backend.URL + "/missing"
+	save(web)
+	waitFor(t, "按新配置探测", func() bool {
+		r := latest(web.ID)
+		return r != nil && !r.Up && r.StatusCode == http.StatusNotFound
+	})
+
+	// 删除后探测结果一并删除
+	mustPost(t, admin, ts.URL+"/api/monitors/delete", map[string]string{"id": web.ID}, http.StatusOK)
+	if _, ok := server.monitors.Get(web.ID); ok {
+		t.Fatal("删除后探测仍在运行")
+	}
+	if results, _ := server.store.ListCheckResults(web.ID, time.Time{}); len(results) != 0 {
+		t.Fatalf("删除后仍有 %d 条探测结果", len(results))
+	}
+}
+
+func TestSaveMonitorValidation(t *testing.T) {
+	_, ts := newTestServer(t)
+	admin := loginClient(t, ts)
+
+	for _, m := range []Monitor{
+		{Name: "", Type: "http", Target: "http://example.com"},
+		{Name: "a", Type: "http", Target: "ftp://example.com"},
+		{Name: "a", Type: "http", Target: "http://example.com", BodyMatch: "("},
+		{Name: "a", Type: "tcp", Target: "example.com"},
+		{Name: "a", Type: "dns", Target: "example.com:53"},
+		{Name: "a", Type: "icmp", Target: "example.com"},
+	} {
+		mustPost(t, admin, ts.URL+"/api/monitors/save", m, http.StatusBadRequest)
+	}
+	mustPost(t, admin, ts.URL+"/api/monitors/save", Monitor{ID: "missing", Name: "a", Type: "dns", Target: "example.com"}, http.StatusNotFound)
+	mustPost(t, newCookieClient(), ts.URL+"/api/monitors/save", Monitor{Name: "a", Type: "dns", Target: "example.com"}, http.StatusUnauthorized)
+}
+
+// blockingStore 保存探测结果前先通知并等待放行，用于模拟删除探测时正在保存的结果
+type blockingStore struct {
+	Store
+	appending chan struct{}
+	release   chan struct{}
+}
+
+func (s *blockingStore) AppendCheckResults(checkID string, results ...CheckResult) error {
+	s.appending <- struct{}{}
+	<-s.release
+	return s.Store.AppendCheckResults(checkID, results...)
+}
+
+func TestMonitorRemoveWaitsForRecord(t *testing.T) {
+	st, err := openBoltStore(filepath.Join(t.TempDir(), dbFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	store := &blockingStore{Store: st, appending: make(chan struct{}), release: make(chan struct{})}
+	m := Monitor{ID: "m1", Name: "db", Type: "tcp", Target: closedPort(t), Interval: 60, Timeout: 1}
+	runner := newMonitorRunner(store, []Monitor{m})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runner.Start(ctx)
+
+	<-store.appending
+	removed := make(chan struct{})
+	go func() {
+		runner.Remove(m.ID)
+		close(removed)
+	}()
+	select {
+	case <-removed:
+		t.Fatal("Remove 在探测结果保存完成之前返回")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(store.release)
+	<-removed
+
+	if err := st.DeleteMonitor(m.ID); err != nil {
+		t.Fatal(err)
+	}
+	runner.Wait()
+	if results, _ := st.ListCheckResults(m.ID, time.Time{}); len(results) != 0 {
+		t.Fatalf("删除后仍有 %d 条探测结果", len(results))
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// passwordScheme 密码哈希的算法标识
+const passwordScheme = "pbkdf2-sha256"
+
+// passwordIterations 计算新密码哈希时的迭代次数，已保存的哈希按其中记录的次数验证，调整后不影响旧密码
+var passwordIterations = 600000
+
+// hashPassword 以随机盐计算密码的 PBKDF2-SHA256 哈希，格式为 pbkdf2-sha256$迭代次数$盐$哈希
+func hashPassword(password string) string {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		panic(err)
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, sha256.Size)
+	if err != nil {
+		panic(err)
+	}
+	return fmt.Sprintf("%s$%d$%s$%s", passwordScheme, passwordIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+// checkPassword 判断密码与 hashPassword 得到的哈希是否匹配，以恒定时间比较；
+// 哈希为空或格式无效时仍然计算一次哈希，避免通过响应时间判断用户是否存在
+func checkPassword(password, hash string) bool {
+	iterations, salt, want, ok := parsePasswordHash(hash)
+	if !ok {
+		iterations, salt = passwordIterations, make([]byte, 16)
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, iterations, sha256.Size)
+	return ok && err == nil && subtle.ConstantTimeCompare(key, want) == 1
+}
+
+// parsePasswordHash 解析 hashPassword 得到的哈希
+func parsePasswordHash(hash string) (iterations int, salt, key []byte, ok bool) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != passwordScheme {
+		return 0, nil, nil, false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return 0, nil, nil, false
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[2]); err != nil {
+		return 0, nil, nil, false
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[3]); err != nil || len(key) != sha256.Size {
+		return 0, nil, nil, false
+	}
+	return iterations, salt, key, true
+}
+
+// legacyUser 旧版 user.json 和数据库版本 10 之前保存的用户，密码为原文
+type legacyUser struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// hashed 返回只保存密码哈希的用户
+func (u legacyUser) hashed() User {
+	return User{Username: u.Username, PasswordHash: hashPassword(u.Password)}
+}
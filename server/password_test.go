@@ -0,0 +1,78 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func init() {
+	// 测试中频繁登录，减少迭代次数以免在 -race 下过慢
+	passwordIterations = 1000
+}
+
+func TestPasswordHash(t *testing.T) {
+	hash := hashPassword("secret")
+	if !strings.HasPrefix(hash, passwordScheme+"$1000$") || strings.Contains(hash, "secret") {
+		t.Fatalf("密码哈希为 %q", hash)
+	}
+	if hashPassword("secret") == hash {
+		t.Fatal("相同的密码应使用不同的盐")
+	}
+	if !checkPassword("secret", hash) || checkPassword("Secret", hash) || checkPassword("", hash) {
+		t.Fatal("密码验证结果不正确")
+	}
+
+	// 已保存的哈希按其中记录的迭代次数验证
+	passwordIterations = 2000
+	defer func() { passwordIterations = 1000 }()
+	if !checkPassword("secret", hash) {
+		t.Fatal("修改迭代次数后旧的哈希无法验证")
+	}
+
+	for _, invalid := range []string{"", "secret", "md5$1000$c2FsdA$aGFzaA", passwordScheme + "$0$c2FsdA$aGFzaA", passwordScheme + "$1000$c2FsdA$aGFzaA"} {
+		if checkPassword("secret", invalid) {
+			t.Errorf("无效的哈希 %q 验证通过", invalid)
+		}
+	}
+}
+
+func TestMigratePlaintextPasswords(t *testing.T) {
+	// 构造版本 9 的数据库，用户密码为原文
+	path := filepath.Join(t.TempDir(), dbFile)
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, migrate := range migrations[:9] {
+			if err := migrate(tx); err != nil {
+				return err
+			}
+		}
+		meta, err := tx.CreateBucketIfNotExists(bucketMeta)
+		if err != nil {
+			return err
+		}
+		if err := meta.Put(keySchemaVersion, itob(9)); err != nil {
+			return err
+		}
+		return tx.Bucket(bucketUsers).Put([]byte("root"), []byte(`{"username": "root", "password": "secret"}`))
+	})
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := openBoltStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	user, err := st.GetUser("root")
+	if err != nil || user.Username != "root" || !checkPassword("secret", user.PasswordHash) {
+		t.Fatalf("迁移后的用户为 %+v, %v", user, err)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// legacyBackups 旧版本数据文件最多保留的备份数量
+const legacyBackups = 5
+
+// jsonFile 表示一个以 JSON 格式保存的数据文件及其轮转备份（clients.json.1 为最新）
+// 目前仅用于从旧版本的数据文件导入数据
+type jsonFile struct {
+	path    string
+	backups int
+}
+
+// newJSONFile 创建数据文件读取器
+func newJSONFile(path string, backups int) *jsonFile {
+	return &jsonFile{path: path, backups: backups}
+}
+
+// load 读取并解析数据文件，解析失败时依次尝试从最新的备份恢复
+// 文件不存在时返回 os.ErrNotExist
+func (f *jsonFile) load(v any) error {
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		if err = json.Unmarshal(data, v); err == nil {
+			return nil
+		}
+	}
+	log.Printf("读取数据文件 %s 出错: %v，尝试从备份恢复", f.path, err)
+
+	for i := 1; i <= f.backups; i++ {
+		backup := f.backupPath(i)
+		data, berr := os.ReadFile(backup)
+		if berr != nil {
+			continue
+		}
+		if berr = json.Unmarshal(data, v); berr != nil {
+			log.Printf("备份文件 %s 无效: %v", backup, berr)
+			continue
+		}
+
+		// 保留损坏的文件以便排查，再用备份内容覆盖
+		f.keepCorrupt()
+		if werr := writeFileAtomic(f.path, data, 0644); werr != nil {
+			log.Printf("写回恢复的数据出错: %v", werr)
+		}
+		log.Printf("已从备份 %s 恢复数据文件 %s", backup, f.path)
+		return nil
+	}
+	// 没有可用的备份时保持文件不变，由调用方决定如何处理
+	return fmt.Errorf("数据文件 %s 损坏且没有可用的备份: %w", f.path, err)
+}
+
+// keepCorrupt 将损坏的数据文件改名保留，避免后续写入覆盖
+func (f *jsonFile) keepCorrupt() {
+	corrupt := fmt.Sprintf("%s.corrupt-%s", f.path, time.Now().Format("20060102150405"))
+	if err := os.Rename(f.path, corrupt); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("保留损坏的数据文件出错: %v", err)
+		return
+	}
+	log.Printf("损坏的数据文件已保存为 %s", corrupt)
+}
+
+// backupPath 返回第 n 份备份的路径，n 越小越新
+func (f *jsonFile) backupPath(n int) string {
+	return fmt.Sprintf("%s.%d", f.path, n)
+}
+
+// writeFileAtomic 通过临时文件、fsync 和重命名原子地写入文件
+// 任何时刻崩溃，目标文件要么是旧内容，要么是完整的新内容
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// syncDir 刷新目录项，确保重命名操作落盘
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	// 部分平台（如 Windows）不支持对目录 fsync，忽略该错误
+	d.Sync()
+	return nil
+}
+
+// importLegacyJSON 将旧版本的 clients.json 和 user.json 导入数据库
+// 导入成功后文件被重命名为 *.imported，因此只会在升级后第一次启动时执行
+// 文件损坏且没有可用的备份时返回错误，避免跳过导入后以默认用户启动
+func importLegacyJSON(store Store, dir string) error {
+	clientsPath := filepath.Join(dir, clientsFile)
+	var clients map[string]*Client
+	err := newJSONFile(clientsPath, legacyBackups).load(&clients)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("%w，请修复或移走该文件后重新启动", err)
+	default:
+		list := make([]*Client, 0, len(clients))
+		for id, client := range clients {
+			if client == nil {
+				continue
+			}
+			client.ID = id
+			client.Connected = false
+			list = append(list, client)
+		}
+		if err := store.SaveClients(list...); err != nil {
+			return fmt.Errorf("导入客户端数据失败: %w", err)
+		}
+		if err := os.Rename(clientsPath, clientsPath+".imported"); err != nil {
+			return err
+		}
+		log.Printf("已从 %s 导入 %d 个客户端", clientsPath, len(list))
+	}
+
+	userPath := filepath.Join(dir, userFile)
+	var user legacyUser
+	err = newJSONFile(userPath, legacyBackups).load(&user)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("%w，请修复或移走该文件后重新启动", err)
+	case user.Username == "":
+		return fmt.Errorf("旧版用户文件 %s 中没有用户名，请修复或移走该文件后重新启动", userPath)
+	default:
+		if err := store.SaveUser(user.hashed()); err != nil {
+			return fmt.Errorf("导入用户数据失败: %w", err)
+		}
+		if err := os.Rename(userPath, userPath+".imported"); err != nil {
+			return err
+		}
+		log.Printf("已从 %s 导入用户 %s", userPath, user.Username)
+	}
+	return nil
+}
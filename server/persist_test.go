@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "user.json")
+	os.WriteFile(path, []byte("old"), 0644)
+
+	if err := writeFileAtomic(path, []byte("new"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "new" {
+		t.Fatalf("文件内容为 %q", data)
+	}
+	if info, _ := os.Stat(path); runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("文件权限为 %v", info.Mode().Perm())
+	}
+	// 临时文件不应残留
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("目录中有 %d 个文件", len(entries))
+	}
+
+	if err := writeFileAtomic(filepath.Join(dir, "missing", "x.json"), []byte("x"), 0644); err == nil {
+		t.Fatal("目录不存在时应返回错误")
+	}
+}
+
+func TestJSONFileLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "clients.json")
+	f := newJSONFile(path, 3)
+	var v map[string]int
+
+	if err := f.load(&v); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("文件不存在时返回 %v", err)
+	}
+
+	// 跳过损坏的备份，从最新的有效备份恢复并写回
+	os.WriteFile(path, []byte("{broken"), 0644)
+	os.WriteFile(f.backupPath(1), []byte("also broken"), 0644)
+	os.WriteFile(f.backupPath(2), []byte(`{"a": 2}`), 0644)
+	os.WriteFile(f.backupPath(3), []byte(`{"a": 3}`), 0644)
+	if err := f.load(&v); err != nil || v["a"] != 2 {
+		t.Fatalf("恢复的数据为 %v, %v", v, err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != `{"a": 2}` {
+		t.Fatalf("写回的文件为 %q", data)
+	}
+	corrupt, _ := filepath.Glob(path + ".corrupt-*")
+	if len(corrupt) != 1 {
+		t.Fatalf("保留的损坏文件为 %v", corrupt)
+	}
+	if data, _ := os.ReadFile(corrupt[0]); string(data) != "{broken" {
+		t.Fatalf("损坏文件的内容为 %q", data)
+	}
+
+	// 没有可用的备份时返回错误并保持文件不变
+	os.WriteFile(path, []byte("{broken"), 0644)
+	f = newJSONFile(path, 1)
+	if err := f.load(&v); err == nil {
+		t.Fatal("没有可用的备份时应返回错误")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "{broken" {
+		t.Fatalf("损坏的文件被修改为 %q", data)
+	}
+}
+
+func TestImportLegacyJSON(t *testing.T) {
+	dir := t.TempDir()
+	st, err := openBoltStore(filepath.Join(dir, dbFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	os.WriteFile(filepath.Join(dir, clientsFile), []byte(`{"A1": {"name": "web", "connected": true}}`), 0644)
+	os.WriteFile(filepath.Join(dir, userFile), []byte(`{"username": "root", "password": "secret"}`), 0644)
+	if err := importLegacyJSON(st, dir); err != nil {
+		t.Fatal(err)
+	}
+	clients, err := st.ListClients()
+	if err != nil || len(clients) != 1 || clients[0].ID != "A1" || clients[0].Connected {
+		t.Fatalf("导入的客户端为 %+v, %v", clients, err)
+	}
+	if user, err := st.GetUser("root"); err != nil || !checkPassword("secret", user.PasswordHash) {
+		t.Fatalf("导入的用户为 %+v, %v", user, err)
+	}
+	for _, name := range []string{clientsFile, userFile} {
+		if _, err := os.Stat(filepath.Join(dir, name+".imported")); err != nil {
+			t.Errorf("%s 没有被重命名: %v", name, err)
+		}
+	}
+	// 再次启动时没有需要导入的文件
+	if err := importLegacyJSON(st, dir); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImportLegacyJSONCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	st, err := openBoltStore(filepath.Join(dir, dbFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	// 用户文件损坏时拒绝启动，不能以默认用户继续运行
+	path := filepath.Join(dir, userFile)
+	os.WriteFile(path, []byte(`{"username": "root"`), 0644)
+	if err := importLegacyJSON(st, dir); err == nil {
+		t.Fatal("用户文件损坏时应返回错误")
+	}
+	if data, _ := os.ReadFile(path); string(data) != `{"username": "root"` {
+		t.Fatalf("损坏的用户文件被修改为 %q", data)
+	}
+	if users, err := st.ListUsers(); err != nil || len(users) != 0 {
+		t.Fatalf("数据库中的用户为 %v, %v", users, err)
+	}
+
+	// 从备份恢复后可以导入
+	os.WriteFile(path+".1", []byte(`{"username": "root", "password": "secret"}`), 0644)
+	if err := importLegacyJSON(st, dir); err != nil {
+		t.Fatal(err)
+	}
+	if user, err := st.GetUser("root"); err != nil || !checkPassword("secret", user.PasswordHash) {
+		t.Fatalf("导入的用户为 %+v, %v", user, err)
+	}
+}
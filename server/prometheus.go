@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// clientGauge 描述一个以 Prometheus 文本格式导出的客户端指标
+type clientGauge struct {
+	name  string
+	help  string
+	value func(c *Client) float64
+}
+
+// clientGauges 导出的客户端指标，除 gonitor_client_up 外只导出在线客户端
+var clientGauges = []clientGauge{
+	{"gonitor_client_up", "客户端是否在线", func(c *Client) float64 {
+		if c.Connected {
+			return 1
+		}
+		return 0
+	}},
+	{"gonitor_client_cpu_percent", "CPU 使用率", func(c *Client) float64 { return c.CPU }},
+	{"gonitor_client_memory_percent", "内存使用率", func(c *Client) float64 { return c.Memory }},
+	{"gonitor_client_disk_percent", "硬盘使用率", func(c *Client) float64 { return c.DiskUsage }},
+	{"gonitor_client_disk_read_kbps", "磁盘读取速度 (KB/s)", func(c *Client) float64 { return c.DiskReadSpeed }},
+	{"gonitor_client_disk_write_kbps", "磁盘写入速度 (KB/s)", func(c *Client) float64 { return c.DiskWriteSpeed }},
+	{"gonitor_client_upload_kbps", "上传网速 (KB/s)", func(c *Client) float64 { return c.UploadSpeed }},
+	{"gonitor_client_download_kbps", "下载网速 (KB/s)", func(c *Client) float64 { return c.DownloadSpeed }},
+}
+
+// promLabels 生成客户端的 Prometheus 标签：id、name、group 以及客户端的全部标签
+func promLabels(c *Client) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `id="%s",name="%s"`, promEscape(c.ID), promEscape(c.Name))
+	if c.Group != "" {
+		fmt.Fprintf(&b, `,group="%s"`, promEscape(c.Group))
+	}
+	labels := c.allLabels()
+	for _, name := range slices.Sorted(maps.Keys(labels)) {
+		fmt.Fprintf(&b, `,%s="%s"`, name, promEscape(labels[name]))
+	}
+	return b.String()
+}
+
+// promEscape 按 Prometheus 文本格式转义标签值
+func promEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// handleMetrics 以 Prometheus 文本格式导出客户端的当前指标，支持与 /api/clients 相同的筛选参数
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAuth(r) {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	query, err := parseClientQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	clientList := slices.DeleteFunc(s.clients.Snapshot().List(), func(c Client) bool {
+		return !query.matches(&c)
+	})
+	labels := make([]string, len(clientList))
+	for i := range clientList {
+		labels[i] = promLabels(&clientList[i])
+	}
+
+	var buf bytes.Buffer
+	for i, g := range clientGauges {
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s gauge\n", g.name, g.help, g.name)
+		for j := range clientList {
+			c := &clientList[j]
+			if i > 0 && !c.Connected {
+				continue
+			}
+			fmt.Fprintf(&buf, "%s{%s} %s\n", g.name, labels[j], formatFloat(g.value(c)))
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Server 包含服务端运行所需的全部状态，HTTP 处理器都是它的方法
+type Server struct {
+	store    Store
+	clients  *ClientDB
+	history  *metricHistory
+	monitors *monitorRunner
+	alerts   *alertEngine
+	web      *staticFS
+	upgrader websocket.Upgrader
+
+	// statusCache 缓存公开状态页的数据
+	statusCache statusPageCache
+
+	// logins 记录登录失败并锁定暴力破解的来源
+	logins *loginLimiter
+	// trustProxy 为 true 时从 X-Forwarded-For 读取来源 IP，只应在服务端位于反向代理之后时开启
+	trustProxy bool
+
+	// 心跳参数
+	pingInterval time.Duration
+	pongTimeout  time.Duration
+
+	// agentConns 跟踪正在运行的客户端消息处理协程，关闭服务器时等待其退出
+	agentConns sync.WaitGroup
+}
+
+// newServer 使用给定的存储和网页资源创建服务端，并加载已保存的客户端
+func newServer(store Store, web *staticFS) (*Server, error) {
+	clients, err := store.ListClients()
+	if err != nil {
+		return nil, fmt.Errorf("加载客户端数据出错: %w", err)
+	}
+	monitors, err := store.ListMonitors()
+	if err != nil {
+		return nil, fmt.Errorf("加载服务端探测出错: %w", err)
+	}
+	s := &Server{
+		store:    store,
+		clients:  newClientDB(store, clients),
+		history:  newMetricHistory(store),
+		monitors: newMonitorRunner(store, monitors),
+		alerts:   newAlertEngine(),
+		web:      web,
+		logins:   newLoginLimiter(),
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin: func(r *http.Request) bool {
+				return true // 允许所有跨域请求，生产环境应该更严格
+			},
+		},
+		pingInterval: 10 * time.Second,
+		pongTimeout:  30 * time.Second,
+	}
+	if err := s.ensureDefaultUser(); err != nil {
+		s.clients.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Handler 返回包含所有页面、API 和 WebSocket 路由的处理器
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	// 设置静态文件服务
+	mux.Handle("/assets/", s.web.assetsHandler())
+
+	// API 路由
+	mux.HandleFunc("/api/login", s.handleLogin)
+	mux.HandleFunc("/api/logout", s.handleLogout)
+	mux.HandleFunc("/api/change-password", s.handleChangePassword)
+	mux.HandleFunc("/api/clients", s.handleGetClients)
+	mux.HandleFunc("/api/clients/add", s.handleAddClient)
+	mux.HandleFunc("/api/clients/delete", s.handleDeleteClient)
+	mux.HandleFunc("/api/clients/reorder", s.handleReorderClients)
+	mux.HandleFunc("/api/clients/rename", s.handleRenameClient)
+	mux.HandleFunc("/api/clients/public", s.handleSetClientPublic)
+	mux.HandleFunc("/api/clients/group", s.handleSetClientGroup)
+	mux.HandleFunc("/api/clients/tags", s.handleUpdateClientTags)
+	mux.HandleFunc("/api/groups", s.handleGetGroups)
+	mux.HandleFunc("/api/clients/export", s.handleExportClients)
+	mux.HandleFunc("/api/clients/import", s.handleImportClients)
+	mux.HandleFunc("/api/clients/series", s.handleClientSeries)
+	mux.HandleFunc("/api/clients/history", s.handleClientHistory)
+	mux.HandleFunc("/api/clients/checks", s.handleClientChecks)
+	mux.HandleFunc("/api/checks/results", s.handleCheckResults)
+	mux.HandleFunc("/api/monitors", s.handleGetMonitors)
+	mux.HandleFunc("/api/monitors/save", s.handleSaveMonitor)
+	mux.HandleFunc("/api/monitors/delete", s.handleDeleteMonitor)
+	mux.HandleFunc("/api/monitors/status", s.handleMonitorStatus)
+	mux.HandleFunc("/api/alerts/rules", s.handleGetAlertRules)
+	mux.HandleFunc("/api/alerts/rules/save", s.handleSaveAlertRule)
+	mux.HandleFunc("/api/alerts/rules/delete", s.handleDeleteAlertRule)
+	mux.HandleFunc("/api/alerts/events", s.handleGetAlertEvents)
+	mux.HandleFunc("/api/maintenances", s.handleGetMaintenances)
+	mux.HandleFunc("/api/maintenances/save", s.handleSaveMaintenance)
+	mux.HandleFunc("/api/maintenances/delete", s.handleDeleteMaintenance)
+	mux.HandleFunc("/api/incidents", s.handleGetIncidents)
+	mux.HandleFunc("/api/incidents/create", s.handleCreateIncident)
+	mux.HandleFunc("/api/incidents/update", s.handleUpdateIncident)
+	mux.HandleFunc("/api/incidents/delete", s.handleDeleteIncident)
+	mux.HandleFunc("/api/enroll-tokens", s.handleGetEnrollTokens)
+	mux.HandleFunc("/api/enroll-tokens/create", s.handleCreateEnrollToken)
+	mux.HandleFunc("/api/enroll-tokens/delete", s.handleDeleteEnrollToken)
+	mux.HandleFunc("/api/enroll", s.handleEnroll)
+	mux.HandleFunc("/api/tokens", s.handleGetAPITokens)
+	mux.HandleFunc("/api/tokens/create", s.handleCreateAPIToken)
+	mux.HandleFunc("/api/tokens/delete", s.handleDeleteAPIToken)
+	mux.HandleFunc("/api/backup", s.handleBackup)
+	mux.HandleFunc("/api/auth/events", s.handleGetAuthEvents)
+	mux.HandleFunc("/api/auth/unlock", s.handleUnlockLogin)
+
+	mux.HandleFunc("/api/status-page", s.handleGetStatusPage)
+	mux.HandleFunc("/api/status-page/save", s.handleSaveStatusPage)
+
+	// 资源风格的 v1 接口，上面的旧接口中有对应资源的已标记为弃用
+	s.registerV1(mux)
+
+	// Prometheus 文本格式的客户端指标
+	mux.HandleFunc("/metrics", s.handleMetrics)
+
+	// 公开状态页，只提供管理员选择公开的内容，不经过管理接口
+	mux.HandleFunc("/status", s.handleStatusPage)
+	mux.HandleFunc("/status/data", s.handleStatusData)
+
+	// 公开的徽章，只有设置为公开的客户端可用
+	mux.HandleFunc("/badge/", s.handleBadge)
+
+	// WebSocket 路由处理客户端连接
+	mux.HandleFunc("/ws", s.handleClientConnection)
+
+	// 网页路由
+	mux.HandleFunc("/", s.handleIndex)
+	return mux
+}
+
+// Close 通知所有客户端断开并等待消息处理结束，然后保存客户端的最终状态
+// 调用前应先停止 HTTP 服务，避免新的客户端连接进来，并取消传给 monitors.Start 的 ctx；
+// 存储由调用方负责关闭
+func (s *Server) Close(ctx context.Context) {
+	// 向所有客户端发送关闭帧，客户端收到后会尽快重连
+	s.closeAgentConns(websocket.CloseGoingAway, "服务器正在关闭")
+
+	// 等待所有消息处理协程退出
+	done := make(chan struct{})
+	go func() {
+		s.agentConns.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Println("等待客户端连接关闭超时")
+	}
+
+	// 等待正在进行的服务端探测保存结果
+	s.monitors.Wait()
+
+	// 保存所有客户端的最终状态和尚未写入的历史指标
+	s.history.Flush(time.Now(), true)
+	s.clients.Close()
+}
+
+// closeAgentConns 向所有客户端连接发送关闭帧并关闭连接
+func (s *Server) closeAgentConns(code int, text string) {
+	msg := websocket.FormatCloseMessage(code, text)
+	for _, conn := range s.clients.Conns() {
+		conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+		conn.Close()
+	}
+}
+
+// ensureDefaultUser 数据库中没有任何用户时创建默认管理员
+func (s *Server) ensureDefaultUser() error {
+	users, err := s.store.ListUsers()
+	if err != nil {
+		return fmt.Errorf("加载用户数据出错: %w", err)
+	}
+	if len(users) > 0 {
+		return nil
+	}
+	if err := s.store.SaveUser(User{Username: "admin", PasswordHash: hashPassword("admin")}); err != nil {
+		return fmt.Errorf("创建默认用户出错: %w", err)
+	}
+	log.Println("已创建默认用户 admin，请登录后尽快修改密码")
+	return nil
+}
+
+// purgeExpiredSessions 定期删除过期的会话
+func (s *Server) purgeExpiredSessions(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		if _, err := s.store.DeleteExpiredSessions(time.Now()); err != nil {
+			log.Printf("清理过期会话出错: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// currentSession 返回请求携带的有效会话
+func (s *Server) currentSession(r *http.Request) (Session, bool) {
+	cookie, err := r.Cookie("session")
+	if err != nil || cookie.Value == "" {
+		return Session{}, false
+	}
+	session, err := s.store.GetSession(cookie.Value)
+	if err != nil {
+		if !errors.Is(err, ErrNotFound) {
+			log.Printf("读取会话出错: %v", err)
+		}
+		return Session{}, false
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return Session{}, false
+	}
+	return session, true
+}
+
+// checkAuth 检查用户是否已登录，或者请求携带了有权限的个人令牌
+func (s *Server) checkAuth(r *http.Request) bool {
+	return s.authorize(r) == nil
+}
+
+// newID 生成一个随机ID，用作告警规则、服务端探测等记录的键
+func newID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// newToken 生成一个随机令牌
+func newToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
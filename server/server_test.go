@@ -0,0 +1,504 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newTestServer 使用临时数据库创建服务端并启动测试 HTTP 服务器
+func newTestServer(t *testing.T) (*Server, *httptest.Server) {
+	t.Helper()
+	st, err := openBoltStore(filepath.Join(t.TempDir(), dbFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	web, err := newStaticFS("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := newServer(st, web)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(server.Handler())
+	t.Cleanup(func() {
+		ts.Close()
+		server.Close(context.Background())
+		st.Close()
+	})
+	return server, ts
+}
+
+// loginClient 返回一个已登录的 HTTP 客户端
+func loginClient(t *testing.T, ts *httptest.Server) *http.Client {
+	t.Helper()
+	c := newCookieClient()
+	resp, err := postJSON(c, ts.URL+"/api/login", map[string]string{"username": "admin", "password": "admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("登录失败: %s", resp.Status)
+	}
+	return c
+}
+
+func newCookieClient() *http.Client {
+	jar, _ := cookiejar.New(nil)
+	return &http.Client{Jar: jar}
+}
+
+func postJSON(c *http.Client, url string, v any) (*http.Response, error) {
+	data, _ := json.Marshal(v)
+	return c.Post(url, "application/json", bytes.NewReader(data))
+}
+
+// mustPost 发送请求并检查状态码，返回解析后的 JSON 响应
+func mustPost(t *testing.T, c *http.Client, url string, v any, wantStatus int) map[string]string {
+	t.Helper()
+	resp, err := postJSON(c, url, v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != wantStatus {
+		t.Fatalf("POST %s 返回 %s，期望 %d", url, resp.Status, wantStatus)
+	}
+	var result map[string]string
+	json.NewDecoder(resp.Body).Decode(&result)
+	return result
+}
+
+// mustGet 以 GET 请求读取 JSON 响应，状态码不是 200 时测试失败
+func mustGet(t *testing.T, c *http.Client, url string, v any) {
+	t.Helper()
+	resp, err := c.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s 返回 %s", url, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func getClients(t *testing.T, c *http.Client, url string) []Client {
+	t.Helper()
+	resp, err := c.Get(url + "/api/clients")
+	if err != nil {
+		t.Error(err)
+		return nil
+	}
+	defer resp.Body.Close()
+	var clients []Client
+	if err := json.NewDecoder(resp.Body).Decode(&clients); err != nil {
+		t.Error(err)
+	}
+	return clients
+}
+
+// addClient 通过 API 添加客户端并返回其ID
+func addClient(t *testing.T, c *http.Client, ts *httptest.Server, name string) string {
+	t.Helper()
+	id := mustPost(t, c, ts.URL+"/api/clients/add", map[string]string{"name": name}, http.StatusOK)["id"]
+	if id == "" {
+		t.Fatal("添加客户端没有返回ID")
+	}
+	return id
+}
+
+// dialAgent 模拟客户端通过 /ws 连接服务器
+func dialAgent(t *testing.T, ts *httptest.Server, id string) *websocket.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws?id=" + id
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// waitFor 在超时前反复检查条件是否成立
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待超时: %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func findClient(clients []Client, name string) (Client, bool) {
+	for _, c := range clients {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return Client{}, false
+}
+
+func TestLogin(t *testing.T) {
+	_, ts := newTestServer(t)
+
+	c := newCookieClient()
+	mustPost(t, c, ts.URL+"/api/login", map[string]string{"username": "admin", "password": "wrong"}, http.StatusUnauthorized)
+	mustPost(t, c, ts.URL+"/api/login", map[string]string{"username": "nobody", "password": "admin"}, http.StatusUnauthorized)
+
+	// 伪造的会话不能通过认证
+	forged := &http.Client{}
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/api/clients/add", strings.NewReader(`{"name":"x"}`))
+	req.AddCookie(&http.Cookie{Name: "session", Value: "session_123"})
+	resp, err := forged.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("伪造会话返回 %s，期望 401", resp.Status)
+	}
+
+	admin := loginClient(t, ts)
+	addClient(t, admin, ts, "a")
+
+	// 登出后会话失效
+	mustPost(t, admin, ts.URL+"/api/logout", nil, http.StatusOK)
+	mustPost(t, admin, ts.URL+"/api/clients/add", map[string]string{"name": "b"}, http.StatusUnauthorized)
+}
+
+func TestChangePassword(t *testing.T) {
+	_, ts := newTestServer(t)
+	admin := loginClient(t, ts)
+
+	mustPost(t, admin, ts.URL+"/api/change-password", map[string]string{
+		"username": "root", "oldPassword": "wrong", "newPassword": "secret",
+	}, http.StatusUnauthorized)
+	mustPost(t, admin, ts.URL+"/api/change-password", map[string]string{
+		"username": "root", "oldPassword": "admin", "newPassword": "secret",
+	}, http.StatusOK)
+
+	// 当前会话仍然有效
+	addClient(t, admin, ts, "a")
+
+	c := newCookieClient()
+	mustPost(t, c, ts.URL+"/api/login", map[string]string{"username": "admin", "password": "admin"}, http.StatusUnauthorized)
+	mustPost(t, c, ts.URL+"/api/login", map[string]string{"username": "root", "password": "secret"}, http.StatusOK)
+}
+
+func TestClientCRUD(t *testing.T) {
+	server, ts := newTestServer(t)
+	anonymous := &http.Client{}
+	mustPost(t, anonymous, ts.URL+"/api/clients/add", map[string]string{"name": "a"}, http.StatusUnauthorized)
+
+	admin := loginClient(t, ts)
+	idA := addClient(t, admin, ts, "a")
+	idB := addClient(t, admin, ts, "b")
+
+	clients := getClients(t, admin, ts.URL)
+	if len(clients) != 2 || clients[0].ID != idA || clients[1].ID != idB {
+		t.Fatalf("客户端列表不正确: %+v", clients)
+	}
+
+	// 未登录用户看不到任何客户端
+	if clients := getClients(t, anonymous, ts.URL); len(clients) != 0 {
+		t.Fatalf("未登录用户看到了客户端: %+v", clients)
+	}
+
+	mustPost(t, anonymous, ts.URL+"/api/clients/delete", map[string]string{"id": idA}, http.StatusUnauthorized)
+	mustPost(t, admin, ts.URL+"/api/clients/delete", map[string]string{"id": idA}, http.StatusOK)
+	clients = getClients(t, admin, ts.URL)
+	if len(clients) != 1 || clients[0].ID != idB {
+		t.Fatalf("删除后客户端列表不正确: %+v", clients)
+	}
+
+	saved, err := server.store.ListClients()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 1 || saved[0].ID != idB {
+		t.Fatalf("数据库中的客户端不正确: %+v", saved)
+	}
+}
+
+func TestReorderClients(t *testing.T) {
+	_, ts := newTestServer(t)
+	admin := loginClient(t, ts)
+	idA := addClient(t, admin, ts, "a")
+	idB := addClient(t, admin, ts, "b")
+	idC := addClient(t, admin, ts, "c")
+
+	mustPost(t, &http.Client{}, ts.URL+"/api/clients/reorder", map[string]any{"orders": map[string]int{idA: 3}}, http.StatusUnauthorized)
+	mustPost(t, admin, ts.URL+"/api/clients/reorder", map[string]any{
+		"orders": map[string]int{idA: 3, idB: 1, idC: 2, "missing": 0},
+	}, http.StatusOK)
+
+	var names []string
+	for _, c := range getClients(t, admin, ts.URL) {
+		names = append(names, c.Name)
+	}
+	if strings.Join(names, ",") != "b,c,a" {
+		t.Fatalf("排序结果为 %v，期望 b,c,a", names)
+	}
+}
+
+func TestRenameClient(t *testing.T) {
+	_, ts := newTestServer(t)
+	admin := loginClient(t, ts)
+	id := addClient(t, admin, ts, "a")
+
+	mustPost(t, &http.Client{}, ts.URL+"/api/clients/rename", map[string]string{"id": id, "name": "b"}, http.StatusUnauthorized)
+	mustPost(t, admin, ts.URL+"/api/clients/rename", map[string]string{"id": "", "name": "b"}, http.StatusBadRequest)
+	mustPost(t, admin, ts.URL+"/api/clients/rename", map[string]string{"id": id, "name": ""}, http.StatusBadRequest)
+	mustPost(t, admin, ts.URL+"/api/clients/rename", map[string]string{"id": "missing", "name": "b"}, http.StatusNotFound)
+	mustPost(t, admin, ts.URL+"/api/clients/rename", map[string]string{"id": id, "name": "b"}, http.StatusOK)
+
+	if _, ok := findClient(getClients(t, admin, ts.URL), "b"); !ok {
+		t.Fatal("重命名没有生效")
+	}
+}
+
+func TestAgentMetricsPropagation(t *testing.T) {
+	_, ts := newTestServer(t)
+	admin := loginClient(t, ts)
+	id := addClient(t, admin, ts, "a")
+
+	conn := dialAgent(t, ts, id)
+	want := Metrics{CPU: 12.5, Memory: 40, DiskUsage: 70, DiskReadSpeed: 1, DiskWriteSpeed: 2, UploadSpeed: 3, DownloadSpeed: 4,
+		Samples: []Sample{{Name: "queueDepth", Value: 7, Labels: map[string]string{"queue": "mail"}}}}
+	if err := conn.WriteJSON(want); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "指标更新", func() bool {
+		c, _ := findClient(getClients(t, admin, ts.URL), "a")
+		return c.Connected && c.CPU == want.CPU
+	})
+	c, _ := findClient(getClients(t, admin, ts.URL), "a")
+	got := c.metrics()
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("指标为 %+v，期望 %+v", got, want)
+	}
+	if c.LastSeen.IsZero() {
+		t.Fatal("LastSeen 没有更新")
+	}
+}
+
+func TestAgentSamplesOnlySentOnChange(t *testing.T) {
+	_, ts := newTestServer(t)
+	admin := loginClient(t, ts)
+	id := addClient(t, admin, ts, "a")
+	conn := dialAgent(t, ts, id)
+
+	samples := []Sample{{Name: "up", Value: 1, Labels: map[string]string{"job": "node"}}}
+	conn.WriteJSON(Metrics{CPU: 1, Samples: samples})
+	// samples 为 null 时沿用上一次的值
+	conn.WriteJSON(map[string]any{"cpu": 2, "samples": nil})
+	waitFor(t, "第二帧", func() bool {
+		c, _ := findClient(getClients(t, admin, ts.URL), "a")
+		return c.CPU == 2
+	})
+	c, _ := findClient(getClients(t, admin, ts.URL), "a")
+	if !reflect.DeepEqual(c.Samples, samples) {
+		t.Fatalf("其他指标为 %+v，期望沿用 %+v", c.Samples, samples)
+	}
+
+	// 空数组表示已经没有其他指标
+	conn.WriteJSON(map[string]any{"cpu": 3, "samples": []Sample{}})
+	waitFor(t, "其他指标清空", func() bool {
+		c, _ := findClient(getClients(t, admin, ts.URL), "a")
+		return c.CPU == 3 && len(c.Samples) == 0
+	})
+}
+
+func TestAgentOfflineOnDisconnect(t *testing.T) {
+	_, ts := newTestServer(t)
+	admin := loginClient(t, ts)
+	id := addClient(t, admin, ts, "a")
+
+	conn := dialAgent(t, ts, id)
+	conn.WriteJSON(Metrics{CPU: 50})
+	waitFor(t, "客户端上线", func() bool {
+		c, _ := findClient(getClients(t, admin, ts.URL), "a")
+		return c.Connected && c.CPU == 50
+	})
+
+	conn.Close()
+	waitFor(t, "客户端离线", func() bool {
+		c, _ := findClient(getClients(t, admin, ts.URL), "a")
+		return !c.Connected && c.CPU == 0
+	})
+}
+
+func TestAgentOfflineOnHeartbeatLoss(t *testing.T) {
+	server, ts := newTestServer(t)
+	server.pingInterval = 50 * time.Millisecond
+	server.pongTimeout = 200 * time.Millisecond
+	admin := loginClient(t, ts)
+	id := addClient(t, admin, ts, "a")
+
+	// 客户端不读取消息，因此不会回应心跳，模拟半开连接
+	conn := dialAgent(t, ts, id)
+	conn.WriteJSON(Metrics{CPU: 50})
+	waitFor(t, "客户端上线", func() bool {
+		c, _ := findClient(getClients(t, admin, ts.URL), "a")
+		return c.Connected
+	})
+	waitFor(t, "心跳超时后离线", func() bool {
+		c, _ := findClient(getClients(t, admin, ts.URL), "a")
+		return !c.Connected
+	})
+
+	// 服务端同时关闭了连接
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				t.Fatal("心跳超时后服务端没有关闭连接")
+			}
+			break
+		}
+	}
+}
+
+func TestAgentStaysOnlineWhileAnsweringPings(t *testing.T) {
+	server, ts := newTestServer(t)
+	server.pingInterval = 50 * time.Millisecond
+	server.pongTimeout = 200 * time.Millisecond
+	admin := loginClient(t, ts)
+	id := addClient(t, admin, ts, "a")
+
+	// 客户端只发送一次数据，之后仅由读取时自动回应的心跳维持连接
+	conn := dialAgent(t, ts, id)
+	closed := make(chan error, 1)
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				closed <- err
+				return
+			}
+		}
+	}()
+	conn.WriteJSON(Metrics{CPU: 50})
+	waitFor(t, "客户端上线", func() bool {
+		c, _ := server.clients.Snapshot().Get(id)
+		return c.Connected
+	})
+
+	select {
+	case err := <-closed:
+		t.Fatalf("回应心跳的连接被关闭: %v", err)
+	case <-time.After(5 * server.pongTimeout):
+	}
+	if c, _ := server.clients.Snapshot().Get(id); !c.Connected {
+		t.Fatal("回应心跳的客户端被判定为离线")
+	}
+}
+
+func TestAgentUnknownIDRejected(t *testing.T) {
+	_, ts := newTestServer(t)
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws?id=missing"
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err == nil {
+		t.Fatal("未注册的客户端不应连接成功")
+	}
+	if resp == nil || resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("未注册的客户端应返回 400，实际为 %v", resp)
+	}
+}
+
+func TestServerCloseSendsGoingAway(t *testing.T) {
+	server, ts := newTestServer(t)
+	admin := loginClient(t, ts)
+	id := addClient(t, admin, ts, "a")
+
+	conn := dialAgent(t, ts, id)
+	waitFor(t, "客户端上线", func() bool {
+		c, _ := server.clients.Snapshot().Get(id)
+		return c.Connected
+	})
+
+	go server.Close(context.Background())
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	_, _, err := conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Fatalf("期望收到 going away 关闭帧，实际为 %v", err)
+	}
+}
+
+func TestServerCloseSavesStateBeforeStoreClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), dbFile)
+	st, err := openBoltStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	web, err := newStaticFS("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := newServer(st, web)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+	admin := loginClient(t, ts)
+	id := addClient(t, admin, ts, "a")
+
+	conn := dialAgent(t, ts, id)
+	conn.WriteJSON(Metrics{CPU: 50})
+	waitFor(t, "客户端上线", func() bool {
+		c, _ := server.clients.Snapshot().Get(id)
+		return c.Connected && c.CPU == 50
+	})
+
+	// 与 main 中的顺序相同：Close 返回后才关闭数据库
+	closeFrame := make(chan error, 1)
+	go func() {
+		conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+		_, _, err := conn.ReadMessage()
+		closeFrame <- err
+	}()
+	server.Close(context.Background())
+	if err := <-closeFrame; !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Fatalf("期望收到 going away 关闭帧，实际为 %v", err)
+	}
+	if err := st.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err = openBoltStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	clients, err := st.ListClients()
+	if err != nil || len(clients) != 1 {
+		t.Fatalf("保存的客户端为 %v, %v", clients, err)
+	}
+	if c := clients[0]; c.Connected || c.LastSeen.IsZero() {
+		t.Fatalf("关闭后保存的客户端状态为 %+v", c)
+	}
+	// 尚未到整点的历史指标也已写入
+	if series, err := st.ListMetricSeries(id); err != nil || len(series) == 0 {
+		t.Fatalf("保存的历史指标为 %v, %v", series, err)
+	}
+}
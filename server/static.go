@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// embeddedWeb 编译进二进制的网页资源，发布构建前由 go generate 生成预压缩的 .br 和 .gz
+//
+//go:generate sh compress-assets.sh
+//go:embed assets templates
+var embeddedWeb embed.FS
+
+// 可压缩的内容类型前缀
+var compressibleTypes = []string{
+	"text/",
+	"application/javascript",
+	"application/json",
+	"application/manifest+json",
+	"image/svg+xml",
+}
+
+// staticFile 表示一个可直接响应的静态文件及其预压缩版本
+type staticFile struct {
+	contentType string
+	modTime     time.Time
+	etag        string
+	data        []byte
+	gzip        []byte // 可能为空
+	brotli      []byte // 仅当存在预先生成的 .br 文件时非空
+}
+
+// staticFS 从 fs.FS 提供网页资源
+// 使用内嵌资源时文件在启动时全部加载并预压缩；开发模式下每次请求都从磁盘读取
+type staticFS struct {
+	fsys  fs.FS
+	dev   bool
+	files map[string]*staticFile
+	start time.Time
+}
+
+// newStaticFS 创建静态资源服务，dir 为空时使用内嵌资源
+func newStaticFS(dir string) (*staticFS, error) {
+	s := &staticFS{start: time.Now()}
+	if dir != "" {
+		s.fsys = os.DirFS(dir)
+		s.dev = true
+		return s, nil
+	}
+
+	s.fsys = embeddedWeb
+	s.files = make(map[string]*staticFile)
+	err := fs.WalkDir(s.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(name, ".gz") || strings.HasSuffix(name, ".br") {
+			return nil
+		}
+		f, err := s.load(name)
+		if err != nil {
+			return err
+		}
+		s.files[name] = f
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load 读取文件并计算 ETag 和压缩版本
+func (s *staticFS) load(name string) (*staticFile, error) {
+	data, err := fs.ReadFile(s.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	f := &staticFile{
+		contentType: mime.TypeByExtension(path.Ext(name)),
+		modTime:     s.start,
+		etag:        `"` + hex.EncodeToString(sum[:8]) + `"`,
+		data:        data,
+	}
+	if f.contentType == "" {
+		f.contentType = http.DetectContentType(data)
+	}
+	if info, err := fs.Stat(s.fsys, name); err == nil && !info.ModTime().IsZero() {
+		f.modTime = info.ModTime()
+	}
+
+	// 优先使用构建时预先生成的压缩文件
+	if br, err := fs.ReadFile(s.fsys, name+".br"); err == nil {
+		f.brotli = br
+	}
+	if gz, err := fs.ReadFile(s.fsys, name+".gz"); err == nil {
+		f.gzip = gz
+	} else if !s.dev && isCompressible(f.contentType) {
+		var buf bytes.Buffer
+		zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		zw.Write(data)
+		zw.Close()
+		if buf.Len() < len(data) {
+			f.gzip = buf.Bytes()
+		}
+	}
+	return f, nil
+}
+
+// open 获取指定文件，开发模式下每次重新读取
+func (s *staticFS) open(name string) (*staticFile, bool) {
+	if !s.dev {
+		f, ok := s.files[name]
+		return f, ok
+	}
+	info, err := fs.Stat(s.fsys, name)
+	if err != nil || info.IsDir() {
+		return nil, false
+	}
+	f, err := s.load(name)
+	if err != nil {
+		log.Printf("读取静态文件 %s 失败: %v", name, err)
+		return nil, false
+	}
+	return f, true
+}
+
+// serve 响应一个静态文件，处理缓存校验和压缩协商
+func (s *staticFS) serve(w http.ResponseWriter, r *http.Request, name, cacheControl string) {
+	f, ok := s.open(name)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", f.contentType)
+	h.Set("Vary", "Accept-Encoding")
+	if s.dev {
+		h.Set("Cache-Control", "no-cache")
+	} else {
+		h.Set("Cache-Control", cacheControl)
+	}
+
+	body, encoding := f.data, ""
+	accept := r.Header.Get("Accept-Encoding")
+	if f.brotli != nil && acceptsEncoding(accept, "br") {
+		body, encoding = f.brotli, "br"
+	} else if f.gzip != nil && acceptsEncoding(accept, "gzip") {
+		body, encoding = f.gzip, "gzip"
+	}
+
+	if encoding == "" {
+		h.Set("ETag", f.etag)
+		http.ServeContent(w, r, name, f.modTime, bytes.NewReader(body))
+		return
+	}
+
+	// 不同编码使用不同的 ETag，避免中间缓存混用
+	etag := strings.TrimSuffix(f.etag, `"`) + "-" + encoding + `"`
+	h.Set("ETag", etag)
+	h.Set("Last-Modified", f.modTime.UTC().Format(http.TimeFormat))
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	h.Set("Content-Encoding", encoding)
+	h.Del("Content-Length")
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write(body)
+	}
+}
+
+// assetsHandler 返回 /assets/ 路径的处理器
+func (s *staticFS) assetsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean("/" + strings.TrimPrefix(r.URL.Path, "/assets/"))
+		if name == "/" {
+			http.NotFound(w, r)
+			return
+		}
+		// Service Worker 需要尽快更新，不能长期缓存
+		cacheControl := "public, max-age=86400"
+		if name == "/service-worker.js" {
+			cacheControl = "no-cache"
+		}
+		s.serve(w, r, "assets"+name, cacheControl)
+	})
+}
+
+// isCompressible 判断内容类型是否值得压缩
+func isCompressible(contentType string) bool {
+	for _, prefix := range compressibleTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsEncoding 判断 Accept-Encoding 是否接受指定编码，q 为 0 表示拒绝；
+// 没有列出该编码时按 * 判断，明确列出的编码优先于 *
+func acceptsEncoding(header, encoding string) bool {
+	wildcard := false
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.TrimSpace(name)
+		if strings.EqualFold(name, encoding) {
+			return positiveQuality(params)
+		}
+		if name == "*" {
+			wildcard = positiveQuality(params)
+		}
+	}
+	return wildcard
+}
+
+// positiveQuality 判断参数中的 q 值是否大于 0，没有 q 时为 1
+func positiveQuality(params string) bool {
+	for _, param := range strings.Split(params, ";") {
+		key, value, _ := strings.Cut(param, "=")
+		if !strings.EqualFold(strings.TrimSpace(key), "q") {
+			continue
+		}
+		q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		return err == nil && q > 0
+	}
+	return true
+}
+
+// etagMatches 判断 If-None-Match 是否匹配给定 ETag
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAcceptsEncoding(t *testing.T) {
+	for _, tc := range []struct {
+		header, encoding string
+		want             bool
+	}{
+		{"gzip, deflate, br", "br", true},
+		{"gzip, deflate", "br", false},
+		{"GZIP", "gzip", true},
+		{"gzip;q=0.5", "gzip", true},
+		{"gzip;q=0", "gzip", false},
+		{"gzip;q=0.0", "gzip", false},
+		{"gzip; q=0.000", "gzip", false},
+		{"gzip;Q=0", "gzip", false},
+		{"gzip;q=abc", "gzip", false},
+		{"br;q=0, gzip;q=1", "gzip", true},
+		{"", "gzip", false},
+		// 没有列出的编码按 * 判断，明确列出的编码优先
+		{"*", "br", true},
+		{"gzip, *;q=0.1", "br", true},
+		{"*;q=0", "gzip", false},
+		{"gzip;q=0, *", "gzip", false},
+		{"*, br;q=0", "br", false},
+		{"br, *;q=0", "br", true},
+		{"identity, *;q=0", "gzip", false},
+	} {
+		if got := acceptsEncoding(tc.header, tc.encoding); got != tc.want {
+			t.Errorf("acceptsEncoding(%q, %q) = %v，期望 %v", tc.header, tc.encoding, got, tc.want)
+		}
+	}
+}
+
+// serveStatic 请求静态资源，返回响应和解码前的内容
+func serveStatic(t *testing.T, web *staticFS, url string, header map[string]string) (*http.Response, []byte) {
+	t.Helper()
+	req := httptest.NewRequest("GET", url, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	web.assetsHandler().ServeHTTP(w, req)
+	resp := w.Result()
+	body, _ := io.ReadAll(resp.Body)
+	return resp, body
+}
+
+func TestStaticEmbedded(t *testing.T) {
+	web, err := newStaticFS("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := embeddedWeb.ReadFile("assets/js/app.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, body := serveStatic(t, web, "/assets/js/app.js", nil)
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || !bytes.Equal(body, want) || etag == "" || resp.Header.Get("Content-Encoding") != "" {
+		t.Fatalf("未压缩的响应为 %s，ETag %q", resp.Status, etag)
+	}
+	if resp.Header.Get("Cache-Control") != "public, max-age=86400" {
+		t.Errorf("Cache-Control 为 %q", resp.Header.Get("Cache-Control"))
+	}
+	if resp, _ := serveStatic(t, web, "/assets/js/app.js", map[string]string{"If-None-Match": etag}); resp.StatusCode != http.StatusNotModified {
+		t.Errorf("ETag 匹配时返回 %s", resp.Status)
+	}
+
+	// gzip 版本（构建时生成或启动时压缩）使用单独的 ETag
+	resp, body = serveStatic(t, web, "/assets/js/app.js", map[string]string{"Accept-Encoding": "gzip"})
+	gzipETag := resp.Header.Get("ETag")
+	if resp.Header.Get("Content-Encoding") != "gzip" || gzipETag == etag || resp.Header.Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("gzip 响应的头为 %v", resp.Header)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := io.ReadAll(zr); !bytes.Equal(data, want) {
+		t.Fatal("解压后的内容与原文件不同")
+	}
+	if resp, _ := serveStatic(t, web, "/assets/js/app.js", map[string]string{"Accept-Encoding": "gzip", "If-None-Match": gzipETag}); resp.StatusCode != http.StatusNotModified {
+		t.Errorf("gzip ETag 匹配时返回 %s", resp.Status)
+	}
+	if resp, _ := serveStatic(t, web, "/assets/js/app.js", map[string]string{"Accept-Encoding": "gzip;q=0.0"}); resp.Header.Get("Content-Encoding") != "" {
+		t.Error("q=0.0 时不应压缩")
+	}
+
+	if resp, _ := serveStatic(t, web, "/assets/../templates/index.html", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("访问 assets 之外的文件返回 %s", resp.Status)
+	}
+	if resp, _ := serveStatic(t, web, "/assets/service-worker.js", nil); resp.StatusCode != http.StatusOK || resp.Header.Get("Cache-Control") != "no-cache" {
+		t.Errorf("Service Worker 返回 %s，Cache-Control 为 %q", resp.Status, resp.Header.Get("Cache-Control"))
+	}
+}
+
+func TestStaticWebDir(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "assets"), 0755)
+	file := filepath.Join(dir, "assets", "app.js")
+	os.WriteFile(file, []byte("v1"), 0644)
+	os.WriteFile(file+".br", []byte("brotli"), 0644)
+	os.WriteFile(file+".gz", []byte("gzipped"), 0644)
+	web, err := newStaticFS(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 优先使用预压缩的文件
+	for _, tc := range []struct{ accept, encoding, body string }{
+		{"gzip, br", "br", "brotli"},
+		{"gzip, br;q=0", "gzip", "gzipped"},
+		{"", "", "v1"},
+	} {
+		resp, body := serveStatic(t, web, "/assets/app.js", map[string]string{"Accept-Encoding": tc.accept})
+		if resp.Header.Get("Content-Encoding") != tc.encoding || string(body) != tc.body {
+			t.Errorf("Accept-Encoding %q 返回 %q 编码的 %q", tc.accept, resp.Header.Get("Content-Encoding"), body)
+		}
+		if resp.Header.Get("Cache-Control") != "no-cache" {
+			t.Errorf("开发模式的 Cache-Control 为 %q", resp.Header.Get("Cache-Control"))
+		}
+	}
+
+	// 开发模式下修改文件立即生效
+	_, before := serveStatic(t, web, "/assets/app.js", nil)
+	os.WriteFile(file, []byte("v2"), 0644)
+	resp, after := serveStatic(t, web, "/assets/app.js", nil)
+	if string(before) != "v1" || string(after) != "v2" || resp.Header.Get("ETag") == "" {
+		t.Fatalf("修改前后的内容为 %q, %q", before, after)
+	}
+	if resp, _ := serveStatic(t, web, "/assets/missing.js", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("不存在的文件返回 %s", resp.Status)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// statusPageTTL 公开状态页数据的缓存时间
+const statusPageTTL = 30 * time.Second
+
+// publicStatus 是公开状态页的数据，只包含管理员选择公开的内容
+type publicStatus struct {
+	Title     string             `json:"title"`
+	Notice    string             `json:"notice,omitempty"`
+	Items     []publicStatusItem `json:"items"`
+	Incidents []publicIncident   `json:"incidents"` // 未解决和最近解决的故障事件，新的在前
+	UpdatedAt time.Time          `json:"updatedAt"`
+}
+
+// publicIncident 是状态页上的故障事件，不包含ID
+type publicIncident struct {
+	Title      string           `json:"title"`
+	Status     string           `json:"status"`
+	Updates    []IncidentUpdate `json:"updates"`
+	CreatedAt  time.Time        `json:"createdAt"`
+	ResolvedAt time.Time        `json:"resolvedAt,omitzero"`
+}
+
+// publicStatusItem 是状态页上一个对象的状态，不包含ID、探测目标和错误信息
+type publicStatusItem struct {
+	Name      string     `json:"name"`
+	Up        *bool      `json:"up"` // 尚无数据时为 null
+	Uptime24h *float64   `json:"uptime24h"`
+	Uptime7d  *float64   `json:"uptime7d"`
+	Uptime30d *float64   `json:"uptime30d"`
+	Days      []CheckDay `json:"days"`
+}
+
+// statusPageCache 缓存公开状态页的数据，避免匿名访问频繁读取数据库
+type statusPageCache struct {
+	mu   sync.Mutex
+	at   time.Time
+	data *publicStatus
+}
+
+// reset 清除缓存，状态页配置修改后调用
+func (c *statusPageCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.data = nil
+}
+
+// normalizeStatusPage 校验状态页配置
+func normalizeStatusPage(page *StatusPage) error {
+	if page.Title == "" {
+		page.Title = "服务状态"
+	}
+	seen := make(map[string]bool)
+	items := page.Items[:0]
+	for _, item := range page.Items {
+		switch item.Kind {
+		case "client", "monitor":
+		case "check":
+			if !strings.Contains(item.ID, "/") {
+				return fmt.Errorf("客户端探测ID %q 无效", item.ID)
+			}
+		default:
+			return fmt.Errorf("类型 %q 无效，可选 client、check、monitor", item.Kind)
+		}
+		if item.ID == "" {
+			return fmt.Errorf("%s 缺少ID", item.Kind)
+		}
+		key := item.Kind + ":" + item.ID
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		items = append(items, item)
+	}
+	page.Items = items
+	return nil
+}
+
+// publicStatus 返回公开状态页的数据，状态页未启用时返回 nil
+func (s *Server) publicStatus(now time.Time) (*publicStatus, error) {
+	s.statusCache.mu.Lock()
+	defer s.statusCache.mu.Unlock()
+	if s.statusCache.data != nil && now.Sub(s.statusCache.at) < statusPageTTL {
+		return s.statusCache.data, nil
+	}
+
+	page, err := s.store.GetStatusPage()
+	if err != nil {
+		return nil, fmt.Errorf("读取状态页配置出错: %w", err)
+	}
+	if !page.Enabled {
+		return nil, nil
+	}
+
+	status := &publicStatus{
+		Title:     page.Title,
+		Notice:    page.Notice,
+		Items:     []publicStatusItem{},
+		Incidents: []publicIncident{},
+		UpdatedAt: now,
+	}
+	snap := s.clients.Snapshot()
+	for _, item := range page.Items {
+		var (
+			name   string
+			latest *CheckResult
+		)
+		switch item.Kind {
+		case "client":
+			c, ok := snap.Get(item.ID)
+			if !ok {
+				continue
+			}
+			name = c.Name
+			latest = &CheckResult{Up: c.Connected}
+		case "check":
+			clientID, checkName, _ := strings.Cut(item.ID, "/")
+			c, ok := snap.Get(clientID)
+			if !ok {
+				continue
+			}
+			name = checkName
+			if i := slices.IndexFunc(c.Checks, func(r CheckResult) bool { return r.Name == checkName }); i >= 0 {
+				latest = &c.Checks[i]
+			}
+		case "monitor":
+			m, ok := s.monitors.Get(item.ID)
+			if !ok {
+				continue
+			}
+			name = m.Name
+			latest = m.Latest
+		}
+		if item.Name != "" {
+			name = item.Name
+		}
+
+		cs, err := s.checkStatus(item.ID, CheckResult{}, now)
+		if err != nil {
+			return nil, err
+		}
+		public := publicStatusItem{
+			Name:      name,
+			Uptime24h: cs.Uptime24h,
+			Uptime7d:  cs.Uptime7d,
+			Uptime30d: cs.Uptime30d,
+			Days:      cs.Days,
+		}
+		if latest != nil {
+			up := latest.Up
+			public.Up = &up
+		}
+		status.Items = append(status.Items, public)
+	}
+
+	incidents, err := s.store.ListIncidents()
+	if err != nil {
+		return nil, fmt.Errorf("读取故障事件出错: %w", err)
+	}
+	sort.Slice(incidents, func(i, j int) bool { return incidents[i].CreatedAt.After(incidents[j].CreatedAt) })
+	for _, incident := range incidents {
+		if incident.Status == "resolved" && now.Sub(incident.ResolvedAt) > incidentRetention {
+			continue
+		}
+		status.Incidents = append(status.Incidents, publicIncident{
+			Title:      incident.Title,
+			Status:     incident.Status,
+			Updates:    incident.Updates,
+			CreatedAt:  incident.CreatedAt,
+			ResolvedAt: incident.ResolvedAt,
+		})
+	}
+
+	s.statusCache.data, s.statusCache.at = status, now
+	return status, nil
+}
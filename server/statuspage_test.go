@@ -0,0 +1,98 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatusPage(t *testing.T) {
+	server, ts := newTestServer(t)
+	admin := loginClient(t, ts)
+	anonymous := newCookieClient()
+
+	// 未启用时不提供数据
+	resp, err := anonymous.Get(ts.URL + "/status/data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("未启用时返回 %s，期望 404", resp.Status)
+	}
+
+	idA := addClient(t, admin, ts, "内部主机名-a")
+	addClient(t, admin, ts, "不公开的客户端")
+	conn := dialAgent(t, ts, idA)
+	defer conn.Close()
+	check := CheckResult{Name: "web", Type: "http", Target: "http://10.0.0.1/secret", Up: false, Message: "内部错误信息", Time: time.Now()}
+	if err := conn.WriteJSON(Metrics{Checks: []CheckResult{check}}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "探测结果", func() bool {
+		c, _ := server.clients.Snapshot().Get(idA)
+		return len(c.Checks) == 1
+	})
+	server.recordClientUptime(time.Now())
+	monitorID := mustPost(t, admin, ts.URL+"/api/monitors/save", Monitor{Name: "官网", Type: "dns", Target: "internal.example"}, http.StatusOK)["id"]
+
+	mustPost(t, admin, ts.URL+"/api/status-page/save", StatusPage{
+		Enabled: true,
+		Items:   []StatusPageItem{{Kind: "unknown", ID: idA}},
+	}, http.StatusBadRequest)
+	mustPost(t, anonymous, ts.URL+"/api/status-page/save", StatusPage{Enabled: true}, http.StatusUnauthorized)
+	mustPost(t, admin, ts.URL+"/api/status-page/save", StatusPage{
+		Enabled: true,
+		Title:   "我们的服务",
+		Notice:  "正在排查问题",
+		Items: []StatusPageItem{
+			{Kind: "client", ID: idA, Name: "主站"},
+			{Kind: "check", ID: clientCheckID(idA, "web")},
+			{Kind: "monitor", ID: monitorID},
+			{Kind: "client", ID: "已删除的客户端"},
+		},
+	}, http.StatusOK)
+
+	resp, err = anonymous.Get(ts.URL + "/status/data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("状态页数据返回 %s", resp.Status)
+	}
+	for _, secret := range []string{idA, "内部主机名", "不公开", "10.0.0.1", "内部错误信息", "internal.example", monitorID} {
+		if strings.Contains(string(body), secret) {
+			t.Fatalf("状态页数据中包含 %q: %s", secret, body)
+		}
+	}
+
+	var status publicStatus
+	mustGet(t, anonymous, ts.URL+"/status/data", &status)
+	if status.Title != "我们的服务" || status.Notice != "正在排查问题" || len(status.Items) != 3 {
+		t.Fatalf("状态页数据为 %+v", status)
+	}
+	client, web, site := status.Items[0], status.Items[1], status.Items[2]
+	if client.Name != "主站" || client.Up == nil || !*client.Up || client.Uptime24h == nil || *client.Uptime24h != 100 {
+		t.Fatalf("客户端状态为 %+v", client)
+	}
+	if web.Name != "web" || web.Up == nil || *web.Up {
+		t.Fatalf("探测状态为 %+v", web)
+	}
+	if site.Name != "官网" || site.Up != nil || len(site.Days) != uptimeDays {
+		t.Fatalf("站点监控状态为 %+v", site)
+	}
+
+	// 页面本身无需登录
+	resp, err = anonymous.Get(ts.URL + "/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("状态页返回 %s", resp.Status)
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrNotFound 表示请求的记录不存在
+var ErrNotFound = errors.New("记录不存在")
+
+// Session 表示一个登录会话
+type Session struct {
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// AlertRule 表示一条告警规则
+type AlertRule struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	ClientID  string    `json:"clientId"` // 为空表示作用于所有客户端
+	Metric    string    `json:"metric"`   // 指标名称，如 cpu、memory
+	Operator  string    `json:"operator"` // 比较运算符：>、>=、<、<=
+	Threshold float64   `json:"threshold"`
+	Duration  int       `json:"duration"` // 持续满足条件多少秒后触发
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	// Labels 只作用于带有所有这些标签的客户端，标签的规则与 /api/clients 的 label 筛选相同
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// AlertEvent 表示一次告警状态变化
+type AlertEvent struct {
+	ID       uint64    `json:"id"`
+	RuleID   string    `json:"ruleId"`
+	ClientID string    `json:"clientId"`
+	State    string    `json:"state"` // firing 或 resolved
+	Value    float64   `json:"value"`
+	Message  string    `json:"message"`
+	Time     time.Time `json:"time"`
+}
+
+// AuthEvent 表示一次登录尝试
+type AuthEvent struct {
+	ID        uint64    `json:"id"`
+	Time      time.Time `json:"time"`
+	Username  string    `json:"username"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"userAgent"`
+	Result    string    `json:"result"` // success、failure 或 locked，locked 表示因尝试过多被拒绝，没有检查密码
+}
+
+// Monitor 表示由服务端执行的探测，用于监控不便安装客户端的网站和服务
+type Monitor struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Type         string    `json:"type"`     // http、tcp 或 dns
+	Target       string    `json:"target"`   // http 为 URL，tcp 为 host:port，dns 为域名
+	Interval     int       `json:"interval"` // 探测间隔（秒）
+	Timeout      int       `json:"timeout"`  // 单次探测超时（秒）
+	DisplayOrder int       `json:"displayOrder"`
+	CreatedAt    time.Time `json:"createdAt"`
+
+	// 以下仅用于 http 探测
+	Method       string `json:"method,omitempty"`       // 请求方法，默认 GET
+	ExpectStatus []int  `json:"expectStatus,omitempty"` // 期望的状态码，为空表示 200-399
+	BodyMatch    string `json:"bodyMatch,omitempty"`    // 响应内容（前 1MB）中需要包含匹配该正则的内容
+	MinCertDays  int    `json:"minCertDays,omitempty"`  // 证书剩余有效期少于该天数时视为失败
+}
+
+// Maintenance 表示一个维护窗口，窗口内不产生新的告警
+// 一次性维护使用 StartsAt 和 EndsAt，周期性维护使用 Cron 和 Duration
+type Maintenance struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Targets   []string  `json:"targets"` // 客户端或站点监控ID，与 Groups 都为空表示全部
+	Groups    []string  `json:"groups"`  // 客户端分组，包含下级分组
+	StartsAt  time.Time `json:"startsAt,omitzero"`
+	EndsAt    time.Time `json:"endsAt,omitzero"`
+	Cron      string    `json:"cron,omitempty"`     // 每次维护开始的时间：分 时 日 月 周，按服务器本地时区
+	Duration  int       `json:"duration,omitempty"` // 每次维护持续的分钟数
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Incident 表示一次手动记录的故障事件，显示在公开状态页上
+type Incident struct {
+	ID         string           `json:"id"`
+	Title      string           `json:"title"`
+	Status     string           `json:"status"`  // investigating、identified、monitoring 或 resolved
+	Updates    []IncidentUpdate `json:"updates"` // 按时间顺序排列的进展
+	CreatedAt  time.Time        `json:"createdAt"`
+	ResolvedAt time.Time        `json:"resolvedAt,omitzero"`
+}
+
+// IncidentUpdate 表示故障事件的一条进展
+type IncidentUpdate struct {
+	Status  string    `json:"status"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+// EnrollToken 表示客户端自助注册使用的令牌，数据库中以令牌的哈希值为键，不保存令牌原文
+type EnrollToken struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Group      string    `json:"group,omitempty"` // 注册的客户端所属的分组
+	MaxUses    int       `json:"maxUses"`         // 最多可注册的客户端数量，0 表示不限
+	Uses       int       `json:"uses"`
+	ExpiresAt  time.Time `json:"expiresAt,omitzero"` // 为零值表示永不过期
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt,omitzero"`
+}
+
+// APIToken 表示用户为脚本等自动化程序创建的个人令牌，数据库中以令牌的哈希值为键，不保存令牌原文
+type APIToken struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Username   string    `json:"username"`           // 创建令牌的用户
+	Scopes     []string  `json:"scopes"`             // read、write 或 admin，高的权限包含低的权限
+	ExpiresAt  time.Time `json:"expiresAt,omitzero"` // 为零值表示永不过期
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt,omitzero"`
+}
+
+// StatusPage 表示公开状态页的配置
+type StatusPage struct {
+	Enabled bool             `json:"enabled"`
+	Title   string           `json:"title"`
+	Notice  string           `json:"notice"` // 显示在页面顶部的说明，如故障或维护通知
+	Items   []StatusPageItem `json:"items"`  // 按显示顺序排列
+}
+
+// StatusPageItem 表示状态页上公开的一个对象
+type StatusPageItem struct {
+	Kind string `json:"kind"` // client、check 或 monitor
+	ID   string `json:"id"`   // 客户端ID、客户端探测ID（<客户端ID>/<探测名称>）或站点监控ID
+	Name string `json:"name"` // 显示名称，为空时使用原名称
+}
+
+// MetricPoint 表示历史指标中的一个数据点
+type MetricPoint struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+// Store 是服务端状态的持久化接口
+type Store interface {
+	// ListClients 返回所有已注册的客户端
+	ListClients() ([]*Client, error)
+	// SaveClients 在一个事务中保存一个或多个客户端
+	SaveClients(clients ...*Client) error
+	// DeleteClient 删除客户端及其历史指标、在线记录和探测结果
+	DeleteClient(id string) error
+
+	ListUsers() ([]User, error)
+	GetUser(username string) (User, error)
+	SaveUser(user User) error
+	// RenameUser 修改用户名并更新用户信息，旧用户名的会话一并失效
+	RenameUser(oldUsername string, user User) error
+	// ResetPassword 修改用户的密码哈希，该用户的所有会话一并失效，用户不存在时返回 ErrNotFound
+	ResetPassword(username, passwordHash string) error
+
+	// 会话以令牌的哈希值为键保存，数据库中不保存令牌原文
+	SaveSession(token string, session Session) error
+	GetSession(token string) (Session, error)
+	DeleteSession(token string) error
+	DeleteExpiredSessions(now time.Time) (int, error)
+
+	ListAlertRules() ([]AlertRule, error)
+	SaveAlertRule(rule AlertRule) error
+	DeleteAlertRule(id string) error
+
+	// AppendAlertEvent 追加一条告警事件并返回分配的ID
+	AppendAlertEvent(event AlertEvent) (uint64, error)
+	// ListAlertEvents 按时间倒序返回最近的告警事件
+	ListAlertEvents(limit int) ([]AlertEvent, error)
+
+	// AppendAuthEvent 追加一条登录记录，只保留最近的 keep 条
+	AppendAuthEvent(event AuthEvent, keep int) error
+	// ListAuthEvents 按时间倒序返回最近的登录记录
+	ListAuthEvents(limit int) ([]AuthEvent, error)
+
+	ListMonitors() ([]Monitor, error)
+	SaveMonitor(monitor Monitor) error
+	// DeleteMonitor 删除服务端探测及其探测结果
+	DeleteMonitor(id string) error
+
+	ListMaintenances() ([]Maintenance, error)
+	SaveMaintenance(m Maintenance) error
+	DeleteMaintenance(id string) error
+
+	ListIncidents() ([]Incident, error)
+	// GetIncident 获取故障事件，不存在时返回 ErrNotFound
+	GetIncident(id string) (Incident, error)
+	SaveIncident(incident Incident) error
+	DeleteIncident(id string) error
+
+	ListEnrollTokens() ([]EnrollToken, error)
+	SaveEnrollToken(token string, t EnrollToken) error
+	// DeleteEnrollToken 按ID删除注册令牌，不存在时返回 ErrNotFound
+	DeleteEnrollToken(id string) error
+	// UseEnrollToken 在一个事务中检查注册令牌是否可用并增加使用次数，令牌不存在时返回 ErrNotFound
+	UseEnrollToken(token string, now time.Time) (EnrollToken, error)
+
+	ListAPITokens() ([]APIToken, error)
+	SaveAPIToken(token string, t APIToken) error
+	// GetAPIToken 按令牌原文查找个人令牌，不检查是否过期
+	GetAPIToken(token string) (APIToken, error)
+	// DeleteAPIToken 按ID删除个人令牌，不存在时返回 ErrNotFound
+	DeleteAPIToken(id string) error
+	// TouchAPIToken 记录个人令牌的最后使用时间
+	TouchAPIToken(token string, now time.Time) error
+
+	// GetStatusPage 返回状态页配置，尚未配置时返回零值
+	GetStatusPage() (StatusPage, error)
+	SaveStatusPage(page StatusPage) error
+
+	// SaveMetricPoints 保存同一时刻多个客户端的指标，points 为 客户端ID -> 指标 -> 值
+	SaveMetricPoints(t time.Time, points map[string]map[string]float64) error
+	// ListMetricSeries 返回客户端有历史数据的所有指标
+	ListMetricSeries(clientID string) ([]string, error)
+	// ListMetricPoints 按时间顺序返回客户端某个指标在 since 之后的历史数据
+	ListMetricPoints(clientID, series string, since time.Time) ([]MetricPoint, error)
+	// DeleteMetricsBefore 删除 before 之前的历史数据，返回删除的数量
+	DeleteMetricsBefore(before time.Time) (int, error)
+
+	// AppendCheckResults 追加探测结果并更新按天汇总的可用率
+	// 客户端执行的探测ID为 <客户端ID>/<探测名称>，客户端的在线状态以客户端ID记录，
+	// 服务端探测的ID为 Monitor.ID
+	AppendCheckResults(checkID string, results ...CheckResult) error
+	// ListCheckResults 按时间顺序返回 since 之后的探测结果
+	ListCheckResults(checkID string, since time.Time) ([]CheckResult, error)
+	// ListCheckDays 按日期顺序返回 since 所在日期及之后每天的汇总
+	ListCheckDays(checkID string, since time.Time) ([]CheckDay, error)
+	// DeleteCheckResultsBefore 删除 before 之前的探测结果，按天汇总的数据不受影响
+	DeleteCheckResultsBefore(before time.Time) (int, error)
+
+	// Backup 把数据库在同一时刻的完整内容写入 w，不影响并发的读写
+	Backup(w io.Writer) error
+
+	Close() error
+}
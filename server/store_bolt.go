@@ -0,0 +1,976 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// 数据库中使用的桶
+var (
+	bucketMeta        = []byte("meta")
+	bucketClients     = []byte("clients")
+	bucketUsers       = []byte("users")
+	bucketSessions    = []byte("sessions")
+	bucketAlertRules  = []byte("alert_rules")
+	bucketAlertEvents = []byte("alert_events")
+	// metrics 下每个客户端一个子桶，其中每个指标一个子桶，键为 Unix 秒
+	bucketMetrics = []byte("metrics")
+	// check_results 和 check_days 下每个探测一个子桶，键分别为 Unix 纳秒和日期
+	bucketCheckResults = []byte("check_results")
+	bucketCheckDays    = []byte("check_days")
+	bucketMonitors     = []byte("monitors")
+	// settings 保存单条的配置，如状态页
+	bucketSettings     = []byte("settings")
+	bucketMaintenances = []byte("maintenances")
+	bucketIncidents    = []byte("incidents")
+	// enroll_tokens 以令牌的哈希值为键
+	bucketEnrollTokens = []byte("enroll_tokens")
+	// api_tokens 以令牌的哈希值为键
+	bucketAPITokens  = []byte("api_tokens")
+	bucketAuthEvents = []byte("auth_events")
+)
+
+var (
+	keySchemaVersion = []byte("schema_version")
+	keyStatusPage    = []byte("status_page")
+)
+
+// migrations 按顺序执行的数据库结构迁移，第 i 个迁移把版本从 i 升级到 i+1
+// 已发布的迁移不能修改，只能在末尾追加
+var migrations = []func(tx *bolt.Tx) error{
+	// 1: 初始结构
+	func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketClients, bucketUsers, bucketSessions, bucketAlertRules, bucketAlertEvents} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	// 2: 历史指标
+	func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketMetrics)
+		return err
+	},
+	// 3: 探测结果
+	func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketCheckResults, bucketCheckDays} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	// 4: 服务端探测
+	func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketMonitors)
+		return err
+	},
+	// 5: 状态页等配置
+	func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketSettings)
+		return err
+	},
+	// 6: 维护窗口和故障事件
+	func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketMaintenances, bucketIncidents} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	// 7: 注册令牌
+	func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketEnrollTokens)
+		return err
+	},
+	// 8: 个人令牌
+	func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketAPITokens)
+		return err
+	},
+	// 9: 登录记录
+	func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketAuthEvents)
+		return err
+	},
+	// 10: 用户密码改为保存哈希
+	func(tx *bolt.Tx) error {
+		users := tx.Bucket(bucketUsers)
+		var hashed []User
+		err := users.ForEach(func(k, v []byte) error {
+			var legacy legacyUser
+			if err := json.Unmarshal(v, &legacy); err != nil {
+				return fmt.Errorf("解析用户 %s 出错: %w", k, err)
+			}
+			hashed = append(hashed, legacy.hashed())
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, user := range hashed {
+			if err := putJSON(users, []byte(user.Username), user); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+// boltStore 基于 bbolt 的嵌入式存储实现
+type boltStore struct {
+	db *bolt.DB
+}
+
+// openBoltStore 打开数据库并执行未完成的迁移
+func openBoltStore(path string) (*boltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("打开数据库 %s 失败: %w", path, err)
+	}
+	s := &boltStore{db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// migrate 将数据库结构升级到最新版本
+func (s *boltStore) migrate() error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(bucketMeta)
+		if err != nil {
+			return err
+		}
+		version := 0
+		if v := meta.Get(keySchemaVersion); v != nil {
+			version = int(binary.BigEndian.Uint64(v))
+		}
+		if version > len(migrations) {
+			return fmt.Errorf("数据库版本 %d 高于程序支持的版本 %d，请升级服务端", version, len(migrations))
+		}
+		for ; version < len(migrations); version++ {
+			if err := migrations[version](tx); err != nil {
+				return fmt.Errorf("执行数据库迁移 %d 失败: %w", version+1, err)
+			}
+			log.Printf("数据库已迁移到版本 %d", version+1)
+		}
+		return meta.Put(keySchemaVersion, itob(uint64(version)))
+	})
+}
+
+// Backup 在一个只读事务中写出整个数据库文件，得到的是事务开始时的一致快照
+func (s *boltStore) Backup(w io.Writer) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		_, err := tx.WriteTo(w)
+		return err
+	})
+}
+
+// Close 关闭数据库
+func (s *boltStore) Close() error {
+	return s.db.Close()
+}
+
+// ListClients 返回所有已注册的客户端
+func (s *boltStore) ListClients() ([]*Client, error) {
+	var clients []*Client
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketClients).ForEach(func(k, v []byte) error {
+			client := &Client{}
+			if err := json.Unmarshal(v, client); err != nil {
+				return fmt.Errorf("解析客户端 %s 出错: %w", k, err)
+			}
+			client.ID = string(k)
+			clients = append(clients, client)
+			return nil
+		})
+	})
+	return clients, err
+}
+
+// SaveClients 在一个事务中保存一个或多个客户端
+func (s *boltStore) SaveClients(clients ...*Client) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketClients)
+		for _, client := range clients {
+			if err := putJSON(b, []byte(client.ID), client); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteClient 删除客户端及其历史指标、在线记录和探测结果
+func (s *boltStore) DeleteClient(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(bucketClients).Delete([]byte(id)); err != nil {
+			return err
+		}
+		err := tx.Bucket(bucketMetrics).DeleteBucket([]byte(id))
+		if err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+		prefix := []byte(id + "/")
+		for _, name := range [][]byte{bucketCheckResults, bucketCheckDays} {
+			err := tx.Bucket(name).DeleteBucket([]byte(id))
+			if err != nil && err != bolt.ErrBucketNotFound {
+				return err
+			}
+			if err := deleteBucketsWithPrefix(tx.Bucket(name), prefix); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListUsers 返回所有用户
+func (s *boltStore) ListUsers() ([]User, error) {
+	var users []User
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketUsers).ForEach(func(k, v []byte) error {
+			var user User
+			if err := json.Unmarshal(v, &user); err != nil {
+				return err
+			}
+			users = append(users, user)
+			return nil
+		})
+	})
+	return users, err
+}
+
+// GetUser 获取指定用户
+func (s *boltStore) GetUser(username string) (User, error) {
+	var user User
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return getJSON(tx.Bucket(bucketUsers), []byte(username), &user)
+	})
+	return user, err
+}
+
+// SaveUser 保存用户
+func (s *boltStore) SaveUser(user User) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putJSON(tx.Bucket(bucketUsers), []byte(user.Username), user)
+	})
+}
+
+// RenameUser 修改用户名并更新用户信息
+func (s *boltStore) RenameUser(oldUsername string, user User) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		users := tx.Bucket(bucketUsers)
+		if users.Get([]byte(oldUsername)) == nil {
+			return ErrNotFound
+		}
+		if oldUsername != user.Username {
+			if users.Get([]byte(user.Username)) != nil {
+				return fmt.Errorf("用户 %s 已存在", user.Username)
+			}
+			if err := users.Delete([]byte(oldUsername)); err != nil {
+				return err
+			}
+			if err := deleteSessionsOf(tx, oldUsername); err != nil {
+				return err
+			}
+			// 个人令牌属于用户而不是用户名，改名后继续有效
+			if err := renameAPITokensOf(tx, oldUsername, user.Username); err != nil {
+				return err
+			}
+		}
+		return putJSON(users, []byte(user.Username), user)
+	})
+}
+
+// ResetPassword 修改用户的密码哈希并删除该用户的所有会话
+func (s *boltStore) ResetPassword(username, passwordHash string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		users := tx.Bucket(bucketUsers)
+		var user User
+		if err := getJSON(users, []byte(username), &user); err != nil {
+			return err
+		}
+		user.PasswordHash = passwordHash
+		if err := deleteSessionsOf(tx, username); err != nil {
+			return err
+		}
+		return putJSON(users, []byte(username), user)
+	})
+}
+
+func (s *boltStore) SaveSession(token string, session Session) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putJSON(tx.Bucket(bucketSessions), sessionKey(token), session)
+	})
+}
+
+// GetSession 获取会话，不检查是否过期
+func (s *boltStore) GetSession(token string) (Session, error) {
+	var session Session
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return getJSON(tx.Bucket(bucketSessions), sessionKey(token), &session)
+	})
+	return session, err
+}
+
+// DeleteSession 删除会话
+func (s *boltStore) DeleteSession(token string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketSessions).Delete(sessionKey(token))
+	})
+}
+
+// DeleteExpiredSessions 删除所有已过期的会话，返回删除的数量
+func (s *boltStore) DeleteExpiredSessions(now time.Time) (int, error) {
+	var count int
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketSessions).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var session Session
+			if err := json.Unmarshal(v, &session); err != nil || now.After(session.ExpiresAt) {
+				if err := c.Delete(); err != nil {
+					return err
+				}
+				count++
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+// ListAlertRules 返回所有告警规则
+func (s *boltStore) ListAlertRules() ([]AlertRule, error) {
+	var rules []AlertRule
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketAlertRules).ForEach(func(k, v []byte) error {
+			var rule AlertRule
+			if err := json.Unmarshal(v, &rule); err != nil {
+				return err
+			}
+			rules = append(rules, rule)
+			return nil
+		})
+	})
+	return rules, err
+}
+
+// SaveAlertRule 保存告警规则
+func (s *boltStore) SaveAlertRule(rule AlertRule) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putJSON(tx.Bucket(bucketAlertRules), []byte(rule.ID), rule)
+	})
+}
+
+// DeleteAlertRule 删除告警规则
+func (s *boltStore) DeleteAlertRule(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketAlertRules).Delete([]byte(id))
+	})
+}
+
+// AppendAlertEvent 追加一条告警事件
+func (s *boltStore) AppendAlertEvent(event AlertEvent) (uint64, error) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketAlertEvents)
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		event.ID = id
+		return putJSON(b, itob(id), event)
+	})
+	return event.ID, err
+}
+
+// ListAlertEvents 按时间倒序返回最近的告警事件
+func (s *boltStore) ListAlertEvents(limit int) ([]AlertEvent, error) {
+	var events []AlertEvent
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketAlertEvents).Cursor()
+		for k, v := c.Last(); k != nil && (limit <= 0 || len(events) < limit); k, v = c.Prev() {
+			var event AlertEvent
+			if err := json.Unmarshal(v, &event); err != nil {
+				return err
+			}
+			events = append(events, event)
+		}
+		return nil
+	})
+	return events, err
+}
+
+// AppendAuthEvent 追加一条登录记录，并删除超出 keep 条的最早的记录
+func (s *boltStore) AppendAuthEvent(event AuthEvent, keep int) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketAuthEvents)
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		event.ID = id
+		if err := putJSON(b, itob(id), event); err != nil {
+			return err
+		}
+		// 序号连续递增，删除最早的记录直到只剩 keep 条
+		c := b.Cursor()
+		for k, _ := c.First(); k != nil && binary.BigEndian.Uint64(k)+uint64(keep) <= id; k, _ = c.First() {
+			if err := c.Delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListAuthEvents 按时间倒序返回最近的登录记录
+func (s *boltStore) ListAuthEvents(limit int) ([]AuthEvent, error) {
+	var events []AuthEvent
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketAuthEvents).Cursor()
+		for k, v := c.Last(); k != nil && (limit <= 0 || len(events) < limit); k, v = c.Prev() {
+			var event AuthEvent
+			if err := json.Unmarshal(v, &event); err != nil {
+				return err
+			}
+			events = append(events, event)
+		}
+		return nil
+	})
+	return events, err
+}
+
+// ListMonitors 返回所有服务端探测
+func (s *boltStore) ListMonitors() ([]Monitor, error) {
+	var monitors []Monitor
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketMonitors).ForEach(func(k, v []byte) error {
+			var monitor Monitor
+			if err := json.Unmarshal(v, &monitor); err != nil {
+				return fmt.Errorf("解析服务端探测 %s 出错: %w", k, err)
+			}
+			monitors = append(monitors, monitor)
+			return nil
+		})
+	})
+	return monitors, err
+}
+
+// SaveMonitor 保存服务端探测
+func (s *boltStore) SaveMonitor(monitor Monitor) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putJSON(tx.Bucket(bucketMonitors), []byte(monitor.ID), monitor)
+	})
+}
+
+// DeleteMonitor 删除服务端探测及其探测结果
+func (s *boltStore) DeleteMonitor(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(bucketMonitors).Delete([]byte(id)); err != nil {
+			return err
+		}
+		for _, name := range [][]byte{bucketCheckResults, bucketCheckDays} {
+			err := tx.Bucket(name).DeleteBucket([]byte(id))
+			if err != nil && err != bolt.ErrBucketNotFound {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListMaintenances 返回所有维护窗口
+func (s *boltStore) ListMaintenances() ([]Maintenance, error) {
+	var maintenances []Maintenance
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketMaintenances).ForEach(func(k, v []byte) error {
+			var m Maintenance
+			if err := json.Unmarshal(v, &m); err != nil {
+				return fmt.Errorf("解析维护窗口 %s 出错: %w", k, err)
+			}
+			maintenances = append(maintenances, m)
+			return nil
+		})
+	})
+	return maintenances, err
+}
+
+// SaveMaintenance 保存维护窗口
+func (s *boltStore) SaveMaintenance(m Maintenance) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putJSON(tx.Bucket(bucketMaintenances), []byte(m.ID), m)
+	})
+}
+
+// DeleteMaintenance 删除维护窗口
+func (s *boltStore) DeleteMaintenance(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketMaintenances).Delete([]byte(id))
+	})
+}
+
+// ListIncidents 返回所有故障事件
+func (s *boltStore) ListIncidents() ([]Incident, error) {
+	var incidents []Incident
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketIncidents).ForEach(func(k, v []byte) error {
+			var incident Incident
+			if err := json.Unmarshal(v, &incident); err != nil {
+				return fmt.Errorf("解析故障事件 %s 出错: %w", k, err)
+			}
+			incidents = append(incidents, incident)
+			return nil
+		})
+	})
+	return incidents, err
+}
+
+// GetIncident 获取故障事件
+func (s *boltStore) GetIncident(id string) (Incident, error) {
+	var incident Incident
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return getJSON(tx.Bucket(bucketIncidents), []byte(id), &incident)
+	})
+	return incident, err
+}
+
+// SaveIncident 保存故障事件
+func (s *boltStore) SaveIncident(incident Incident) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putJSON(tx.Bucket(bucketIncidents), []byte(incident.ID), incident)
+	})
+}
+
+// DeleteIncident 删除故障事件
+func (s *boltStore) DeleteIncident(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketIncidents).Delete([]byte(id))
+	})
+}
+
+// ListEnrollTokens 返回所有注册令牌
+func (s *boltStore) ListEnrollTokens() ([]EnrollToken, error) {
+	var tokens []EnrollToken
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketEnrollTokens).ForEach(func(k, v []byte) error {
+			var t EnrollToken
+			if err := json.Unmarshal(v, &t); err != nil {
+				return fmt.Errorf("解析注册令牌 %s 出错: %w", k, err)
+			}
+			tokens = append(tokens, t)
+			return nil
+		})
+	})
+	return tokens, err
+}
+
+// SaveEnrollToken 保存注册令牌
+func (s *boltStore) SaveEnrollToken(token string, t EnrollToken) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putJSON(tx.Bucket(bucketEnrollTokens), sessionKey(token), t)
+	})
+}
+
+// DeleteEnrollToken 按ID删除注册令牌
+func (s *boltStore) DeleteEnrollToken(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketEnrollTokens).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var t EnrollToken
+			if err := json.Unmarshal(v, &t); err != nil {
+				return fmt.Errorf("解析注册令牌 %s 出错: %w", k, err)
+			}
+			if t.ID == id {
+				return c.Delete()
+			}
+		}
+		return ErrNotFound
+	})
+}
+
+// UseEnrollToken 检查注册令牌是否可用并增加使用次数
+func (s *boltStore) UseEnrollToken(token string, now time.Time) (EnrollToken, error) {
+	var t EnrollToken
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketEnrollTokens)
+		if err := getJSON(b, sessionKey(token), &t); err != nil {
+			return err
+		}
+		if err := t.usable(now); err != nil {
+			return err
+		}
+		t.Uses++
+		t.LastUsedAt = now
+		return putJSON(b, sessionKey(token), t)
+	})
+	return t, err
+}
+
+// ListAPITokens 返回所有个人令牌
+func (s *boltStore) ListAPITokens() ([]APIToken, error) {
+	var tokens []APIToken
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketAPITokens).ForEach(func(k, v []byte) error {
+			var t APIToken
+			if err := json.Unmarshal(v, &t); err != nil {
+				return fmt.Errorf("解析个人令牌 %s 出错: %w", k, err)
+			}
+			tokens = append(tokens, t)
+			return nil
+		})
+	})
+	return tokens, err
+}
+
+// SaveAPIToken 保存个人令牌
+func (s *boltStore) SaveAPIToken(token string, t APIToken) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putJSON(tx.Bucket(bucketAPITokens), sessionKey(token), t)
+	})
+}
+
+// GetAPIToken 按令牌原文查找个人令牌
+func (s *boltStore) GetAPIToken(token string) (APIToken, error) {
+	var t APIToken
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return getJSON(tx.Bucket(bucketAPITokens), sessionKey(token), &t)
+	})
+	return t, err
+}
+
+// DeleteAPIToken 按ID删除个人令牌
+func (s *boltStore) DeleteAPIToken(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketAPITokens).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var t APIToken
+			if err := json.Unmarshal(v, &t); err != nil {
+				return fmt.Errorf("解析个人令牌 %s 出错: %w", k, err)
+			}
+			if t.ID == id {
+				return c.Delete()
+			}
+		}
+		return ErrNotFound
+	})
+}
+
+// TouchAPIToken 记录个人令牌的最后使用时间，令牌已被删除时不做任何事
+func (s *boltStore) TouchAPIToken(token string, now time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketAPITokens)
+		var t APIToken
+		if err := getJSON(b, sessionKey(token), &t); err == ErrNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		t.LastUsedAt = now
+		return putJSON(b, sessionKey(token), t)
+	})
+}
+
+// GetStatusPage 返回状态页配置，尚未配置时返回零值
+func (s *boltStore) GetStatusPage() (StatusPage, error) {
+	var page StatusPage
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return getJSON(tx.Bucket(bucketSettings), keyStatusPage, &page)
+	})
+	if err == ErrNotFound {
+		return StatusPage{}, nil
+	}
+	return page, err
+}
+
+// SaveStatusPage 保存状态页配置
+func (s *boltStore) SaveStatusPage(page StatusPage) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putJSON(tx.Bucket(bucketSettings), keyStatusPage, page)
+	})
+}
+
+// SaveMetricPoints 保存同一时刻多个客户端的指标
+func (s *boltStore) SaveMetricPoints(t time.Time, points map[string]map[string]float64) error {
+	key := itob(uint64(t.Unix()))
+	return s.db.Update(func(tx *bolt.Tx) error {
+		metrics := tx.Bucket(bucketMetrics)
+		for clientID, values := range points {
+			client, err := metrics.CreateBucketIfNotExists([]byte(clientID))
+			if err != nil {
+				return err
+			}
+			for series, v := range values {
+				b, err := client.CreateBucketIfNotExists([]byte(series))
+				if err != nil {
+					return err
+				}
+				if err := b.Put(key, itob(math.Float64bits(v))); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// ListMetricSeries 返回客户端有历史数据的所有指标
+func (s *boltStore) ListMetricSeries(clientID string) ([]string, error) {
+	var series []string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		client := tx.Bucket(bucketMetrics).Bucket([]byte(clientID))
+		if client == nil {
+			return nil
+		}
+		return client.ForEachBucket(func(k []byte) error {
+			series = append(series, string(k))
+			return nil
+		})
+	})
+	return series, err
+}
+
+// ListMetricPoints 按时间顺序返回客户端某个指标在 since 之后的历史数据
+func (s *boltStore) ListMetricPoints(clientID, series string, since time.Time) ([]MetricPoint, error) {
+	var points []MetricPoint
+	err := s.db.View(func(tx *bolt.Tx) error {
+		client := tx.Bucket(bucketMetrics).Bucket([]byte(clientID))
+		if client == nil {
+			return nil
+		}
+		b := client.Bucket([]byte(series))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.Seek(itob(uint64(max(since.Unix(), 0)))); k != nil; k, v = c.Next() {
+			points = append(points, MetricPoint{
+				Time:  time.Unix(int64(binary.BigEndian.Uint64(k)), 0),
+				Value: math.Float64frombits(binary.BigEndian.Uint64(v)),
+			})
+		}
+		return nil
+	})
+	return points, err
+}
+
+// DeleteMetricsBefore 删除 before 之前的历史数据，返回删除的数量
+func (s *boltStore) DeleteMetricsBefore(before time.Time) (int, error) {
+	var count int
+	limit := itob(uint64(max(before.Unix(), 0)))
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketMetrics).ForEachBucket(func(clientID []byte) error {
+			client := tx.Bucket(bucketMetrics).Bucket(clientID)
+			return client.ForEachBucket(func(series []byte) error {
+				c := client.Bucket(series).Cursor()
+				for k, _ := c.First(); k != nil && bytes.Compare(k, limit) < 0; k, _ = c.Next() {
+					if err := c.Delete(); err != nil {
+						return err
+					}
+					count++
+				}
+				return nil
+			})
+		})
+	})
+	return count, err
+}
+
+// AppendCheckResults 追加探测结果并更新按天汇总的可用率
+func (s *boltStore) AppendCheckResults(checkID string, results ...CheckResult) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		raw, err := tx.Bucket(bucketCheckResults).CreateBucketIfNotExists([]byte(checkID))
+		if err != nil {
+			return err
+		}
+		days, err := tx.Bucket(bucketCheckDays).CreateBucketIfNotExists([]byte(checkID))
+		if err != nil {
+			return err
+		}
+		for _, result := range results {
+			if err := putJSON(raw, itob(uint64(result.Time.UnixNano())), result); err != nil {
+				return err
+			}
+
+			day := CheckDay{Date: result.Time.Local().Format(time.DateOnly)}
+			if err := getJSON(days, []byte(day.Date), &day); err != nil && err != ErrNotFound {
+				return err
+			}
+			day.Total++
+			if result.Up {
+				day.Up++
+			}
+			day.LatencySum += result.Latency
+			if err := putJSON(days, []byte(day.Date), day); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListCheckResults 按时间顺序返回 since 之后的探测结果
+func (s *boltStore) ListCheckResults(checkID string, since time.Time) ([]CheckResult, error) {
+	var results []CheckResult
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketCheckResults).Bucket([]byte(checkID))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.Seek(itob(uint64(max(since.UnixNano(), 0)))); k != nil; k, v = c.Next() {
+			var result CheckResult
+			if err := json.Unmarshal(v, &result); err != nil {
+				return err
+			}
+			results = append(results, result)
+		}
+		return nil
+	})
+	return results, err
+}
+
+// ListCheckDays 按日期顺序返回 since 所在日期及之后每天的汇总
+func (s *boltStore) ListCheckDays(checkID string, since time.Time) ([]CheckDay, error) {
+	var days []CheckDay
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketCheckDays).Bucket([]byte(checkID))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.Seek([]byte(since.Local().Format(time.DateOnly))); k != nil; k, v = c.Next() {
+			var day CheckDay
+			if err := json.Unmarshal(v, &day); err != nil {
+				return err
+			}
+			days = append(days, day)
+		}
+		return nil
+	})
+	return days, err
+}
+
+// DeleteCheckResultsBefore 删除 before 之前的探测结果
+func (s *boltStore) DeleteCheckResultsBefore(before time.Time) (int, error) {
+	var count int
+	limit := itob(uint64(max(before.UnixNano(), 0)))
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		results := tx.Bucket(bucketCheckResults)
+		return results.ForEachBucket(func(checkID []byte) error {
+			c := results.Bucket(checkID).Cursor()
+			for k, _ := c.First(); k != nil && bytes.Compare(k, limit) < 0; k, _ = c.Next() {
+				if err := c.Delete(); err != nil {
+					return err
+				}
+				count++
+			}
+			return nil
+		})
+	})
+	return count, err
+}
+
+// deleteBucketsWithPrefix 删除名称以 prefix 开头的所有子桶
+func deleteBucketsWithPrefix(b *bolt.Bucket, prefix []byte) error {
+	var names [][]byte
+	c := b.Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		if v == nil {
+			names = append(names, append([]byte(nil), k...))
+		}
+	}
+	for _, name := range names {
+		if err := b.DeleteBucket(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renameAPITokensOf 把指定用户的个人令牌转移到新的用户名
+func renameAPITokensOf(tx *bolt.Tx, oldUsername, newUsername string) error {
+	b := tx.Bucket(bucketAPITokens)
+	// 遍历时修改数据可能使游标失效，先收集再保存
+	renamed := make(map[string]APIToken)
+	err := b.ForEach(func(k, v []byte) error {
+		var t APIToken
+		if err := json.Unmarshal(v, &t); err != nil {
+			return fmt.Errorf("解析个人令牌 %s 出错: %w", k, err)
+		}
+		if t.Username == oldUsername {
+			t.Username = newUsername
+			renamed[string(k)] = t
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for k, t := range renamed {
+		if err := putJSON(b, []byte(k), t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteSessionsOf 删除指定用户的所有会话
+func deleteSessionsOf(tx *bolt.Tx, username string) error {
+	c := tx.Bucket(bucketSessions).Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		var session Session
+		if err := json.Unmarshal(v, &session); err == nil && session.Username != username {
+			continue
+		}
+		if err := c.Delete(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sessionKey 返回会话令牌在数据库中的键
+func sessionKey(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return []byte(hex.EncodeToString(sum[:]))
+}
+
+// putJSON 将值序列化为 JSON 后写入桶
+func putJSON(b *bolt.Bucket, key []byte, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return b.Put(key, data)
+}
+
+// getJSON 从桶中读取并解析 JSON，键不存在时返回 ErrNotFound
+func getJSON(b *bolt.Bucket, key []byte, v any) error {
+	data := b.Get(key)
+	if data == nil {
+		return ErrNotFound
+	}
+	return json.Unmarshal(data, v)
+}
+
+// itob 将整数编码为大端字节序，保证键按数值顺序排列
+func itob(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}